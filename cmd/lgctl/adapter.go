@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/skyleronken/lemonclient/pkg/adapter"
+	"github.com/skyleronken/lemonclient/pkg/client"
+)
+
+const adapterUsage = `usage: lgctl adapter poll [-query Q] [-limit N] [-timeout S] [-jobs UUID,...] [-ignore TASK,...] ADAPTER
+
+Polls for a task as ADAPTER would and prints it with its chains. The task is issued to lgctl, so it will be reissued
+to the adapter once its timeout passes (or straight away after lgctl tasks retry).
+`
+
+// polledTask is a task returned by adapter poll
+type polledTask struct {
+	Task   client.TaskMetadata `json:"task"`
+	Chains []client.TaskChain  `json:"chains"`
+}
+
+func (a *app) adapter(args []string) error {
+	if len(args) == 0 || args[0] != "poll" {
+		return a.usage(adapterUsage)
+	}
+
+	fs := a.flagSet("adapter poll", adapterUsage)
+	q := fs.String("query", "", "only poll for tasks from this query")
+	limit := fs.Uint64("limit", 0, "maximum number of chains in the task")
+	timeout := fs.Int("timeout", 0, "seconds before the task is reissued")
+	jobs := fs.String("jobs", "", "comma separated job UUIDs to poll")
+	ignore := fs.String("ignore", "", "comma separated task UUIDs to skip")
+	args, err := a.parse(fs, args[1:], 1)
+	if err != nil {
+		return err
+	}
+
+	a1 := adapter.ConfigureAdapter(args[0])
+	p := adapter.AdapterPollingOpts{
+		AdapterBehaviors: adapter.AdapterBehaviors{Query: *q, Limit: *limit, Timeout: *timeout},
+		JobUuids:         splitList(*jobs),
+		IgnoreTaskUuids:  splitList(*ignore),
+	}
+
+	_, metadata, chains, err := a.client.PollAdapter(*a1, p)
+	if err != nil {
+		return err
+	}
+
+	out := newOutput("TASK", "JOB", "QUERY", "STATE", "TIMEOUT", "CHAINS")
+	if metadata.Task != "" {
+		out.add(polledTask{Task: metadata, Chains: chains}, metadata.Task, metadata.Job, metadata.Query, metadata.State,
+			strconv.Itoa(metadata.Timeout), strconv.Itoa(len(chains)))
+	}
+	return a.print(out)
+}
+
+// splitList splits a comma separated flag, returning nil for an empty one
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
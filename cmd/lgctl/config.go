@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/skyleronken/lemonclient/pkg/client"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultServer = "http://localhost:8000"
+
+// config holds the server settings. Each setting is taken from the first of: a command line flag, the environment
+// (LG_SERVICE, as used by scripts/tests.sh), the config file, or the default.
+type config struct {
+	Server string `yaml:"server"`
+	Output string `yaml:"output"`
+	Debug  bool   `yaml:"debug"`
+	Outbox string `yaml:"outbox"`
+}
+
+// defaultConfigPath is $XDG_CONFIG_HOME/lgctl/config.yaml, or the platform equivalent
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "lgctl", "config.yaml")
+}
+
+// loadConfig reads the config file at path. A missing file is only an error if the path was given explicitly.
+func loadConfig(path string, explicit bool) (config, error) {
+	var c config
+	if path == "" {
+		return c, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) && !explicit {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// resolve fills in settings that were not given as flags from the environment, then the file, then the defaults
+func (c config) resolve(file config, getenv func(string) string) config {
+	if c.Server == "" {
+		c.Server = getenv("LG_SERVICE")
+	}
+	if c.Server == "" {
+		c.Server = file.Server
+	}
+	if c.Server == "" {
+		c.Server = defaultServer
+	}
+
+	if c.Output == "" {
+		c.Output = file.Output
+	}
+	if c.Output == "" {
+		c.Output = formatTable
+	}
+
+	c.Debug = c.Debug || file.Debug
+	if c.Outbox == "" {
+		c.Outbox = file.Outbox
+	}
+	return c
+}
+
+// newClient creates a client for a server given as a URL (http://host:port) or host:port
+func newClient(server string, debug bool) (*client.LGClient, error) {
+	u, err := url.Parse(server)
+	if err != nil || u.Host == "" {
+		u, err = url.Parse("http://" + server)
+	}
+	if err != nil || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid server %q", server)
+	}
+	if u.Scheme != "http" {
+		return nil, fmt.Errorf("invalid server %q: only http is supported", server)
+	}
+
+	port := 80
+	if p := u.Port(); p != "" {
+		if port, err = strconv.Atoi(p); err != nil {
+			return nil, fmt.Errorf("invalid server %q: %w", server, err)
+		}
+	}
+
+	host := u.Hostname()
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		host = "[" + host + "]"
+	}
+	return client.CreateClient(host, port, debug)
+}
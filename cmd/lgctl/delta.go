@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skyleronken/lemonclient/pkg/client"
+	"github.com/skyleronken/lemonclient/pkg/graph"
+	"github.com/skyleronken/lemonclient/pkg/job"
+)
+
+const deltaUsage = `usage: lgctl delta tail [-pos N] [-f] [-interval D] UUID
+
+Prints the updates to a job's graph after -pos, which defaults to the job's current MaxID. With -f the delta is
+fetched again every interval until interrupted.
+`
+
+// deltaRow is one update from the delta stream, as printed by delta tail
+type deltaRow struct {
+	Pos  int64       `json:"pos"`
+	Kind string      `json:"kind"`
+	Tags []string    `json:"tags,omitempty"`
+	Data interface{} `json:"data"`
+}
+
+func (a *app) delta(args []string) error {
+	if len(args) == 0 || args[0] != "tail" {
+		return a.usage(deltaUsage)
+	}
+
+	fs := a.flagSet("delta tail", deltaUsage)
+	pos := fs.Int64("pos", -1, "position to start from")
+	follow := fs.Bool("f", false, "keep fetching updates")
+	interval := fs.Duration("interval", 2*time.Second, "delay between fetches with -f")
+	args, err := a.parse(fs, args[1:], 1)
+	if err != nil {
+		return err
+	}
+	uuid := args[0]
+
+	if *pos < 0 {
+		status, err := a.client.GetJobStatus(uuid)
+		if err != nil {
+			return err
+		}
+		*pos = int64(status.MaxID)
+	}
+
+	// rows are printed as each fetch completes, so with -f they appear as the job changes, and a table header is
+	// only printed before the first fetch
+	first := true
+	for {
+		out := newOutput()
+		if first {
+			out.header = []string{"POS", "KIND", "ID", "TYPE", "VALUE", "TAGS"}
+		}
+		next := *pos
+		var updateErr error
+		err := a.client.StreamDeltaContext(a.ctx, uuid, &client.DeltaParams{Position: pos}, func(header *client.DeltaHeader, flags int64, data interface{}, err error) {
+			if err != nil {
+				updateErr = err
+				return
+			}
+			if header.Pos > next {
+				next = header.Pos
+			}
+			if data != nil {
+				a.addDeltaRow(out, header, flags, data)
+			}
+		})
+		if err == nil {
+			err = updateErr
+		}
+		if a.ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if first || len(out.rows) > 0 {
+			if err := a.print(out); err != nil {
+				return err
+			}
+		}
+		first = false
+		*pos = next
+
+		if !*follow {
+			return nil
+		}
+		select {
+		case <-a.ctx.Done():
+			return nil
+		case <-time.After(*interval):
+		}
+	}
+}
+
+func (a *app) addDeltaRow(out *output, header *client.DeltaHeader, flags int64, data interface{}) {
+	row := deltaRow{Pos: header.Pos, Tags: client.GetTags(flags, header), Data: data}
+	id, typ, value := "", "", ""
+
+	switch v := data.(type) {
+	case job.JobMetadata:
+		row.Kind = "meta"
+	case graph.EdgeInterface:
+		row.Kind = "edge"
+		id, typ, value = strconv.Itoa(v.GetID()), v.GetType(), v.GetValue()
+	case graph.NodeInterface:
+		row.Kind = "node"
+		id, typ, value = strconv.Itoa(v.GetID()), v.GetType(), v.GetValue()
+	default:
+		row.Kind = fmt.Sprintf("flags=%d", flags)
+	}
+
+	out.add(row, strconv.FormatInt(header.Pos, 10), row.Kind, id, typ, value, strings.Join(row.Tags, ","))
+}
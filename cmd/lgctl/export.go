@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/skyleronken/lemonclient/pkg/export"
+	"github.com/skyleronken/lemonclient/pkg/graph"
+)
+
+// exportFormats are the formats written to a single file
+var exportFormats = map[string]func(io.Writer, *graph.Store, ...export.OptFunc) error{
+	"graphml": export.WriteGraphML,
+	"gexf":    export.WriteGEXF,
+	"dot":     export.WriteDOT,
+	"cypher":  export.WriteCypher,
+}
+
+const exportUsage = `usage: lgctl export [-format F] [-out PATH] [-name NAME] [-label LABEL] UUID
+
+Writes a job's graph as graphml, gexf, dot, cypher or neo4j-csv. neo4j-csv writes two files, PATH_nodes.csv and
+PATH_relationships.csv, so needs -out. Other formats are written to stdout unless -out is given.
+`
+
+func (a *app) export(args []string) error {
+	fs := a.flagSet("export", exportUsage)
+	format := fs.String("format", "graphml", "graphml, gexf, dot, cypher or neo4j-csv")
+	path := fs.String("out", "", "file to write (default stdout)")
+	name := fs.String("name", "", "graph name (default the job UUID)")
+	label := fs.String("label", export.DefaultNeo4jLabel, "label given to every node with cypher and neo4j-csv")
+	args, err := a.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	uuid := args[0]
+
+	write, ok := exportFormats[*format]
+	switch {
+	case *format == "neo4j-csv" && *path == "":
+		return fmt.Errorf("neo4j-csv needs -out")
+	case *format != "neo4j-csv" && !ok:
+		return fmt.Errorf("unknown export format %q", *format)
+	}
+
+	detail, err := a.client.GetJob(uuid)
+	if err != nil {
+		return err
+	}
+	s, err := detail.Store()
+	if err != nil {
+		return err
+	}
+
+	if *name == "" {
+		*name = uuid
+	}
+	opts := []export.OptFunc{export.WithName(*name), export.WithLabel(*label)}
+
+	if *format == "neo4j-csv" {
+		return writeFiles(func(files ...io.Writer) error {
+			return export.WriteNeo4jCSV(files[0], files[1], s, opts...)
+		}, *path+"_nodes.csv", *path+"_relationships.csv")
+	}
+	if *path == "" {
+		return write(a.stdout, s, opts...)
+	}
+	return writeFiles(func(files ...io.Writer) error { return write(files[0], s, opts...) }, *path)
+}
+
+// writeFiles creates each path and passes them to write, reporting errors from closing the files as well
+func writeFiles(write func(...io.Writer) error, paths ...string) (err error) {
+	files := make([]io.Writer, 0, len(paths))
+	for _, path := range paths {
+		f, createErr := os.Create(path)
+		if createErr != nil {
+			return createErr
+		}
+		defer func() {
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+		}()
+		files = append(files, f)
+	}
+	return write(files...)
+}
@@ -0,0 +1,528 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skyleronken/lemonclient/pkg/client"
+	"github.com/skyleronken/lemonclient/pkg/job"
+	"github.com/skyleronken/lemonclient/pkg/permissions"
+)
+
+const jobsUsage = `usage:
+  lgctl jobs ls
+  lgctl jobs create SPEC
+  lgctl jobs rm [-all] UUID...
+  lgctl jobs clone [-priority N] [-seeds SPEC] UUID
+  lgctl jobs template UUID
+  lgctl jobs bulk [filters] delete|disable|reset|priority N
+  lgctl jobs meta get UUID
+  lgctl jobs meta set [-priority N] [-enabled BOOL] [-set KEY=VALUE]... UUID
+  lgctl jobs roles ls UUID
+  lgctl jobs roles grant|revoke [-read] [-write] [-admin] UUID USER
+  lgctl jobs config UUID
+`
+
+func (a *app) jobs(args []string) error {
+	if len(args) == 0 {
+		return a.usage(jobsUsage)
+	}
+
+	switch args[0] {
+	case "ls":
+		return a.jobsList(args[1:])
+	case "create":
+		return a.jobsCreate(args[1:])
+	case "rm":
+		return a.jobsRemove(args[1:])
+	case "clone":
+		return a.jobsClone(args[1:])
+	case "template":
+		return a.jobsTemplate(args[1:])
+	case "bulk":
+		return a.jobsBulk(args[1:])
+	case "meta":
+		return a.jobsMeta(args[1:])
+	case "roles":
+		return a.jobsRoles(args[1:])
+	case "config":
+		return a.jobsConfig(args[1:])
+	default:
+		return a.usage(jobsUsage)
+	}
+}
+
+func (a *app) jobsList(args []string) error {
+	if _, err := a.parse(a.flagSet("jobs ls", "usage: lgctl jobs ls\n"), args, 0); err != nil {
+		return err
+	}
+
+	jobs, err := a.client.GetJobs()
+	if err != nil {
+		return err
+	}
+
+	out := newOutput("JOB", "NODES", "EDGES", "MAXID", "PRIORITY", "ENABLED", "CREATED")
+	for _, j := range jobs {
+		out.add(j, j.GraphID, strconv.Itoa(j.TotalNodes), strconv.Itoa(j.TotalEdges), strconv.Itoa(j.MaxID),
+			strconv.Itoa(int(j.Meta.Priority)), strconv.FormatBool(j.Meta.Enabled), j.CreatedAt)
+	}
+	return a.print(out)
+}
+
+func (a *app) jobsCreate(args []string) error {
+	fs := a.flagSet("jobs create", "usage: lgctl jobs create SPEC\n\nSPEC is a YAML or JSON job spec, see job.LoadSpec\n")
+	args, err := a.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	out := newOutput("SPEC", "JOB")
+	for _, path := range args {
+		j, err := job.LoadSpecFile(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		created, err := a.client.CreateJob(*j)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		out.add(created, path, created.UUID)
+	}
+	return a.print(out)
+}
+
+func (a *app) jobsRemove(args []string) error {
+	fs := a.flagSet("jobs rm", "usage: lgctl jobs rm [-all] UUID...\n")
+	all := fs.Bool("all", false, "delete every job on the server")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+
+	uuids := fs.Args()
+	if *all {
+		jobs, err := a.client.GetJobs()
+		if err != nil {
+			return err
+		}
+		for _, j := range jobs {
+			uuids = append(uuids, j.GraphID)
+		}
+	} else if len(uuids) == 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	// carry on past failures so one bad job does not stop the rest being deleted
+	var errs []error
+	out := newOutput("JOB", "DELETED")
+	for _, uuid := range uuids {
+		err := a.client.DeleteJob(uuid)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", uuid, err))
+		}
+		out.add(map[string]interface{}{"job": uuid, "deleted": err == nil}, uuid, strconv.FormatBool(err == nil))
+	}
+	if err := a.print(out); err != nil {
+		return err
+	}
+	return errors.Join(errs...)
+}
+
+const cloneUsage = `usage: lgctl jobs clone [-priority N] [-seeds SPEC] UUID
+
+Creates a job with the metadata, adapters and seeds of an existing one. -seeds replaces the seeds with the nodes and
+chains of a job spec.
+`
+
+func (a *app) jobsClone(args []string) error {
+	fs := a.flagSet("jobs clone", cloneUsage)
+	priority := fs.Uint("priority", 0, "priority of the new job (default the existing job's)")
+	seeds := fs.String("seeds", "", "job spec whose nodes and chains seed the new job")
+	args, err := a.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	var overrides []job.OptFunc
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "priority":
+			if *priority > 255 {
+				flagErr = fmt.Errorf("priority must be between 0 and 255")
+			}
+			overrides = append(overrides, job.WithPriority(uint8(*priority)))
+		case "seeds":
+			spec, err := job.LoadSpecFile(*seeds)
+			if err != nil {
+				flagErr = fmt.Errorf("%s: %w", *seeds, err)
+				return
+			}
+			overrides = append(overrides, job.WithNodes(spec.Nodes...), job.WithChains(spec.Chains...))
+		}
+	})
+	if flagErr != nil {
+		return flagErr
+	}
+
+	created, err := a.client.CloneJob(args[0], overrides...)
+	if err != nil {
+		return err
+	}
+
+	out := newOutput("FROM", "JOB")
+	out.add(created, args[0], created.UUID)
+	return a.print(out)
+}
+
+// jobsTemplate prints a job as a spec which jobs create (or jobs clone -seeds) can read, whatever the output format
+func (a *app) jobsTemplate(args []string) error {
+	args, err := a.parse(a.flagSet("jobs template", "usage: lgctl jobs template UUID > job.json\n"), args, 1)
+	if err != nil {
+		return err
+	}
+
+	template, err := a.client.TemplateFromJob(args[0])
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(a.stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(template)
+}
+
+const bulkUsage = `usage: lgctl jobs bulk [filters] [-dry-run] [-concurrency N] delete|disable|reset|priority N
+
+Applies an action to every job matching all of the filters given, and reports what happened to each job.
+`
+
+func (a *app) jobsBulk(args []string) error {
+	fs := a.flagSet("jobs bulk", bulkUsage)
+	createdBefore := fs.String("created-before", "", "jobs created before an RFC 3339 time, or longer ago than a duration (e.g. 72h)")
+	minSize := fs.Int("min-size", 0, "jobs at least this many bytes")
+	maxSize := fs.Int("max-size", 0, "jobs at most this many bytes")
+	enabled := fs.String("enabled", "", "jobs which are enabled (true) or disabled (false)")
+	minPriority := fs.Uint("min-priority", 0, "jobs with at least this priority")
+	maxPriority := fs.Uint("max-priority", 255, "jobs with at most this priority")
+	role := fs.String("role", "", "jobs giving this user a role")
+	meta := fs.String("meta", "", "jobs whose metadata has KEY=VALUE")
+	dryRun := fs.Bool("dry-run", false, "list the jobs which would be affected without changing them")
+	concurrency := fs.Int("concurrency", 4, "most jobs changed at once")
+	args, err := a.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	var action client.JobAction
+	switch {
+	case args[0] == "delete" && len(args) == 1:
+		action = client.DeleteJobs()
+	case args[0] == "disable" && len(args) == 1:
+		action = client.DisableJobs()
+	case args[0] == "reset" && len(args) == 1:
+		action = client.ResetJobs()
+	case args[0] == "priority" && len(args) == 2:
+		priority, err := strconv.ParseUint(args[1], 10, 8)
+		if err != nil {
+			return fmt.Errorf("priority must be between 0 and 255")
+		}
+		action = client.PrioritizeJobs(uint8(priority))
+	default:
+		fs.Usage()
+		return errUsage
+	}
+
+	if *maxPriority > 255 || *minPriority > *maxPriority {
+		return fmt.Errorf("priorities must be between 0 and 255, with -min-priority no more than -max-priority")
+	}
+	filters := []client.JobFilter{
+		client.JobsWithSize(*minSize, *maxSize),
+		client.JobsWithPriority(uint8(*minPriority), uint8(*maxPriority)),
+	}
+	if *createdBefore != "" {
+		before, err := time.Parse(time.RFC3339, *createdBefore)
+		if err != nil {
+			age, durationErr := time.ParseDuration(*createdBefore)
+			if durationErr != nil {
+				return fmt.Errorf("-created-before must be an RFC 3339 time or a duration: %q", *createdBefore)
+			}
+			before = time.Now().Add(-age)
+		}
+		filters = append(filters, client.JobsCreatedBefore(before))
+	}
+	if *enabled != "" {
+		b, err := strconv.ParseBool(*enabled)
+		if err != nil {
+			return fmt.Errorf("-enabled must be true or false")
+		}
+		filters = append(filters, client.JobsEnabled(b))
+	}
+	if *role != "" {
+		filters = append(filters, client.JobsWithRole(*role))
+	}
+	if *meta != "" {
+		key, value, ok := strings.Cut(*meta, "=")
+		if !ok {
+			return fmt.Errorf("-meta must be KEY=VALUE")
+		}
+		filters = append(filters, client.JobsWithMeta(key, value))
+	}
+
+	opts := []client.BulkOptFunc{client.WithConcurrency(*concurrency)}
+	if *dryRun {
+		opts = append(opts, client.WithDryRun())
+	}
+	report, bulkErr := a.client.BulkJobs(a.ctx, action, client.MatchAll(filters...), opts...)
+	if report == nil {
+		return bulkErr
+	}
+
+	out := newOutput("JOB", "ACTION", "RESULT")
+	for _, res := range report {
+		result := "ok"
+		switch {
+		case res.DryRun:
+			result = "dry-run"
+		case res.Skipped:
+			result = "skipped"
+		case res.Err != nil:
+			result = res.Err.Error()
+		}
+		out.add(map[string]interface{}{"job": res.Job.GraphID, "action": res.Action, "result": result},
+			res.Job.GraphID, res.Action, result)
+	}
+	if err := a.print(out); err != nil {
+		return err
+	}
+	if bulkErr != nil {
+		return bulkErr
+	}
+	return report.Err()
+}
+
+const metaUsage = `usage:
+  lgctl jobs meta get UUID
+  lgctl jobs meta set [-priority N] [-enabled BOOL] [-set KEY=VALUE]... UUID
+`
+
+func (a *app) jobsMeta(args []string) error {
+	if len(args) == 0 {
+		return a.usage(metaUsage)
+	}
+
+	switch args[0] {
+	case "get":
+		args, err := a.parse(a.flagSet("jobs meta get", "usage: lgctl jobs meta get UUID\n"), args[1:], 1)
+		if err != nil {
+			return err
+		}
+		meta, err := a.client.GetJobMetadata(args[0])
+		if err != nil {
+			return err
+		}
+		return a.printMeta(meta)
+
+	case "set":
+		fs := a.flagSet("jobs meta set", "usage: lgctl jobs meta set [-priority N] [-enabled BOOL] [-set KEY=VALUE]... UUID\n")
+		priority := fs.Uint("priority", 0, "job priority, 0-255")
+		enabled := fs.Bool("enabled", true, "whether the job's tasks are issued")
+		var set metaFlag
+		fs.Var(&set, "set", "set KEY to VALUE, which is read as JSON if it can be and as a string otherwise (repeatable)")
+		args, err := a.parse(fs, args[1:], 1)
+		if err != nil {
+			return err
+		}
+		if *priority > 255 {
+			return fmt.Errorf("priority must be between 0 and 255")
+		}
+
+		// only the flags given are sent, so the server keeps everything else
+		meta, err := a.client.GetJobMetadata(args[0])
+		if err != nil {
+			return err
+		}
+		fields := map[string]interface{}{}
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "priority":
+				meta.Priority = uint8(*priority)
+				fields["priority"] = meta.Priority
+			case "enabled":
+				meta.Enabled = *enabled
+				fields["enabled"] = meta.Enabled
+			}
+		})
+		for _, kv := range set {
+			if err := meta.Set(kv.key, kv.value); err != nil {
+				return err
+			}
+			fields[kv.key] = kv.value
+		}
+		if err := a.client.UpdateJobMetadataFields(args[0], fields); err != nil {
+			return err
+		}
+		return a.printMeta(meta)
+
+	default:
+		return a.usage(metaUsage)
+	}
+}
+
+func (a *app) printMeta(meta job.JobMetadata) error {
+	names := make([]string, len(meta.Roles))
+	for idx, user := range meta.Roles {
+		names[idx] = user.Name
+	}
+
+	extra := make([]string, 0, len(meta.Extra))
+	for _, key := range meta.Keys() {
+		value, _ := meta.Get(key)
+		if s, ok := value.(string); ok {
+			extra = append(extra, key+"="+s)
+			continue
+		}
+		data, _ := json.Marshal(value)
+		extra = append(extra, key+"="+string(data))
+	}
+
+	out := newOutput("PRIORITY", "ENABLED", "ROLES", "META")
+	out.add(meta, strconv.Itoa(int(meta.Priority)), strconv.FormatBool(meta.Enabled), strings.Join(names, ","), strings.Join(extra, ","))
+	return a.print(out)
+}
+
+type metaValue struct {
+	key   string
+	value interface{}
+}
+
+// metaFlag collects -set KEY=VALUE flags
+type metaFlag []metaValue
+
+func (f *metaFlag) String() string {
+	return ""
+}
+
+func (f *metaFlag) Set(s string) error {
+	key, raw, ok := strings.Cut(s, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("must be KEY=VALUE")
+	}
+	var value interface{} = raw
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.UseNumber()
+	var v interface{}
+	if dec.Decode(&v) == nil && !dec.More() {
+		value = v
+	}
+	*f = append(*f, metaValue{key: key, value: value})
+	return nil
+}
+
+const rolesUsage = `usage:
+  lgctl jobs roles ls UUID
+  lgctl jobs roles grant|revoke [-read] [-write] [-admin] UUID USER
+
+USER may be a group, written @group.
+`
+
+func (a *app) jobsRoles(args []string) error {
+	if len(args) == 0 {
+		return a.usage(rolesUsage)
+	}
+
+	switch args[0] {
+	case "ls":
+		args, err := a.parse(a.flagSet("jobs roles ls", rolesUsage), args[1:], 1)
+		if err != nil {
+			return err
+		}
+		return a.printRoles(args[0])
+
+	case "grant", "revoke":
+		fs := a.flagSet("jobs roles "+args[0], rolesUsage)
+		read := fs.Bool("read", false, "the reader role")
+		write := fs.Bool("write", false, "the writer role")
+		admin := fs.Bool("admin", false, "the admin role")
+		rest, err := a.parse(fs, args[1:], 2)
+		if err != nil {
+			return err
+		}
+		if !*read && !*write && !*admin {
+			return fmt.Errorf("give at least one of -read, -write and -admin")
+		}
+
+		perms := permissions.Permissions{Reader: *read, Writer: *write, Admin: *admin}
+		if args[0] == "grant" {
+			err = a.client.GrantJobRole(rest[0], rest[1], perms)
+		} else {
+			err = a.client.RevokeJobRole(rest[0], rest[1], perms)
+		}
+		if err != nil {
+			return err
+		}
+		return a.printRoles(rest[0])
+
+	default:
+		return a.usage(rolesUsage)
+	}
+}
+
+func (a *app) printRoles(uuid string) error {
+	roles, err := a.client.GetJobRoles(uuid)
+	if err != nil {
+		return err
+	}
+
+	out := newOutput("USER", "READER", "WRITER", "ADMIN")
+	for _, user := range roles {
+		out.add(user, user.Name, strconv.FormatBool(user.Reader), strconv.FormatBool(user.Writer), strconv.FormatBool(user.Admin))
+	}
+	return a.print(out)
+}
+
+// queryRow is one adapter query from a job's config, as printed by jobs config
+type queryRow struct {
+	Adapter string `json:"adapter"`
+	Query   string `json:"query"`
+	job.QueryConfig
+}
+
+func (a *app) jobsConfig(args []string) error {
+	args, err := a.parse(a.flagSet("jobs config", "usage: lgctl jobs config UUID\n"), args, 1)
+	if err != nil {
+		return err
+	}
+
+	config, err := a.client.GetJobConfig(args[0])
+	if err != nil {
+		return err
+	}
+
+	var rows []queryRow
+	for adapter, queries := range config {
+		for q, qc := range queries {
+			rows = append(rows, queryRow{Adapter: adapter, Query: q, QueryConfig: qc})
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Adapter != rows[j].Adapter {
+			return rows[i].Adapter < rows[j].Adapter
+		}
+		return rows[i].Query < rows[j].Query
+	})
+
+	out := newOutput("ADAPTER", "QUERY", "POS", "QLEN", "TASKS", "ACTIVE", "ENABLED", "AUTOTASK")
+	for _, r := range rows {
+		out.add(r, r.Adapter, r.Query, strconv.Itoa(r.Pos), strconv.Itoa(r.QLen), strconv.Itoa(r.Tasks),
+			strconv.FormatBool(r.Active), strconv.FormatBool(r.Enabled), strconv.FormatBool(r.AutoTask))
+	}
+	return a.print(out)
+}
@@ -0,0 +1,210 @@
+// lgctl is a command line tool for administering a LemonGrenade server with the lemonclient library.
+//
+// Usage:
+//
+//	lgctl [-server URL] [-config FILE] [-o table|json|jsonl] [-debug] COMMAND [ARGS]
+//
+// Commands:
+//
+//	status                                 server version and uptime
+//	jobs ls                                list jobs
+//	jobs create SPEC                       create a job from a YAML or JSON job spec
+//	jobs rm [-all] UUID...                 delete jobs
+//	jobs clone [-priority N] [-seeds SPEC] UUID
+//	jobs template UUID                     print a job as a spec to create others like it
+//	jobs bulk [filters] ACTION             delete, disable, reset or re-prioritize the jobs matching filters
+//	jobs meta get UUID                     show a job's metadata
+//	jobs meta set [-priority N] [-enabled BOOL] UUID
+//	jobs roles ls UUID                     list the users given roles on a job
+//	jobs roles grant|revoke [-read] [-write] [-admin] UUID USER
+//	jobs config UUID                       show a job's adapter queries and their progress
+//	tasks ls [-state STATE] UUID           list a job's tasks
+//	tasks rm UUID TASK...                  delete tasks
+//	tasks retry UUID TASK...               queue tasks for reissue
+//	delta tail [-pos N] [-f] UUID          print updates to a job's graph
+//	adapter poll [flags] ADAPTER           poll for a task as an adapter would
+//	export [-format F] [-out PATH] UUID    export a job's graph (graphml, gexf, dot, cypher or neo4j-csv)
+//	outbox ls|flush|purge [-file PATH]     inspect, send or discard task results waiting in an adapter's outbox
+//
+// The server is taken from -server, then the LG_SERVICE environment variable, then the config file
+// ($XDG_CONFIG_HOME/lgctl/config.yaml unless -config is given), and defaults to http://localhost:8000. The config
+// file may also set the output format, debug and the outbox file used by the outbox commands:
+//
+//	server: http://lg.example.com:8000
+//	output: json
+//	debug: false
+//	outbox: /var/lib/myadapter/outbox.jsonl
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/skyleronken/lemonclient/pkg/client"
+)
+
+// app is the state shared by every command
+type app struct {
+	ctx        context.Context
+	client     *client.LGClient
+	format     string
+	outboxFile string // used by the outbox commands when -file is not given
+	stdout     io.Writer
+	stderr     io.Writer
+}
+
+// errUsage is returned by commands given bad arguments, after the usage has been printed
+var errUsage = errors.New("usage")
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr, os.Getenv)
+	stop()
+	os.Exit(code)
+}
+
+func run(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer, getenv func(string) string) int {
+	var flags config
+	var configPath string
+
+	fs := flag.NewFlagSet("lgctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&flags.Server, "server", "", "LemonGrenade server URL (default $LG_SERVICE or "+defaultServer+")")
+	fs.StringVar(&configPath, "config", "", "config file (default "+defaultConfigPath()+")")
+	fs.StringVar(&flags.Output, "o", "", "output format: table, json or jsonl")
+	fs.BoolVar(&flags.Debug, "debug", false, "log requests and responses")
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	explicit := configPath != ""
+	if !explicit {
+		configPath = defaultConfigPath()
+	}
+	file, err := loadConfig(configPath, explicit)
+	if err != nil {
+		fmt.Fprintf(stderr, "lgctl: %v\n", err)
+		return 1
+	}
+
+	cfg := flags.resolve(file, getenv)
+	if !validFormat(cfg.Output) {
+		fmt.Fprintf(stderr, "lgctl: unknown output format %q\n", cfg.Output)
+		return 2
+	}
+
+	c, err := newClient(cfg.Server, cfg.Debug)
+	if err != nil {
+		fmt.Fprintf(stderr, "lgctl: %v\n", err)
+		return 2
+	}
+
+	a := &app{ctx: ctx, client: c, format: cfg.Output, outboxFile: cfg.Outbox, stdout: stdout, stderr: stderr}
+	if err := a.dispatch(fs.Args()); err != nil {
+		if errors.Is(err, errUsage) {
+			return 2
+		}
+		fmt.Fprintf(stderr, "lgctl: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+const usage = `usage: lgctl [flags] COMMAND [ARGS]
+
+commands:
+  status
+  jobs ls|create|rm|clone|template|bulk|config
+  jobs meta get|set
+  jobs roles ls|grant|revoke
+  tasks ls|rm|retry
+  delta tail
+  adapter poll
+  export
+  outbox ls|flush|purge
+
+flags:
+`
+
+func (a *app) dispatch(args []string) error {
+	if len(args) == 0 {
+		return a.usage(usage)
+	}
+
+	switch args[0] {
+	case "status":
+		return a.status(args[1:])
+	case "jobs":
+		return a.jobs(args[1:])
+	case "tasks":
+		return a.tasks(args[1:])
+	case "delta":
+		return a.delta(args[1:])
+	case "adapter":
+		return a.adapter(args[1:])
+	case "export":
+		return a.export(args[1:])
+	case "outbox":
+		return a.outbox(args[1:])
+	default:
+		fmt.Fprintf(a.stderr, "lgctl: unknown command %q\n", args[0])
+		return a.usage(usage)
+	}
+}
+
+// usage prints text and returns errUsage
+func (a *app) usage(text string) error {
+	fmt.Fprint(a.stderr, text)
+	return errUsage
+}
+
+// flagSet creates the flags for a subcommand, printing text as its usage
+func (a *app) flagSet(name string, text string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	fs.Usage = func() {
+		fmt.Fprint(a.stderr, text)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse parses a subcommand's flags, requiring at least min positional arguments
+func (a *app) parse(fs *flag.FlagSet, args []string, min int) ([]string, error) {
+	if err := fs.Parse(args); err != nil {
+		return nil, errUsage
+	}
+	if fs.NArg() < min {
+		fs.Usage()
+		return nil, errUsage
+	}
+	return fs.Args(), nil
+}
+
+func (a *app) print(out *output) error {
+	return out.write(a.stdout, a.format)
+}
+
+func (a *app) status(args []string) error {
+	if _, err := a.parse(a.flagSet("status", "usage: lgctl status\n"), args, 0); err != nil {
+		return err
+	}
+
+	status, err := a.client.Status()
+	if err != nil {
+		return err
+	}
+
+	out := newOutput("VERSION", "UPTIME")
+	out.add(status, status.Version, fmt.Sprintf("%.0fs", status.Uptime))
+	return a.print(out)
+}
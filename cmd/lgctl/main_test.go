@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skyleronken/lemonclient/pkg/client"
+	"github.com/skyleronken/lemonclient/pkg/task"
+	"github.com/stretchr/testify/assert"
+)
+
+func noEnv(string) string { return "" }
+
+func lgctl(t *testing.T, handler http.HandlerFunc, args ...string) (int, string, string) {
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+
+	// an empty config file keeps the user's own config out of the tests
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(configPath, nil, 0o600))
+
+	var stdout, stderr bytes.Buffer
+	args = append([]string{"-server", ts.URL, "-config", configPath}, args...)
+	code := run(context.Background(), args, &stdout, &stderr, noEnv)
+	return code, stdout.String(), stderr.String()
+}
+
+func Test_Config(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("server: http://file:1\noutput: json\n"), 0o600))
+
+	file, err := loadConfig(path, true)
+	assert.NoError(t, err)
+
+	env := func(key string) string {
+		if key == "LG_SERVICE" {
+			return "http://env:2"
+		}
+		return ""
+	}
+
+	// flags win over the environment, which wins over the file
+	assert.Equal(t, "http://flag:3", config{Server: "http://flag:3"}.resolve(file, env).Server)
+	assert.Equal(t, "http://env:2", config{}.resolve(file, env).Server)
+	assert.Equal(t, "http://file:1", config{}.resolve(file, noEnv).Server)
+	assert.Equal(t, defaultServer, config{}.resolve(config{}, noEnv).Server)
+	assert.Equal(t, formatJSON, config{}.resolve(file, noEnv).Output)
+	assert.Equal(t, formatTable, config{}.resolve(config{}, noEnv).Output)
+
+	_, err = loadConfig(filepath.Join(t.TempDir(), "missing.yaml"), false)
+	assert.NoError(t, err)
+	_, err = loadConfig(filepath.Join(t.TempDir(), "missing.yaml"), true)
+	assert.Error(t, err)
+
+	c, err := newClient("lg.example.com:8001", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "lg.example.com", c.Address)
+	assert.Equal(t, 8001, c.Port)
+	_, err = newClient("https://lg.example.com", false)
+	assert.Error(t, err)
+}
+
+func Test_JobsList(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/graph", r.URL.Path)
+		w.Write([]byte(`[
+			{"graph": "j1", "id": "j1", "nodes_count": 2, "edges_count": 1, "maxID": 3, "meta": {"priority": 10, "enabled": true}},
+			{"graph": "j2", "id": "j2", "maxID": 0, "meta": {}}
+		]`))
+	}
+
+	code, stdout, _ := lgctl(t, handler, "jobs", "ls")
+	assert.Equal(t, 0, code)
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	assert.Len(t, lines, 3)
+	assert.Equal(t, []string{"JOB", "NODES", "EDGES", "MAXID", "PRIORITY", "ENABLED", "CREATED"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"j1", "2", "1", "3", "10", "true"}, strings.Fields(lines[1]))
+
+	code, stdout, _ = lgctl(t, handler, "-o", "jsonl", "jobs", "ls")
+	assert.Equal(t, 0, code)
+	lines = strings.Split(strings.TrimSpace(stdout), "\n")
+	assert.Len(t, lines, 2)
+	var j map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &j))
+	assert.Equal(t, "j1", j["graph"])
+
+	code, stdout, _ = lgctl(t, handler, "-o", "json", "jobs", "ls")
+	assert.Equal(t, 0, code)
+	var jobs []map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(stdout), &jobs))
+	assert.Len(t, jobs, 2)
+}
+
+func Test_TasksRetry(t *testing.T) {
+	var posted []string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		var body struct {
+			State map[string]string `json:"state"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		// errored tasks are retried as well as active and idle ones
+		assert.Equal(t, "retry", body.State["error"])
+		assert.Equal(t, "retry", body.State["idle"])
+		posted = append(posted, r.URL.Path)
+		w.Write([]byte(`{}`))
+	}
+
+	code, stdout, _ := lgctl(t, handler, "tasks", "retry", "j1", "t1", "t2")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, []string{"/lg/task/j1/t1", "/lg/task/j1/t2"}, posted)
+	assert.Contains(t, stdout, "t2")
+}
+
+func Test_Export(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{
+			"graph": "j1", "id": "j1", "maxID": 3,
+			"nodes": [{"ID": 1, "type": "domain", "value": "a.com"}, {"ID": 2, "type": "ip", "value": "1.1.1.1"}],
+			"edges": [{"ID": 3, "type": "resolves", "srcID": 1, "tgtID": 2}]
+		}`))
+	}
+
+	code, stdout, _ := lgctl(t, handler, "export", "-format", "dot", "j1")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "digraph")
+	assert.Contains(t, stdout, "resolves")
+
+	prefix := filepath.Join(t.TempDir(), "j1")
+	code, _, _ = lgctl(t, handler, "export", "-format", "neo4j-csv", "-out", prefix, "j1")
+	assert.Equal(t, 0, code)
+	nodes, err := os.ReadFile(prefix + "_nodes.csv")
+	assert.NoError(t, err)
+	assert.Contains(t, string(nodes), "a.com")
+
+	code, _, stderr := lgctl(t, handler, "export", "-format", "svg", "j1")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, `unknown export format "svg"`)
+
+	code, _, _ = lgctl(t, handler, "export")
+	assert.Equal(t, 2, code)
+}
+
+func Test_JobsBulk(t *testing.T) {
+	var deleted []string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			deleted = append(deleted, r.URL.Path)
+			return
+		}
+		w.Write([]byte(`[
+			{"graph": "j1", "created": "2024-01-01T00:00:00Z", "meta": {"priority": 10, "enabled": true}},
+			{"graph": "j2", "created": "2024-06-01T00:00:00Z", "meta": {"priority": 10, "enabled": false}}
+		]`))
+	}
+
+	code, stdout, _ := lgctl(t, handler, "jobs", "bulk", "-created-before", "2024-03-01T00:00:00Z", "-dry-run", "delete")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "dry-run")
+	assert.NotContains(t, stdout, "j2")
+	assert.Empty(t, deleted)
+
+	code, _, _ = lgctl(t, handler, "jobs", "bulk", "-enabled", "false", "delete")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, []string{"/graph/j2"}, deleted)
+
+	code, _, _ = lgctl(t, handler, "jobs", "bulk", "priority")
+	assert.Equal(t, 2, code)
+}
+
+func Test_JobsMeta(t *testing.T) {
+	var put map[string]interface{}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/graph/j1/meta", r.URL.Path)
+		if r.Method == http.MethodPut {
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&put))
+			return
+		}
+		w.Write([]byte(`{"priority": 10, "enabled": true, "case": "CASE-1"}`))
+	}
+
+	code, stdout, _ := lgctl(t, handler, "jobs", "meta", "get", "j1")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "case=CASE-1")
+
+	code, _, _ = lgctl(t, handler, "jobs", "meta", "set", "-set", "analyst=alice", "-set", "count=3", "j1")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, map[string]interface{}{"analyst": "alice", "count": 3.0}, put)
+
+	put = nil
+	code, _, _ = lgctl(t, handler, "jobs", "meta", "set", "-enabled=false", "-priority", "0", "j1")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, map[string]interface{}{"priority": 0.0, "enabled": false}, put)
+
+	code, _, stderr := lgctl(t, handler, "jobs", "meta", "set", "-set", "roles={}", "j1")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "reserved")
+}
+
+func Test_Outbox(t *testing.T) {
+	up := false
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if !up {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		w.Write([]byte(`{}`))
+	}
+	ts := httptest.NewServer(http.HandlerFunc(handler))
+	t.Cleanup(ts.Close)
+	c, err := newClient(ts.URL, false)
+	assert.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	ob, err := client.OpenOutbox(c, path)
+	assert.NoError(t, err)
+	assert.NoError(t, ob.Post("j1", "t1", *task.PrepareTaskResults()))
+	assert.NoError(t, ob.Post("j1", "t2", *task.PrepareTaskResults()))
+	assert.NoError(t, ob.Post("j2", "t1", *task.PrepareTaskResults()))
+	assert.NoError(t, ob.Close())
+
+	code, stdout, _ := lgctl(t, handler, "outbox", "ls", "-file", path)
+	assert.Equal(t, 0, code)
+	assert.Len(t, strings.Split(strings.TrimSpace(stdout), "\n"), 4)
+
+	code, stdout, _ = lgctl(t, handler, "outbox", "purge", "-file", path, "j1", "t2")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, []string{"PURGED", "1"}, strings.Fields(stdout))
+
+	// the outbox cannot be changed while an adapter has it open
+	ob, err = client.OpenOutbox(c, path)
+	assert.NoError(t, err)
+	code, _, stderr := lgctl(t, handler, "outbox", "flush", "-file", path)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "outbox is already open")
+	assert.NoError(t, ob.Close())
+
+	up = true
+	code, stdout, _ = lgctl(t, handler, "outbox", "flush", "-file", path)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, []string{"SENT", "PENDING", "2", "0"}, strings.Fields(stdout))
+
+	code, _, _ = lgctl(t, handler, "outbox", "purge", "-file", path)
+	assert.Equal(t, 2, code)
+	code, _, stderr = lgctl(t, handler, "outbox", "ls")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "no outbox file")
+}
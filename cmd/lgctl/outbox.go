@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/skyleronken/lemonclient/pkg/client"
+)
+
+const outboxUsage = `usage:
+  lgctl outbox ls [-file PATH]
+  lgctl outbox flush [-file PATH]
+  lgctl outbox purge [-file PATH] -all | UUID [TASK]
+
+The outbox file is taken from -file, then the config file's outbox setting. flush and purge fail while an
+adapter has the outbox open, so stop the adapter first; ls can be run at any time.
+`
+
+func (a *app) outbox(args []string) error {
+	if len(args) == 0 {
+		return a.usage(outboxUsage)
+	}
+
+	switch args[0] {
+	case "ls":
+		return a.outboxList(args[1:])
+	case "flush":
+		return a.outboxFlush(args[1:])
+	case "purge":
+		return a.outboxPurge(args[1:])
+	default:
+		return a.usage(outboxUsage)
+	}
+}
+
+// outboxPath is the -file flag if given, or the configured outbox
+func (a *app) outboxPath(file string) (string, error) {
+	if file == "" {
+		file = a.outboxFile
+	}
+	if file == "" {
+		return "", fmt.Errorf("no outbox file: give -file or set outbox in the config file")
+	}
+	return file, nil
+}
+
+func (a *app) outboxList(args []string) error {
+	fs := a.flagSet("outbox ls", "usage: lgctl outbox ls [-file PATH]\n")
+	file := fs.String("file", "", "outbox file")
+	if _, err := a.parse(fs, args, 0); err != nil {
+		return err
+	}
+	path, err := a.outboxPath(*file)
+	if err != nil {
+		return err
+	}
+
+	entries, err := client.ReadOutbox(path)
+	if err != nil {
+		return err
+	}
+
+	out := newOutput("JOB", "TASK", "QUEUED", "ATTEMPTS", "REJECTED", "ERROR")
+	for _, e := range entries {
+		out.add(e, e.Job, e.Task, e.Queued.Format(time.RFC3339), strconv.Itoa(e.Attempts), strconv.FormatBool(e.Rejected), e.LastErr)
+	}
+	return a.print(out)
+}
+
+func (a *app) outboxFlush(args []string) error {
+	fs := a.flagSet("outbox flush", "usage: lgctl outbox flush [-file PATH]\n")
+	file := fs.String("file", "", "outbox file")
+	if _, err := a.parse(fs, args, 0); err != nil {
+		return err
+	}
+	path, err := a.outboxPath(*file)
+	if err != nil {
+		return err
+	}
+
+	ob, err := client.OpenOutbox(a.client, path)
+	if err != nil {
+		return err
+	}
+	defer ob.Close()
+
+	sent, flushErr := ob.Flush(a.ctx)
+	out := newOutput("SENT", "PENDING")
+	pending := len(ob.Pending())
+	out.add(map[string]interface{}{"sent": sent, "pending": pending}, strconv.Itoa(sent), strconv.Itoa(pending))
+	if err := a.print(out); err != nil {
+		return err
+	}
+	return flushErr
+}
+
+func (a *app) outboxPurge(args []string) error {
+	fs := a.flagSet("outbox purge", "usage: lgctl outbox purge [-file PATH] -all | UUID [TASK]\n")
+	file := fs.String("file", "", "outbox file")
+	all := fs.Bool("all", false, "purge every entry")
+	args, err := a.parse(fs, args, 0)
+	if err != nil {
+		return err
+	}
+	if *all == (len(args) > 0) || len(args) > 2 {
+		fs.Usage()
+		return errUsage
+	}
+	path, err := a.outboxPath(*file)
+	if err != nil {
+		return err
+	}
+
+	var jobId, taskId string
+	if len(args) > 0 {
+		jobId = args[0]
+	}
+	if len(args) > 1 {
+		taskId = args[1]
+	}
+
+	ob, err := client.OpenOutbox(a.client, path)
+	if err != nil {
+		return err
+	}
+	defer ob.Close()
+
+	purged, err := ob.Purge(jobId, taskId)
+	if err != nil {
+		return err
+	}
+	out := newOutput("PURGED")
+	out.add(map[string]interface{}{"purged": purged}, strconv.Itoa(purged))
+	return a.print(out)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatJSONL = "jsonl"
+)
+
+// output is what a command prints: rows of cells for table output, and the value behind each row for JSON output
+type output struct {
+	header []string
+	rows   [][]string
+	items  []interface{}
+}
+
+func newOutput(header ...string) *output {
+	return &output{header: header}
+}
+
+// add appends a row, cells being the table columns and item the value encoded in JSON
+func (o *output) add(item interface{}, cells ...string) {
+	o.rows = append(o.rows, cells)
+	o.items = append(o.items, item)
+}
+
+func validFormat(format string) bool {
+	return format == formatTable || format == formatJSON || format == formatJSONL
+}
+
+// write prints the output in the given format. JSON is a single array, JSONL one item per line.
+func (o *output) write(w io.Writer, format string) error {
+	switch format {
+	case formatJSON:
+		items := o.items
+		if items == nil {
+			items = []interface{}{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(items)
+	case formatJSONL:
+		enc := json.NewEncoder(w)
+		for _, item := range o.items {
+			if err := enc.Encode(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case formatTable:
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		if len(o.header) > 0 {
+			fmt.Fprintln(tw, strings.Join(o.header, "\t"))
+		}
+		for _, row := range o.rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+}
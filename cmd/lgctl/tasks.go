@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/skyleronken/lemonclient/pkg/task"
+)
+
+const tasksUsage = `usage:
+  lgctl tasks ls [-state STATE] UUID
+  lgctl tasks rm UUID TASK...
+  lgctl tasks retry UUID TASK...
+`
+
+func (a *app) tasks(args []string) error {
+	if len(args) == 0 {
+		return a.usage(tasksUsage)
+	}
+
+	switch args[0] {
+	case "ls":
+		return a.tasksList(args[1:])
+	case "rm":
+		return a.tasksSetState(args[1:], "rm", task.TaskState_Delete)
+	case "retry":
+		return a.tasksSetState(args[1:], "retry", task.TaskState_Retry)
+	default:
+		return a.usage(tasksUsage)
+	}
+}
+
+func (a *app) tasksList(args []string) error {
+	fs := a.flagSet("tasks ls", "usage: lgctl tasks ls [-state STATE] UUID\n")
+	state := fs.String("state", "", "only list tasks in this state")
+	args, err := a.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	tasks, err := a.client.GetTasks(args[0])
+	if err != nil {
+		return err
+	}
+
+	out := newOutput("TASK", "ADAPTER", "QUERY", "STATE", "RETRIES", "LENGTH", "DETAILS")
+	for _, t := range tasks {
+		if *state != "" && t.State != *state {
+			continue
+		}
+		out.add(t, t.Task, t.Adapter, t.Query, t.State, strconv.Itoa(t.Retries), strconv.Itoa(t.Length), t.Details)
+	}
+	return a.print(out)
+}
+
+// tasksSetState moves tasks to state whatever state they are currently in. UpdateTaskStatus cannot be used as it
+// only changes active and idle tasks, and errored tasks are the ones most often retried.
+func (a *app) tasksSetState(args []string, name string, state task.TaskState) error {
+	args, err := a.parse(a.flagSet("tasks "+name, fmt.Sprintf("usage: lgctl tasks %s UUID TASK...\n", name)), args, 2)
+	if err != nil {
+		return err
+	}
+
+	transitions := map[task.TaskState]task.TaskState{}
+	for _, from := range task.States {
+		transitions[from] = state
+	}
+	results := task.PrepareTaskResults(task.WithStateSetMatch(transitions))
+
+	var errs []error
+	out := newOutput("TASK", "STATE")
+	for _, taskId := range args[1:] {
+		if err := a.client.PostTaskResults(args[0], taskId, *results); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", taskId, err))
+			continue
+		}
+		out.add(map[string]interface{}{"task": taskId, "state": state}, taskId, string(state))
+	}
+	if err := a.print(out); err != nil {
+		return err
+	}
+	return errors.Join(errs...)
+}
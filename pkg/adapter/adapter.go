@@ -1,6 +1,14 @@
 package adapter
 
-import "strings"
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/skyleronken/lemonclient/pkg/query"
+)
 
 type Adapter struct {
 	Name string
@@ -16,9 +24,114 @@ type AdapterBehaviors struct {
 type AdapterOpts struct {
 	AdapterBehaviors
 	Filter   string `json:"filter,omitempty"`
-	Enabled  bool   `json:"enabled,omitempty"`
+	Enabled  bool   `json:"enabled"`
 	Autotask bool   `json:"autotask,omitempty"`
 	Position uint64 `json:"pos,omitempty"`
+
+	err error // set by options that could not be applied, and returned by Validate
+}
+
+// AdapterOptsList holds every query configured for one adapter in a job. LG keys an adapter's configs by query, so
+// adding a config for a query already in the list replaces it. A single config is serialized as an object, as LG
+// has always accepted, and several as an object keyed by query, as in the job's config (see job.AdapterConfig):
+//
+//	{"query": "n()", "limit": 10}
+//	{"n(type=\"domain\")": {"limit": 10}, "n(type=\"ip\")": {"enabled": true}}
+//
+// Both forms, and a list of configs, are accepted when unmarshalling.
+type AdapterOptsList []AdapterOpts
+
+// Add adds opts to the list, replacing any config for the same query
+func (l *AdapterOptsList) Add(opts AdapterOpts) {
+	for idx := range *l {
+		if (*l)[idx].Query == opts.Query {
+			(*l)[idx] = opts
+			return
+		}
+	}
+	*l = append(*l, opts)
+}
+
+// ListsFrom converts adapter configs keyed by name, as job.Opts.Adapters and task.TaskResultsOpts.Adapters held
+// before they took several queries per adapter, into single config lists
+func ListsFrom(opts map[string]AdapterOpts) map[string]AdapterOptsList {
+	lists := make(map[string]AdapterOptsList, len(opts))
+	for name, o := range opts {
+		lists[name] = AdapterOptsList{o}
+	}
+	return lists
+}
+
+func (l AdapterOptsList) MarshalJSON() ([]byte, error) {
+	if len(l) == 1 {
+		return json.Marshal(l[0])
+	}
+
+	byQuery := make(map[string]AdapterOpts, len(l))
+	for _, opts := range l {
+		if _, ok := byQuery[opts.Query]; ok {
+			return nil, fmt.Errorf("adapter has more than one config for query %q", opts.Query)
+		}
+		q := opts.Query
+		opts.Query = ""
+		byQuery[q] = opts
+	}
+	return json.Marshal(byQuery)
+}
+
+func (l *AdapterOptsList) UnmarshalJSON(data []byte) error {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		var list []AdapterOpts
+		if err := json.Unmarshal(data, &list); err != nil {
+			return err
+		}
+		*l = list
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(trimmed, &fields); err != nil {
+		return err
+	}
+
+	// a config only has scalar fields, so an object of objects is keyed by query
+	objects := 0
+	for _, raw := range fields {
+		if v := bytes.TrimSpace(raw); len(v) > 0 && v[0] == '{' {
+			objects++
+		}
+	}
+	switch objects {
+	case 0:
+		var single AdapterOpts
+		if err := json.Unmarshal(trimmed, &single); err != nil {
+			return err
+		}
+		*l = AdapterOptsList{single}
+		return nil
+	case len(fields):
+	default:
+		return fmt.Errorf("adapter configs keyed by query must all be objects")
+	}
+
+	queries := make([]string, 0, len(fields))
+	for q := range fields {
+		queries = append(queries, q)
+	}
+	sort.Strings(queries)
+
+	list := make(AdapterOptsList, 0, len(queries))
+	for _, q := range queries {
+		var opts AdapterOpts
+		if err := json.Unmarshal(fields[q], &opts); err != nil {
+			return fmt.Errorf("query %q: %w", q, err)
+		}
+		opts.Query = q
+		list = append(list, opts)
+	}
+	*l = list
+	return nil
 }
 
 type AdapterPollingOpts struct {
@@ -71,6 +184,22 @@ func WithLimit(limit uint64) AdapterOptFunc {
 func WithQuery(query string) AdapterOptFunc {
 	return func(opts *AdapterOpts) {
 		opts.Query = query
+		opts.err = nil
+	}
+}
+
+// WithQueryBuilder sets the adapter's query from a builder. A builder that failed to build leaves the query unset,
+// and its error is returned by NewAdapter and Validate.
+func WithQueryBuilder(b *query.Builder) AdapterOptFunc {
+	rendered := ""
+	built, err := b.Query()
+	if err == nil {
+		rendered = built.String()
+	}
+
+	return func(opts *AdapterOpts) {
+		opts.Query = rendered
+		opts.err = err
 	}
 }
 
@@ -80,6 +209,8 @@ func WithFilter(filter string) AdapterOptFunc {
 	}
 }
 
+// ConfigureAdapter applies the options without checking them, so a malformed query or filter is only found by
+// Validate, job.BuildJob or the server. Use NewAdapter to reject them here.
 func ConfigureAdapter(name string, opts ...AdapterOptFunc) *Adapter {
 	o := defaultOpts()
 	for _, fn := range opts {
@@ -91,3 +222,37 @@ func ConfigureAdapter(name string, opts ...AdapterOptFunc) *Adapter {
 		AdapterOpts: o,
 	}
 }
+
+// NewAdapter configures an adapter in the same way as ConfigureAdapter, but rejects malformed queries and filters
+func NewAdapter(name string, opts ...AdapterOptFunc) (*Adapter, error) {
+	a := ConfigureAdapter(name, opts...)
+	if err := a.Validate(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Validate checks that the adapter has a name and that its query and filter (if any) are valid LG queries
+func (a Adapter) Validate() error {
+	if len(a.Name) == 0 {
+		return fmt.Errorf("adapter name cannot be empty")
+	}
+
+	if a.err != nil {
+		return fmt.Errorf("adapter %s: %w", a.Name, a.err)
+	}
+
+	if len(a.Query) > 0 {
+		if err := query.Validate(a.Query); err != nil {
+			return fmt.Errorf("adapter %s: %w", a.Name, err)
+		}
+	}
+
+	if len(a.Filter) > 0 {
+		if err := query.Validate(a.Filter); err != nil {
+			return fmt.Errorf("adapter %s filter: %w", a.Name, err)
+		}
+	}
+
+	return nil
+}
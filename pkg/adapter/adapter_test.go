@@ -1 +1,92 @@
 package adapter
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/skyleronken/lemonclient/pkg/query"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NewAdapter(t *testing.T) {
+	a, err := NewAdapter("adapter_chain", WithQuery("n()->e()->n()"), WithFilter("n(type=foo)"))
+	assert.NoError(t, err)
+	assert.Equal(t, "ADAPTER_CHAIN", a.Name)
+
+	_, err = NewAdapter("adapter_bad", WithQuery("n()->n()"))
+	assert.Error(t, err)
+
+	_, err = NewAdapter("adapter_bad", WithQuery("n()"), WithFilter("n("))
+	assert.Error(t, err)
+
+	_, err = NewAdapter("", WithQuery("n()"))
+	assert.Error(t, err)
+}
+
+func Test_WithQueryBuilder(t *testing.T) {
+	b := query.N().Type("domain").Where("value", "~", "evil").E("resolves").N().Type("ip")
+
+	a, err := NewAdapter("adapter_builder", WithQueryBuilder(b))
+	assert.NoError(t, err)
+	assert.Equal(t, `n(type="domain",value~/evil/)->e(type="resolves")->n(type="ip")`, a.Query)
+	assert.Equal(t, 3, b.Length())
+
+	a, err = NewAdapter("adapter_parsed", WithQuery(query.MustParse("n( ) -> e( ) -> n( )").String()))
+	assert.NoError(t, err)
+	assert.Equal(t, "n()->e()->n()", a.Query)
+
+	// WithQuery can be used as a function value
+	var withQuery func(string) AdapterOptFunc = WithQuery
+	a, err = NewAdapter("adapter_func", withQuery("n()"))
+	assert.NoError(t, err)
+	assert.Equal(t, "n()", a.Query)
+
+	// a builder's errors are returned rather than rendering what was built before them
+	bad := query.N().Type("domain").Where("value", "like", "evil")
+	_, err = NewAdapter("adapter_bad_builder", WithQueryBuilder(bad))
+	assert.ErrorContains(t, err, "unknown operator")
+	assert.Error(t, ConfigureAdapter("adapter_bad_builder", WithQueryBuilder(bad)).Validate())
+	assert.Empty(t, ConfigureAdapter("adapter_bad_builder", WithQueryBuilder(bad)).Query)
+}
+
+func Test_AdapterOptsList(t *testing.T) {
+	domains := ConfigureAdapter("dns", WithQuery(`n(type="domain")`), WithLimit(10))
+	ips := ConfigureAdapter("dns", WithQuery(`n(type="ip")`))
+
+	var list AdapterOptsList
+	list.Add(domains.AdapterOpts)
+	data, err := json.Marshal(list)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"query": "n(type=\"domain\")", "limit": 10, "enabled": true}`, string(data))
+
+	list.Add(ips.AdapterOpts)
+	replaced := domains.AdapterOpts
+	replaced.Limit = 20
+	list.Add(replaced)
+	assert.Len(t, list, 2)
+	assert.Equal(t, uint64(20), list[0].Limit)
+
+	data, err = json.Marshal(list)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"n(type=\"domain\")": {"limit": 20, "enabled": true}, "n(type=\"ip\")": {"enabled": true}}`, string(data))
+
+	var back AdapterOptsList
+	assert.NoError(t, json.Unmarshal(data, &back))
+	assert.Equal(t, list, back)
+
+	assert.NoError(t, json.Unmarshal([]byte(` {"query": "n()"}`), &back))
+	assert.Equal(t, AdapterOptsList{{AdapterBehaviors: AdapterBehaviors{Query: "n()"}}}, back)
+
+	// the list form is still accepted
+	assert.NoError(t, json.Unmarshal([]byte(`[{"query": "n()"}, {"query": "n()->e()->n()", "limit": 5}]`), &back))
+	assert.Len(t, back, 2)
+	assert.Equal(t, uint64(5), back[1].Limit)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"n()": {"limit": 5}, "limit": 5}`), &back))
+
+	_, err = json.Marshal(AdapterOptsList{ips.AdapterOpts, ips.AdapterOpts})
+	assert.ErrorContains(t, err, "more than one config")
+
+	lists := ListsFrom(map[string]AdapterOpts{"DNS": domains.AdapterOpts})
+	assert.Equal(t, map[string]AdapterOptsList{"DNS": {domains.AdapterOpts}}, lists)
+}
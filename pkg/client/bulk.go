@@ -0,0 +1,260 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/skyleronken/lemonclient/pkg/permissions"
+)
+
+// JobFilter selects jobs for BulkJobs
+type JobFilter func(JobGraph) bool
+
+// MatchAll selects jobs matching every filter. With no filters every job is selected.
+func MatchAll(filters ...JobFilter) JobFilter {
+	return func(j JobGraph) bool {
+		for _, f := range filters {
+			if f != nil && !f(j) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// JobsCreatedBefore selects jobs created before t. Jobs whose creation time cannot be parsed are not selected.
+func JobsCreatedBefore(t time.Time) JobFilter {
+	return func(j JobGraph) bool {
+		created, err := time.Parse(time.RFC3339Nano, j.CreatedAt)
+		return err == nil && created.Before(t)
+	}
+}
+
+// JobsWithSize selects jobs whose size in bytes is between min and max inclusive. A max of 0 means no upper limit.
+func JobsWithSize(min int, max int) JobFilter {
+	return func(j JobGraph) bool {
+		return j.Size >= min && (max == 0 || j.Size <= max)
+	}
+}
+
+// JobsEnabled selects jobs which are enabled, or with false, disabled
+func JobsEnabled(enabled bool) JobFilter {
+	return func(j JobGraph) bool {
+		return j.Meta.Enabled == enabled
+	}
+}
+
+// JobsWithPriority selects jobs whose priority is between min and max inclusive
+func JobsWithPriority(min uint8, max uint8) JobFilter {
+	return func(j JobGraph) bool {
+		return j.Meta.Priority >= min && j.Meta.Priority <= max
+	}
+}
+
+// JobsWithRole selects jobs which give the named user a role with at least one permission. The empty entry left
+// behind by revoking a user's permissions does not count.
+func JobsWithRole(name string) JobFilter {
+	return func(j JobGraph) bool {
+		for _, user := range j.Meta.Roles {
+			if user.Name == name {
+				return user.Permissions != permissions.Permissions{}
+			}
+		}
+		return false
+	}
+}
+
+// JobsVisibleTo selects the jobs policy lets user read, for example to decide which jobs from GetJobs to show them
+func JobsVisibleTo(policy permissions.Policy, user string) JobFilter {
+	return func(j JobGraph) bool {
+		return policy.Can(user, j.Meta.Roles, permissions.ReadOnly)
+	}
+}
+
+// JobsWithMeta selects jobs whose metadata has key set to value. Values are compared in their JSON form, so
+// JobsWithMeta("priority", "10") and JobsWithMeta("enabled", "true") work as expected.
+func JobsWithMeta(key string, value string) JobFilter {
+	return func(j JobGraph) bool {
+		// priority and enabled are left out of the JSON when zero, so compare them directly
+		switch key {
+		case "priority":
+			return strconv.Itoa(int(j.Meta.Priority)) == value
+		case "enabled":
+			return strconv.FormatBool(j.Meta.Enabled) == value
+		}
+		data, err := json.Marshal(j.Meta)
+		if err != nil {
+			return false
+		}
+		var meta map[string]json.RawMessage
+		if err := json.Unmarshal(data, &meta); err != nil {
+			return false
+		}
+		raw, ok := meta[key]
+		if !ok {
+			return false
+		}
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			return s == value
+		}
+		return string(raw) == value
+	}
+}
+
+// JobAction is applied to each job selected by BulkJobs
+type JobAction struct {
+	Name  string
+	Apply func(s *LGClient, j JobGraph) error
+}
+
+// DeleteJobs deletes each job
+func DeleteJobs() JobAction {
+	return JobAction{Name: "delete", Apply: func(s *LGClient, j JobGraph) error {
+		return s.DeleteJob(j.GraphID)
+	}}
+}
+
+// DisableJobs disables each job so no more of its tasks are issued
+func DisableJobs() JobAction {
+	return JobAction{Name: "disable", Apply: func(s *LGClient, j JobGraph) error {
+		return s.UpdateJobMetadataFields(j.GraphID, map[string]interface{}{"enabled": false})
+	}}
+}
+
+// PrioritizeJobs sets the priority of each job
+func PrioritizeJobs(priority uint8) JobAction {
+	return JobAction{Name: fmt.Sprintf("prioritize %d", priority), Apply: func(s *LGClient, j JobGraph) error {
+		return s.UpdateJobMetadataFields(j.GraphID, map[string]interface{}{"priority": priority})
+	}}
+}
+
+// ResetJobs resets each job's graph to its seed data
+func ResetJobs() JobAction {
+	return JobAction{Name: "reset", Apply: func(s *LGClient, j JobGraph) error {
+		return s.ResetJob(j.GraphID)
+	}}
+}
+
+// BulkOpts configures BulkJobs
+type BulkOpts struct {
+	Concurrency int  // most jobs acted on at once
+	DryRun      bool // report the selected jobs without acting on them
+}
+
+type BulkOptFunc func(*BulkOpts)
+
+func defaultBulkOpts() BulkOpts {
+	return BulkOpts{
+		Concurrency: 4,
+	}
+}
+
+// WithConcurrency sets how many jobs are acted on at once
+func WithConcurrency(n int) BulkOptFunc {
+	return func(opts *BulkOpts) {
+		opts.Concurrency = n
+	}
+}
+
+// WithDryRun reports which jobs would be affected without changing them
+func WithDryRun() BulkOptFunc {
+	return func(opts *BulkOpts) {
+		opts.DryRun = true
+	}
+}
+
+// BulkResult is what happened to one job in BulkJobs
+type BulkResult struct {
+	Job     JobGraph
+	Action  string
+	DryRun  bool  // the action was not applied
+	Skipped bool  // ctx was done before the action could be applied
+	Err     error // the action failed
+}
+
+// BulkReport has a result for each selected job, in the order GetJobs returned them
+type BulkReport []BulkResult
+
+// Err joins the errors of every job which failed, or returns nil
+func (r BulkReport) Err() error {
+	var errs []error
+	for _, res := range r {
+		if res.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", res.Job.GraphID, res.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SelectJobs lists the jobs matching filter. A nil filter selects every job.
+func (s *LGClient) SelectJobs(filter JobFilter) (JobGraphs, error) {
+	jobs, err := s.GetJobs()
+	if err != nil {
+		return nil, err
+	}
+
+	selected := JobGraphs{}
+	for _, j := range jobs {
+		if filter == nil || filter(j) {
+			selected = append(selected, j)
+		}
+	}
+	return selected, nil
+}
+
+// BulkJobs applies action to every job matching filter, at most Concurrency at a time. A failure on one job does
+// not stop the others; each job's outcome is in the report, and report.Err() joins the failures. The returned error
+// is only set if the jobs could not be listed or ctx was done.
+func (s *LGClient) BulkJobs(ctx context.Context, action JobAction, filter JobFilter, opts ...BulkOptFunc) (BulkReport, error) {
+	o := defaultBulkOpts()
+	for _, fn := range opts {
+		fn(&o)
+	}
+	if o.Concurrency < 1 {
+		o.Concurrency = 1
+	}
+
+	jobs, err := s.SelectJobs(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	report := make(BulkReport, len(jobs))
+	for idx, j := range jobs {
+		report[idx] = BulkResult{Job: j, Action: action.Name, DryRun: o.DryRun}
+	}
+	if o.DryRun {
+		return report, nil
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, o.Concurrency)
+	for idx := range report {
+		if ctx.Err() != nil {
+			report[idx].Skipped = true
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			report[idx].Skipped = true
+			continue
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(res *BulkResult) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			res.Err = action.Apply(s, res.Job)
+		}(&report[idx])
+	}
+	wg.Wait()
+
+	return report, ctx.Err()
+}
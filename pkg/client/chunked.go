@@ -0,0 +1,141 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/skyleronken/lemonclient/pkg/graph"
+	"github.com/skyleronken/lemonclient/pkg/task"
+)
+
+// ChunkOpts bounds the size of each batch posted by PostTaskResultsChunked
+type ChunkOpts struct {
+	MaxItems int // most nodes, edges and chains in a batch
+	MaxBytes int // most bytes of encoded nodes, edges and chains in a batch; a larger single item is sent alone
+}
+
+type ChunkOptFunc func(*ChunkOpts)
+
+func defaultChunkOpts() ChunkOpts {
+	return ChunkOpts{
+		MaxItems: 1000,
+		MaxBytes: 4 << 20,
+	}
+}
+
+// WithChunkItems sets the most nodes, edges and chains sent in one batch
+func WithChunkItems(n int) ChunkOptFunc {
+	return func(opts *ChunkOpts) {
+		opts.MaxItems = n
+	}
+}
+
+// WithChunkBytes sets roughly the largest body sent in one batch
+func WithChunkBytes(n int) ChunkOptFunc {
+	return func(opts *ChunkOpts) {
+		opts.MaxBytes = n
+	}
+}
+
+// chunkItem is a node, chain or edge with its encoded size
+type chunkItem struct {
+	node  graph.NodeInterface
+	chain graph.ChainInterface
+	edge  graph.EdgeInterface
+	size  int
+}
+
+// PostTaskResultsChunked posts results too large for one request in batches, nodes first, then chains, then edges.
+// Adapters are configured with the first batch, and only the last batch carries the details and the state
+// transition. The batches before it are guarded with task.WithStates, using the states the transition applies to,
+// so they leave the task's state alone and are only accepted while the task could still take the transition.
+//
+// If a batch fails the batches already posted stay in the graph and the task keeps its state, so the same results
+// can be posted again; LemonGrenade merges nodes and edges it already has.
+// POST /lg/task/{job_uuid}/{task_uuid}
+func (s *LGClient) PostTaskResultsChunked(ctx context.Context, jobId, taskId string, tResults task.TaskResults, opts ...ChunkOptFunc) error {
+	o := defaultChunkOpts()
+	for _, fn := range opts {
+		fn(&o)
+	}
+	if o.MaxItems < 1 {
+		o.MaxItems = 1
+	}
+
+	if err := validateTaskResults(tResults); err != nil {
+		return err
+	}
+
+	var items []chunkItem
+	for idx, n := range tResults.Nodes {
+		data, err := json.Marshal(n)
+		if err != nil {
+			return fmt.Errorf("node %d: %w", idx, err)
+		}
+		items = append(items, chunkItem{node: n, size: len(data)})
+	}
+	for idx, c := range tResults.Chains {
+		data, err := json.Marshal(c)
+		if err != nil {
+			return fmt.Errorf("chain %d: %w", idx, err)
+		}
+		items = append(items, chunkItem{chain: c, size: len(data)})
+	}
+	for idx, e := range tResults.Edges {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("edge %d: %w", idx, err)
+		}
+		items = append(items, chunkItem{edge: e, size: len(data)})
+	}
+
+	var batches [][]chunkItem
+	var batch []chunkItem
+	size := 0
+	for _, item := range items {
+		if len(batch) > 0 && (len(batch) >= o.MaxItems || (o.MaxBytes > 0 && size+item.size > o.MaxBytes)) {
+			batches = append(batches, batch)
+			batch, size = nil, 0
+		}
+		batch = append(batch, item)
+		size += item.size
+	}
+	// the last batch is always sent, even when empty, to make the state transition
+	batches = append(batches, batch)
+
+	guard := task.WithStates(tResults.State.From())
+	resultsUrl := fmt.Sprintf("/lg/task/%s/%s", jobId, taskId)
+	for idx, batch := range batches {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("batch %d of %d: %w", idx+1, len(batches), err)
+		}
+
+		r := task.PrepareTaskResults()
+		r.Timeout = tResults.Timeout
+		if idx == 0 {
+			r.Adapters = tResults.Adapters
+		}
+		if idx == len(batches)-1 {
+			r.State = tResults.State
+			r.Details = tResults.Details
+		} else {
+			guard(&r.TaskResultsOpts)
+		}
+		for _, item := range batch {
+			switch {
+			case item.node != nil:
+				r.Nodes = append(r.Nodes, item.node)
+			case item.chain != nil:
+				r.Chains = append(r.Chains, item.chain)
+			default:
+				r.Edges = append(r.Edges, item.edge)
+			}
+		}
+
+		if _, err := s.sendPost(resultsUrl, nil, r, nil); err != nil {
+			return fmt.Errorf("batch %d of %d: %w", idx+1, len(batches), err)
+		}
+	}
+	return nil
+}
@@ -65,6 +65,13 @@ type JobGraph struct {
 	CreatedAt  string          `json:"created"`
 }
 
+// JobDetail is the full contents of a job's graph as returned by GetJob
+type JobDetail struct {
+	JobGraph
+	Nodes []graph.NodeInterface `json:"nodes"`
+	Edges []graph.EdgeInterface `json:"edges"`
+}
+
 type D3View struct {
 	Pos   int      `json:"pos"`
 	Nodes []D3Node `json:"nodes"`
@@ -105,6 +112,76 @@ func (e *ServerError) Error() string {
 	return fmt.Sprintf("%d %s: %s", e.Code, e.Message, e.Reason)
 }
 
+func (jd *JobDetail) UnmarshalJSON(data []byte) error {
+	aux := &struct {
+		JobGraph
+		Nodes []json.RawMessage `json:"nodes"`
+		Edges []json.RawMessage `json:"edges"`
+	}{}
+
+	if err := json.Unmarshal(data, aux); err != nil {
+		return err
+	}
+
+	jd.JobGraph = aux.JobGraph
+	jd.Nodes = make([]graph.NodeInterface, 0, len(aux.Nodes))
+	for idx, raw := range aux.Nodes {
+		n, err := graph.JsonToNode(raw)
+		if err != nil {
+			return fmt.Errorf("failed to parse node at index %d: %w", idx, err)
+		}
+		jd.Nodes = append(jd.Nodes, n)
+	}
+
+	jd.Edges = make([]graph.EdgeInterface, 0, len(aux.Edges))
+	for idx, raw := range aux.Edges {
+		e, err := graph.JsonToEdge(raw)
+		if err != nil {
+			return fmt.Errorf("failed to parse edge at index %d: %w", idx, err)
+		}
+		jd.Edges = append(jd.Edges, e)
+	}
+
+	return nil
+}
+
+// Store indexes the job's nodes and edges in a graph.Store
+func (jd JobDetail) Store() (*graph.Store, error) {
+	s := graph.NewStore()
+	for _, n := range jd.Nodes {
+		s.AddNode(n)
+	}
+	for _, e := range jd.Edges {
+		if err := s.AddEdge(e); err != nil {
+			return nil, fmt.Errorf("failed to add edge %d: %w", e.GetID(), err)
+		}
+	}
+	return s, nil
+}
+
+// Store indexes the nodes and edges of the view in a graph.Store. D3 views only carry the core fields of each
+// element, so properties are not included.
+func (v D3View) Store() (*graph.Store, error) {
+	s := graph.NewStore()
+	for _, d3n := range v.Nodes {
+		n, err := graph.Node(d3n.Data.NodeMembers)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert node %d: %w", d3n.Data.ID, err)
+		}
+		s.AddNode(n)
+	}
+	for _, d3e := range v.Edges {
+		e, err := graph.Edge(d3e.Data.EdgeMembers)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert edge %d: %w", d3e.Data.ID, err)
+		}
+		if err := s.AddEdge(e); err != nil {
+			return nil, fmt.Errorf("failed to add edge %d: %w", d3e.Data.ID, err)
+		}
+	}
+	return s, nil
+}
+
 // Result types
 
 // ServerStatus result type
@@ -343,7 +420,13 @@ func (s *LGClient) Uptime() (float64, error) {
 
 // This function is used to poll for new adapter tasks
 // POST /lg/adapter/{adapter}
-func (s *LGClient) PollAdapter(a adapter.Adapter, p adapter.AdapterPollingOpts) (*http.Response, TaskMetadata, []TaskChain, error) {
+// PollOptFuncs (see shape.go) can be provided to check the returned chains against the task's query.
+func (s *LGClient) PollAdapter(a adapter.Adapter, p adapter.AdapterPollingOpts, opts ...PollOptFunc) (*http.Response, TaskMetadata, []TaskChain, error) {
+
+	pollOpts := PollOpts{}
+	for _, fn := range opts {
+		fn(&pollOpts)
+	}
 
 	adapterUrl := fmt.Sprintf("/lg/adapter/%s", a.Name)
 	var metadata TaskMetadata
@@ -407,6 +490,8 @@ func (s *LGClient) PollAdapter(a adapter.Adapter, p adapter.AdapterPollingOpts)
 		return resp, metadata, taskChains, err
 	}
 
+	err = s.checkShape(pollOpts, metadata, taskChains)
+
 	return resp, metadata, taskChains, err
 }
 
@@ -414,27 +499,84 @@ func (s *LGClient) PollAdapter(a adapter.Adapter, p adapter.AdapterPollingOpts)
 // POST /lg/task/{job_uuid}/{task_uuid}
 func (s *LGClient) PostTaskResults(jobId, taskId string, tResults task.TaskResults) error {
 
+	if err := validateTaskResults(tResults); err != nil {
+		return err
+	}
+
 	resultsUrl := fmt.Sprintf("/lg/task/%s/%s", jobId, taskId)
 	_, err := s.sendPost(resultsUrl, nil, tResults, nil)
 
 	return err
 }
 
+// validateTaskResults checks results before they are posted. The state transition must be one LemonGrenade allows,
+// and edges posted outside of a chain must carry their own endpoints.
+func validateTaskResults(tResults task.TaskResults) error {
+	if err := tResults.State.Validate(); err != nil {
+		return fmt.Errorf("invalid state %s: %w", tResults.State, err)
+	}
+	for idx, e := range tResults.Edges {
+		if err := graph.ValidateEdge(e); err != nil {
+			return fmt.Errorf("invalid edge at index %d: %w", idx, err)
+		}
+	}
+	return nil
+}
+
 func (s *LGClient) UpdateTaskStatus(jobId, taskId string, t task.TaskState) error {
 
 	tResults := task.PrepareTaskResults(task.WithStateSetTo(t))
+	if err := tResults.State.Validate(); err != nil {
+		return err
+	}
 	taskUrl := fmt.Sprintf("/lg/task/%s/%s", jobId, taskId)
 	_, err := s.sendPost(taskUrl, nil, tResults, nil)
 
 	return err
 }
 
-// This function is used to create new job
+// This function is used to list the tasks of a job
+// GET /lg/task/{job_uuid}
+func (s *LGClient) GetTasks(jobId string) ([]TaskMetadata, error) {
+
+	var tasks []TaskMetadata
+	_, err := s.sendGet(fmt.Sprintf("/lg/task/%s", jobId), nil, &tasks)
+
+	return tasks, err
+}
+
+// CreateJobOpts controls the checks CreateJob makes before sending a job
+type CreateJobOpts struct {
+	SkipValidation bool
+}
+
+type CreateJobOptFunc func(*CreateJobOpts)
+
+// WithoutJobValidation sends the job as it is, without calling job.Validate() first
+func WithoutJobValidation() CreateJobOptFunc {
+	return func(opts *CreateJobOpts) {
+		opts.SkipValidation = true
+	}
+}
+
+// This function is used to create new job. The job is checked with job.Validate() first, and a *job.ValidationError
+// returned without contacting the server if it is invalid, unless WithoutJobValidation is given.
 // POST /graph
-func (s *LGClient) CreateJob(j job.Job) (NewJobId, error) {
+func (s *LGClient) CreateJob(j job.Job, opts ...CreateJobOptFunc) (NewJobId, error) {
+
+	o := CreateJobOpts{}
+	for _, fn := range opts {
+		fn(&o)
+	}
 
 	newJob := NewJobId{}
 
+	if !o.SkipValidation {
+		if err := j.Validate(); err != nil {
+			return newJob, err
+		}
+	}
+
 	_, err := s.sendPost("/graph", nil, j, &newJob)
 	return newJob, err
 }
@@ -461,6 +603,16 @@ func (s *LGClient) GetJobStatus(uuid string) (JobGraph, error) {
 
 }
 
+// GET /graph/{uuid} ; get entire detail of a graph (including all edges and nodes)
+func (s *LGClient) GetJob(uuid string) (JobDetail, error) {
+
+	jobDetail := JobDetail{}
+
+	_, err := s.sendGet(fmt.Sprintf("/graph/%s", uuid), nil, &jobDetail)
+
+	return jobDetail, err
+}
+
 // DELETE /graph/{uuid} ; delete a graph
 func (s *LGClient) DeleteJob(uuid string) error {
 
@@ -469,6 +621,14 @@ func (s *LGClient) DeleteJob(uuid string) error {
 	return err
 }
 
+// PUT /reset/{uuid} ; reset the entire graph to whatever data was marked as seed
+func (s *LGClient) ResetJob(uuid string) error {
+
+	_, err := s.sendPut(fmt.Sprintf("/reset/%s", uuid), nil, nil, nil)
+
+	return err
+}
+
 // PUT /graph/{uuid}/meta ; merge in graph metadata
 func (s *LGClient) UpdateJobMetadata(uuid string, meta job.JobMetadata) error {
 
@@ -477,6 +637,15 @@ func (s *LGClient) UpdateJobMetadata(uuid string, meta job.JobMetadata) error {
 	return err
 }
 
+// PUT /graph/{uuid}/meta ; merge in only the given metadata keys. Unlike UpdateJobMetadata, zero values such as
+// "enabled": false or "priority": 0 are sent as given.
+func (s *LGClient) UpdateJobMetadataFields(uuid string, fields map[string]interface{}) error {
+
+	_, err := s.sendPut(fmt.Sprintf("/graph/%s/meta", uuid), nil, fields, nil)
+
+	return err
+}
+
 // GET /graph/{uuid}/meta ; get a graphs metadata
 func (s *LGClient) GetJobMetadata(uuid string) (job.JobMetadata, error) {
 
@@ -487,6 +656,17 @@ func (s *LGClient) GetJobMetadata(uuid string) (job.JobMetadata, error) {
 	return meta, err
 }
 
+// GET /graph/{uuid}/seeds ; list of payloads which were marked as seeds in metadata when posted
+// Each payload is read as a job.Job, so its nodes and chains are available however it was posted.
+func (s *LGClient) GetJobSeeds(uuid string) ([]job.Job, error) {
+
+	var seeds []job.Job
+
+	_, err := s.sendGet(fmt.Sprintf("/graph/%s/seeds", uuid), nil, &seeds)
+
+	return seeds, err
+}
+
 // GET /d3/{uuid} ; stream d3 json of a graph
 func (s *LGClient) GetJobD3View(uuid string) (D3View, error) {
 
@@ -520,24 +700,18 @@ func (s *LGClient) GetJobEdge(uuid string, id int) (graph.EdgeInterface, error)
 /// TODOS
 ///
 
-// TODO: GET /graph/{uuid} ; get entire detail of a graph (including all edges and nodes)
-
 // TODO: POST /graph/{uuid} ; merge data into an existing graph
 
 // TODO: PUT /graph/{uuid} ; upload a graph in binary format
 
 // TODO: PUT /graph/{uuid}/meta ; merge in graph metadata
 
-// TODO: GET /graph/{uuid}/seeds ; list of payloads which were marked as seeds in metadata when posted
-
 // TODO: GET /graph/{uuid}/node/{ID} ; get info about specifi node in a graph
 
 // TODO: PUT /graph/{uuid}/node/{ID} ; update info about specific node in a graph
 
 // TODO: PUT /graph/{uuid}/edge/{ID} ; update info about specific edge in a graph
 
-// TODO: PUT /reset/{uuid} ; reset the entire graph to whatever data was marked as seed
-
 // TODO: POST /graph/exec ; execute a python function against all graphs
 
 // TODO: POST /graph/{uuid}/exec ; execute a python function against a specific graph
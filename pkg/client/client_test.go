@@ -1,7 +1,16 @@
 package client
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -269,3 +278,595 @@ func Test_PollAdapter(t *testing.T) {
 	// TODO: job uuids respected
 
 }
+
+// newMockClient starts an httptest server for tests which should not depend on a running LemonGraph instance
+func newMockClient(t *testing.T, handler http.HandlerFunc) *LGClient {
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+
+	u, err := url.Parse(ts.URL)
+	assert.NoError(t, err)
+	port, err := strconv.Atoi(u.Port())
+	assert.NoError(t, err)
+
+	c, err := CreateClient(u.Hostname(), port, false)
+	assert.NoError(t, err)
+	return c
+}
+
+func Test_ValidateTaskChains(t *testing.T) {
+	chains := []TaskChain{
+		{{"type": "domain", "value": "a"}, {"type": "resolves", "src": map[string]interface{}{}, "tgt": map[string]interface{}{}}, {"type": "ip", "value": "b"}},
+		{{"type": "domain", "value": "a"}, {"type": "ip", "value": "b"}},
+		{{"type": "ip", "value": "a"}, {"type": "resolves", "src": map[string]interface{}{}, "tgt": map[string]interface{}{}}, {"type": "ip", "value": "b"}},
+	}
+
+	mismatches, err := ValidateTaskChains(`n(type="domain")->e()->n(type="ip")`, chains)
+	assert.NoError(t, err)
+	assert.Len(t, mismatches, 2)
+	assert.Equal(t, 1, mismatches[0].Index)
+	assert.Len(t, mismatches[0].Problems, 2) // length and alternation
+	assert.Equal(t, 2, mismatches[1].Index)
+	assert.Len(t, mismatches[1].Problems, 1) // type
+
+	_, err = ValidateTaskChains("n(", chains)
+	assert.Error(t, err)
+}
+
+func Test_PollAdapterShapeValidation(t *testing.T) {
+	var posted map[string]interface{}
+
+	c := newMockClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/lg/adapter/"):
+			w.Write([]byte(`[
+				{"task": "t1", "adapter": "SHAPE", "query": "n()->e()->n()", "length": 1, "uuid": "j1"},
+				[{"ID": 1, "type": "testtype", "value": "n1"}]
+			]`))
+		case r.Method == http.MethodPost && r.URL.Path == "/lg/task/j1/t1":
+			json.NewDecoder(r.Body).Decode(&posted)
+			w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	a := adapter.ConfigureAdapter("shape", adapter.WithQuery("n()->e()->n()"))
+
+	// no validation by default
+	_, _, chains, err := c.PollAdapter(*a, adapter.AdapterPollingOpts{})
+	assert.NoError(t, err)
+	assert.Len(t, chains, 1)
+
+	_, metadata, _, err := c.PollAdapter(*a, adapter.AdapterPollingOpts{}, WithShapeValidation())
+	var shapeErr *ShapeError
+	assert.True(t, errors.As(err, &shapeErr))
+	assert.Equal(t, "t1", metadata.Task)
+	assert.Len(t, shapeErr.Chains, 1)
+	assert.False(t, shapeErr.Errored)
+	assert.Nil(t, posted)
+
+	_, _, _, err = c.PollAdapter(*a, adapter.AdapterPollingOpts{}, WithMismatchedTasksErrored())
+	assert.True(t, errors.As(err, &shapeErr))
+	assert.True(t, shapeErr.Errored)
+	assert.Equal(t, string(task.TaskState_Errr), posted["state"])
+}
+
+func Test_Mirror(t *testing.T) {
+	c := newMockClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/graph/j1":
+			w.Write([]byte(`{
+				"graph": "j1", "id": "j1", "maxID": 3, "meta": {"priority": 10, "enabled": true},
+				"nodes": [{"ID": 1, "type": "domain", "value": "a.com"}, {"ID": 2, "type": "ip", "value": "1.1.1.1"}],
+				"edges": [{"ID": 3, "type": "resolves", "srcID": 1, "tgtID": 2}]
+			}`))
+		case "/lg/delta/j1":
+			assert.Equal(t, "3", r.URL.Query().Get("pos"))
+			w.Write([]byte(`[
+				{"id": "j1", "pos": 6, "nodes": 3, "edges": 2},
+				[1, {"ID": 4, "type": "ip", "value": "2.2.2.2"}],
+				[2, {"ID": 5}],
+				[0, {"priority": 20, "enabled": true}]
+			]`))
+		case "/graph/j1/edge/5":
+			w.Write([]byte(`{"ID": 5, "type": "resolves", "srcID": 1, "tgtID": 4}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	m := NewMirror(c, "j1")
+	events, unsubscribe := m.Subscribe(10)
+
+	assert.NoError(t, m.Bootstrap())
+	before := m.Snapshot()
+	nodes, edges := before.Len()
+	assert.Equal(t, 2, nodes)
+	assert.Equal(t, 1, edges)
+	assert.EqualValues(t, 3, m.Pos())
+	assert.EqualValues(t, 10, m.Meta().Priority)
+
+	assert.NoError(t, m.Sync(context.Background()))
+	assert.EqualValues(t, 6, m.Pos())
+	assert.EqualValues(t, 20, m.Meta().Priority)
+
+	after := m.Snapshot()
+	nodes, edges = after.Len()
+	assert.Equal(t, 3, nodes)
+	assert.Equal(t, 2, edges)
+	assert.Equal(t, 2, after.Degree(graph.NodeKey{Type: "domain", Value: "a.com"}, graph.Outgoing))
+
+	// earlier snapshots are unaffected
+	nodes, _ = before.Len()
+	assert.Equal(t, 2, nodes)
+
+	unsubscribe()
+	var kinds []MirrorEventKind
+	var positions []int64
+	for ev := range events {
+		kinds = append(kinds, ev.Kind)
+		positions = append(positions, ev.Pos)
+	}
+	assert.Equal(t, []MirrorEventKind{MirrorReset, MirrorNode, MirrorEdge, MirrorMetadata}, kinds)
+	assert.Equal(t, []int64{3, 6, 6, 6}, positions)
+
+	// resuming from a saved position skips the full fetch
+	resumed := NewMirror(c, "j1", WithMirrorPosition(3), WithMirrorStore(before))
+	assert.NoError(t, resumed.Sync(context.Background()))
+	nodes, edges = resumed.Snapshot().Len()
+	assert.Equal(t, 3, nodes)
+	assert.Equal(t, 2, edges)
+
+	// an edge that cannot be applied stops the sync, and the pos stays put so the next sync fetches it again
+	broken := newMockClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/lg/delta/j1":
+			w.Write([]byte(`[
+				{"id": "j1", "pos": 6, "nodes": 3, "edges": 2},
+				[1, {"ID": 4, "type": "ip", "value": "2.2.2.2"}],
+				[2, {"ID": 5}],
+				[0, {"priority": 20, "enabled": true}]
+			]`))
+		case "/graph/j1/edge/5":
+			w.Write([]byte(`{"ID": 5, "type": "resolves", "tgtID": 4}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	partial := NewMirror(broken, "j1", WithMirrorPosition(3), WithMirrorStore(before))
+	assert.ErrorContains(t, partial.Sync(context.Background()), "failed to apply edge 5")
+	assert.EqualValues(t, 3, partial.Pos())
+	assert.EqualValues(t, 0, partial.Meta().Priority)
+}
+
+func Test_D3ViewStore(t *testing.T) {
+	c := newMockClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/d3/j1", r.URL.Path)
+		w.Write([]byte(`{
+			"pos": 3,
+			"nodes": [
+				{"data": {"ID": 1, "type": "domain", "value": "a.com", "PID": 1}},
+				{"data": {"ID": 2, "type": "ip", "value": "1.1.1.1", "PID": 1}}
+			],
+			"edges": [{"data": {"ID": 3, "type": "resolves", "srcID": "1", "tgtID": "2", "PID": 1}}]
+		}`))
+	})
+
+	view, err := c.GetJobD3View("j1")
+	assert.NoError(t, err)
+	s, err := view.Store()
+	assert.NoError(t, err)
+
+	nodes, edges := s.Len()
+	assert.Equal(t, 2, nodes)
+	assert.Equal(t, 1, edges)
+	assert.Equal(t, 1, s.Degree(graph.NodeKey{Type: "domain", Value: "a.com"}, graph.Outgoing))
+	assert.Equal(t, 1, s.Degree(graph.NodeKey{Type: "ip", Value: "1.1.1.1"}, graph.Incoming))
+
+	// an edge with no way to find its endpoints is reported
+	view.Edges = append(view.Edges, D3Edge{Data: D3EdgeData{EdgeMembers: graph.EdgeMembers{ID: 4, Type: "resolves"}}})
+	_, err = view.Store()
+	assert.ErrorContains(t, err, "edge 4")
+}
+
+func Test_CreateJobValidation(t *testing.T) {
+	posts := 0
+	c := newMockClient(t, func(w http.ResponseWriter, r *http.Request) {
+		posts++
+		w.Write([]byte(`{"uuid": "j1"}`))
+	})
+
+	dup := permissions.User{Name: "dup"}
+	bad := job.NewJob(job.WithRoles(dup, dup))
+
+	_, err := c.CreateJob(*bad)
+	var verr *job.ValidationError
+	assert.True(t, errors.As(err, &verr))
+	assert.Equal(t, 0, posts)
+
+	_, err = c.CreateJob(*bad, WithoutJobValidation())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, posts)
+}
+
+func Test_WaitForJob(t *testing.T) {
+	checks := 0
+	c := newMockClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/lg/config/j1":
+			checks++
+			if checks == 1 {
+				w.Write([]byte(`{"DNS": {"n()": {"pos": 2, "tasks": 1, "active": true, "enabled": true}}}`))
+				return
+			}
+			w.Write([]byte(`{"DNS": {"n()": {"pos": 5, "enabled": true}}, "WHOIS": {"n()": {"pos": 1}}}`))
+		case "/graph/j1/status":
+			if checks == 1 {
+				w.Write([]byte(`{"id": "j1", "maxID": 2, "nodes_count": 2, "edges_count": 0}`))
+				return
+			}
+			w.Write([]byte(`{"id": "j1", "maxID": 4, "nodes_count": 3, "edges_count": 1}`))
+		case "/lg/delta/j1":
+			w.Write([]byte(`[{"id": "j1", "pos": 4, "nodes": 3, "edges": 1}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	var events []JobProgress
+	p, err := c.WaitForJob(context.Background(), "j1",
+		WithWaitBackoff(time.Millisecond, 5*time.Millisecond),
+		WithProgress(func(p JobProgress) { events = append(events, p) }),
+	)
+	assert.NoError(t, err)
+	assert.True(t, p.Done)
+	assert.Equal(t, 3, p.Checks)
+	assert.Equal(t, 3, p.Nodes)
+	assert.Len(t, events, 3)
+
+	first := events[0]
+	assert.False(t, first.Idle)
+	assert.Equal(t, 1, first.Tasks)
+	assert.Equal(t, []QueryProgress{{Adapter: "DNS", Query: "n()", Pos: 2, Behind: 1, Tasks: 1, Active: true, Enabled: true}}, first.Queries)
+
+	// the disabled WHOIS query is behind but idle
+	assert.True(t, events[1].Idle)
+	assert.False(t, events[1].Done)
+	assert.Equal(t, "WHOIS", p.Queries[1].Adapter)
+	assert.Equal(t, 4, p.Queries[1].Behind)
+
+	// an empty delta confirms an idle job without a second check
+	checks = 0
+	events = nil
+	p, err = c.WaitForJob(context.Background(), "j1", WithWaitBackoff(time.Millisecond, 5*time.Millisecond), WithDeltaStream())
+	assert.NoError(t, err)
+	assert.True(t, p.Done)
+	assert.Equal(t, 2, p.Checks)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = c.WaitForJob(ctx, "j1")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func Test_BulkJobs(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	c := newMockClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/graph" {
+			w.Write([]byte(`[
+				{"graph": "j1", "size": 100, "created": "2024-01-01T00:00:00Z", "meta": {"priority": 10, "enabled": true, "roles": {"alice": {"reader": true}}}},
+				{"graph": "j2", "size": 5000, "created": "2024-06-01T00:00:00Z", "meta": {"priority": 50, "enabled": false}},
+				{"graph": "j3", "size": 200, "created": "2023-01-01T00:00:00Z", "meta": {"priority": 10, "enabled": true}}
+			]`))
+			return
+		}
+
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		if r.URL.Path == "/graph/j3" {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"code": 500, "message": "boom"}`))
+			return
+		}
+		if r.Method == http.MethodPut {
+			var meta map[string]interface{}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&meta))
+			assert.Equal(t, map[string]interface{}{"enabled": false}, meta)
+		}
+		w.Write([]byte(`{}`))
+	})
+
+	old := JobsCreatedBefore(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	jobs, err := c.SelectJobs(MatchAll(old, JobsWithSize(0, 1000)))
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 2)
+
+	jobs, _ = c.SelectJobs(MatchAll(JobsWithRole("alice"), JobsWithMeta("priority", "10")))
+	assert.Len(t, jobs, 1)
+	revoked := JobGraph{Meta: job.JobMetadata{Roles: []permissions.User{{Name: "bob"}}}}
+	assert.False(t, JobsWithRole("bob")(revoked))
+	jobs, _ = c.SelectJobs(JobsVisibleTo(permissions.Policy{Unrestricted: permissions.ReadOnly}, "alice"))
+	assert.Len(t, jobs, 3)
+	jobs, _ = c.SelectJobs(JobsVisibleTo(permissions.Policy{}, "bob"))
+	assert.Len(t, jobs, 0)
+	jobs, _ = c.SelectJobs(MatchAll(JobsEnabled(false), JobsWithPriority(20, 255)))
+	assert.Len(t, jobs, 1)
+	assert.Equal(t, "j2", jobs[0].GraphID)
+
+	report, err := c.BulkJobs(context.Background(), DeleteJobs(), old, WithDryRun())
+	assert.NoError(t, err)
+	assert.Len(t, report, 2)
+	assert.True(t, report[0].DryRun)
+	assert.Empty(t, requests)
+
+	report, err = c.BulkJobs(context.Background(), DeleteJobs(), old, WithConcurrency(2))
+	assert.NoError(t, err)
+	assert.NoError(t, report[0].Err)
+	assert.Error(t, report[1].Err)
+	assert.ErrorContains(t, report.Err(), "j3")
+	assert.ElementsMatch(t, []string{"DELETE /graph/j1", "DELETE /graph/j3"}, requests)
+
+	requests = nil
+	report, err = c.BulkJobs(context.Background(), DisableJobs(), JobsEnabled(true), WithConcurrency(1))
+	assert.NoError(t, err)
+	assert.Len(t, report, 2)
+	assert.Equal(t, []string{"PUT /graph/j1/meta", "PUT /graph/j3/meta"}, requests)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	report, err = c.BulkJobs(ctx, ResetJobs(), nil)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.True(t, report[0].Skipped)
+}
+
+func Test_CloneJob(t *testing.T) {
+	var created map[string]interface{}
+	c := newMockClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/graph/j1/meta":
+			w.Write([]byte(`{"priority": 10, "enabled": true, "roles": {"alice": {"reader": true, "writer": false}}, "case": "CASE-1"}`))
+		case "/lg/config/j1":
+			w.Write([]byte(`{
+				"DNS": {"n(type=\"domain\")": {"pos": 12, "limit": 50, "timeout": 30, "enabled": true, "autotask": true}},
+				"WHOIS": {"n(type=\"domain\")": {"pos": 3, "enabled": false}, "n(type=\"ip\")": {"pos": 4, "limit": 5, "enabled": true}}
+			}`))
+		case "/graph/j1/seeds":
+			w.Write([]byte(`[
+				{"seed": true, "nodes": [{"type": "domain", "value": "a.com"}]},
+				{"seed": true, "chains": [[{"type": "domain", "value": "b.com"}, {"type": "resolves"}, {"type": "ip", "value": "1.1.1.1"}]]}
+			]`))
+		case "/graph":
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&created))
+			w.Write([]byte(`{"uuid": "j2"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	template, err := c.TemplateFromJob("j1")
+	assert.NoError(t, err)
+	assert.True(t, template.Seed)
+	assert.EqualValues(t, 10, template.Meta.Priority)
+	caseID, ok := template.Meta.GetString("case")
+	assert.True(t, ok)
+	assert.Equal(t, "CASE-1", caseID)
+	assert.Len(t, template.Nodes, 1)
+	assert.Len(t, template.Chains, 1)
+	dns := template.Adapters["DNS"][0]
+	assert.Equal(t, `n(type="domain")`, dns.Query)
+	assert.EqualValues(t, 50, dns.Limit)
+	assert.Equal(t, 30, dns.Timeout)
+	assert.True(t, dns.Autotask)
+	assert.Zero(t, dns.Position)
+	assert.Len(t, template.Adapters["WHOIS"], 2)
+	assert.False(t, template.Adapters["WHOIS"][0].Enabled)
+	assert.EqualValues(t, 5, template.Adapters["WHOIS"][1].Limit)
+
+	// a saved template reads back as a job spec
+	data, err := json.Marshal(template)
+	assert.NoError(t, err)
+	loaded, err := job.ParseSpec(data)
+	assert.NoError(t, err)
+	assert.Equal(t, template.Adapters, loaded.Adapters)
+
+	n, _ := graph.JsonToNode([]byte(`{"type": "domain", "value": "c.com"}`))
+	id, err := c.CloneJob("j1", job.WithNodes(n), job.WithChains(), job.WithPriority(200))
+	assert.NoError(t, err)
+	assert.Equal(t, "j2", id.UUID)
+	assert.EqualValues(t, 200, created["meta"].(map[string]interface{})["priority"])
+	assert.Contains(t, created["meta"].(map[string]interface{})["roles"], "alice")
+	assert.Equal(t, "CASE-1", created["meta"].(map[string]interface{})["case"])
+	assert.Len(t, created["nodes"], 1)
+	assert.NotContains(t, created, "chains")
+	assert.Contains(t, created["adapters"], "WHOIS")
+}
+
+func Test_JobRoles(t *testing.T) {
+	var updates []map[string]map[string]permissions.Permissions
+	c := newMockClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/graph/j1/meta", r.URL.Path)
+		if r.Method == http.MethodPut {
+			var update map[string]map[string]permissions.Permissions
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&update))
+			updates = append(updates, update)
+			return
+		}
+		w.Write([]byte(`{"priority": 10, "enabled": true, "roles": {"bob": {"reader": true, "writer": true}, "alice": {"reader": true, "writer": false}}}`))
+	})
+
+	roles, err := c.GetJobRoles("j1")
+	assert.NoError(t, err)
+	assert.Equal(t, []permissions.User{
+		{Name: "alice", Permissions: permissions.Permissions{Reader: true}},
+		{Name: "bob", Permissions: permissions.Permissions{Reader: true, Writer: true}},
+	}, roles)
+
+	meta, _ := c.GetJobMetadata("j1")
+	assert.True(t, meta.CanRead("alice"))
+	assert.False(t, meta.CanWrite("alice"))
+	assert.False(t, meta.CanRead("carol"))
+
+	assert.NoError(t, c.GrantJobRole("j1", "alice", permissions.Permissions{Writer: true}))
+	assert.NoError(t, c.GrantJobRole("j1", "carol", permissions.Permissions{Reader: true}))
+	assert.NoError(t, c.RevokeJobRole("j1", "bob", permissions.Permissions{Reader: true, Writer: true}))
+	assert.Error(t, c.GrantJobRole("j1", "", permissions.Permissions{Reader: true}))
+
+	// only roles are sent, and always every one of them
+	assert.Len(t, updates, 3)
+	assert.Equal(t, map[string]map[string]permissions.Permissions{"roles": {
+		"alice": {Reader: true, Writer: true},
+		"bob":   {Reader: true, Writer: true},
+	}}, updates[0])
+	assert.Equal(t, permissions.Permissions{Reader: true}, updates[1]["roles"]["carol"])
+	assert.Equal(t, permissions.Permissions{}, updates[2]["roles"]["bob"])
+}
+
+func Test_Outbox(t *testing.T) {
+	var mu sync.Mutex
+	status := http.StatusServiceUnavailable
+	var posted []string
+	c := newMockClient(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		posted = append(posted, r.URL.Path+" "+body["details"].(string))
+		w.WriteHeader(status)
+		w.Write([]byte(`{}`))
+	})
+	setStatus := func(code int) {
+		mu.Lock()
+		defer mu.Unlock()
+		status = code
+	}
+
+	path := t.TempDir() + "/outbox.jsonl"
+	ob, err := OpenOutbox(c, path)
+	assert.NoError(t, err)
+
+	// the server is down: the results are kept, and posting the same task again replaces them
+	assert.NoError(t, ob.Post("j1", "t1", *task.PrepareTaskResults(task.WithDetails("first"))))
+	assert.NoError(t, ob.Post("j1", "t1", *task.PrepareTaskResults(task.WithDetails("second"))))
+	assert.NoError(t, ob.Post("j1", "t2", *task.PrepareTaskResults(task.WithDetails("other"))))
+	pending := ob.Pending()
+	assert.Len(t, pending, 2)
+	assert.Equal(t, 1, pending[0].Attempts)
+	assert.Contains(t, string(pending[0].Results), "second")
+	assert.NoError(t, ob.Close())
+
+	// the results survive a restart, even with a partly written record at the end of the file
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	assert.NoError(t, err)
+	f.Write([]byte(`{"op":"put","seq":9,"entry":{"job":"j1"`))
+	f.Close()
+	entries, err := ReadOutbox(path)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	ob, err = OpenOutbox(c, path)
+	assert.NoError(t, err)
+	defer ob.Close()
+	assert.Len(t, ob.Pending(), 2)
+
+	// only one outbox can have the file open at a time
+	_, err = OpenOutbox(c, path)
+	assert.ErrorIs(t, err, ErrOutboxLocked)
+
+	setStatus(http.StatusOK)
+	sent, err := ob.Flush(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, sent)
+	assert.Empty(t, ob.Pending())
+	assert.Equal(t, "/lg/task/j1/t1 second", posted[len(posted)-2])
+	assert.Equal(t, "/lg/task/j1/t2 other", posted[len(posted)-1])
+
+	// refused results are kept for inspection but not retried
+	setStatus(http.StatusBadRequest)
+	assert.NoError(t, ob.Post("j2", "t1", *task.PrepareTaskResults(task.WithDetails("bad"))))
+	pending = ob.Pending()
+	assert.Len(t, pending, 1)
+	assert.True(t, pending[0].Rejected)
+	sent, err = ob.Flush(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, sent)
+
+	purged, err := ob.Purge("j2", "")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	entries, err = ReadOutbox(path)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	// invalid results are rejected before they are saved
+	dangling, _ := graph.Edge(TestEdge{EdgeMembers: graph.EdgeMembers{Type: "testedge"}})
+	err = ob.Post("j1", "t3", *task.PrepareTaskResults(task.WithEdges(dangling)))
+	assert.Error(t, err)
+	assert.Empty(t, ob.Pending())
+}
+
+func Test_PostTaskResultsChunked(t *testing.T) {
+	var bodies []map[string]interface{}
+	c := newMockClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/lg/task/j1/t1", r.URL.Path)
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		bodies = append(bodies, body)
+		w.Write([]byte(`{}`))
+	})
+
+	var nodes []graph.NodeInterface
+	for i := 0; i < 25; i++ {
+		n, _ := graph.Node(TestType{NodeMembers: graph.NodeMembers{Type: "testtype", Value: strconv.Itoa(i)}})
+		nodes = append(nodes, n)
+	}
+	dns := adapter.ConfigureAdapter("dns", adapter.WithQuery(`n(type="domain")`))
+	chain, err := graph.CreateChain(n1, e1, n2)
+	assert.NoError(t, err)
+	results := task.PrepareTaskResults(task.WithNodes(nodes...), task.WithChains(chain), task.WithDetails("walked"), task.WithAdapters(*dns))
+
+	err = c.PostTaskResultsChunked(context.Background(), "j1", "t1", *results, WithChunkItems(10))
+	assert.NoError(t, err)
+	assert.Len(t, bodies, 3)
+	total := 0
+	for idx, body := range bodies {
+		nodes, _ := body["nodes"].([]interface{})
+		total += len(nodes)
+		if idx < 2 {
+			// earlier batches leave an active or idle task as it is
+			assert.Equal(t, []interface{}{"active", "idle"}, body["state"])
+			assert.NotContains(t, body, "details")
+		}
+	}
+	assert.Equal(t, 25, total)
+	assert.Contains(t, bodies[0], "adapters")
+	assert.NotContains(t, bodies[2], "adapters")
+	assert.NotContains(t, bodies[2], "state")
+	assert.Equal(t, "walked", bodies[2]["details"])
+	assert.Len(t, bodies[2]["chains"], 1)
+
+	// batches are also bounded in bytes, and the guard follows the final transition
+	bodies = nil
+	results = task.PrepareTaskResults(task.WithNodes(nodes...),
+		task.WithStateSetMatch(map[task.TaskState]task.TaskState{task.TaskState_Retry: task.TaskState_Done}))
+	err = c.PostTaskResultsChunked(context.Background(), "j1", "t1", *results, WithChunkBytes(200))
+	assert.NoError(t, err)
+	assert.Greater(t, len(bodies), 3)
+	assert.Equal(t, []interface{}{"retry"}, bodies[0]["state"])
+	assert.Equal(t, map[string]interface{}{"retry": "done"}, bodies[len(bodies)-1]["state"])
+
+	// results with nothing to split are posted once
+	bodies = nil
+	err = c.PostTaskResultsChunked(context.Background(), "j1", "t1", *task.PrepareTaskResults(task.WithStateSetTo(task.TaskState_Errr)))
+	assert.NoError(t, err)
+	assert.Len(t, bodies, 1)
+	assert.Equal(t, "error", bodies[0]["state"])
+
+	// transitions LemonGrenade does not allow are not sent
+	bodies = nil
+	err = c.PostTaskResults("j1", "t1", *task.PrepareTaskResults(task.WithStateSetTo(task.TaskState_Active)))
+	assert.ErrorContains(t, err, "use retry")
+	assert.Empty(t, bodies)
+}
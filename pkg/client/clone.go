@@ -0,0 +1,88 @@
+package client
+
+import (
+	"sort"
+
+	"github.com/skyleronken/lemonclient/pkg/adapter"
+	"github.com/skyleronken/lemonclient/pkg/graph"
+	"github.com/skyleronken/lemonclient/pkg/job"
+)
+
+// TemplateFromJob reads an existing job's metadata (including its extra keys), adapter configuration and seeds and
+// returns them as a job which can be passed to job.NewJobFrom to create new jobs like it. Queries start from the
+// beginning of the new job, so positions are not copied, and the job's seeds are marked as seeds again so the new
+// job can be reset.
+//
+// A template marshals to a job spec, including adapters watching several queries, so it can be saved to a file and
+// read back with job.LoadSpecFile.
+func (s *LGClient) TemplateFromJob(uuid string) (*job.Job, error) {
+	meta, err := s.GetJobMetadata(uuid)
+	if err != nil {
+		return nil, err
+	}
+	config, err := s.GetJobConfig(uuid)
+	if err != nil {
+		return nil, err
+	}
+	seeds, err := s.GetJobSeeds(uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []job.OptFunc{
+		job.WithPriority(meta.Priority),
+		job.WithEnabled(meta.Enabled),
+		job.WithRoles(meta.Roles...),
+		job.WithSeed(true),
+		job.WithAdapters(adaptersFromConfig(config)...),
+	}
+	for _, key := range meta.Keys() {
+		value, _ := meta.Get(key)
+		opts = append(opts, job.WithMeta(key, value))
+	}
+
+	var nodes []graph.NodeInterface
+	var chains []graph.ChainInterface
+	for _, seed := range seeds {
+		nodes = append(nodes, seed.Nodes...)
+		chains = append(chains, seed.Chains...)
+	}
+	opts = append(opts, job.WithNodes(nodes...), job.WithChains(chains...))
+
+	return job.NewJob(opts...), nil
+}
+
+// adaptersFromConfig converts the queries in a job's config back into adapters, ordered by name then query
+func adaptersFromConfig(config job.JobConfig) []adapter.Adapter {
+	var adapters []adapter.Adapter
+	for name, queries := range config {
+		for q, qc := range queries {
+			adapters = append(adapters, *adapter.ConfigureAdapter(name,
+				adapter.WithQuery(q),
+				adapter.WithLimit(uint64(qc.Limit)),
+				adapter.WithTimeout(int(qc.Timeout)),
+				adapter.WithAutotask(qc.AutoTask),
+				adapter.WithEnabled(qc.Enabled),
+			))
+		}
+	}
+	sort.Slice(adapters, func(i, j int) bool {
+		if adapters[i].Name != adapters[j].Name {
+			return adapters[i].Name < adapters[j].Name
+		}
+		return adapters[i].Query < adapters[j].Query
+	})
+	return adapters
+}
+
+// CloneJob creates a new job with the metadata, adapters and seeds of an existing one (see TemplateFromJob), with
+// overrides applied on top. For example, to rerun a job on new seeds at a higher priority:
+//
+//	server.CloneJob(uuid, job.WithChains(chains...), job.WithPriority(200))
+func (s *LGClient) CloneJob(uuid string, overrides ...job.OptFunc) (NewJobId, error) {
+	template, err := s.TemplateFromJob(uuid)
+	if err != nil {
+		return NewJobId{}, err
+	}
+	return s.CreateJob(*job.NewJobFrom(*template, overrides...))
+}
@@ -2,6 +2,7 @@ package client
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -78,13 +79,19 @@ func RegisterEdgeType(nodeType string, factory func() graph.EdgeInterface) {
 
 // StreamDelta streams graph updates for the given UUID
 func (c *LGClient) StreamDelta(graphUUID string, params *DeltaParams, callback UpdateCallback) error {
-	req, err := c.sling.New().
+	return c.StreamDeltaContext(context.Background(), graphUUID, params, callback)
+}
+
+// StreamDeltaContext is StreamDelta with a context which can be used to abandon the stream
+func (c *LGClient) StreamDeltaContext(ctx context.Context, graphUUID string, params *DeltaParams, callback UpdateCallback) error {
+	req, err := c.newRequest().
 		Get(fmt.Sprintf("/lg/delta/%s", graphUUID)).
 		QueryStruct(params).
 		Request()
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
+	req = req.WithContext(ctx)
 
 	// Add tag parameters manually
 	q := req.URL.Query()
@@ -0,0 +1,299 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/skyleronken/lemonclient/pkg/graph"
+	"github.com/skyleronken/lemonclient/pkg/job"
+)
+
+// MirrorEventKind identifies what changed in a Mirror
+type MirrorEventKind int
+
+const (
+	MirrorReset    MirrorEventKind = iota // the mirror was (re)loaded from a full fetch
+	MirrorNode                            // a node was added or updated
+	MirrorEdge                            // an edge was added or updated
+	MirrorMetadata                        // the job metadata changed
+)
+
+// MirrorEvent is sent to subscribers after each change has been applied
+type MirrorEvent struct {
+	Kind MirrorEventKind
+	Pos  int64
+	Node graph.NodeInterface
+	Edge graph.EdgeInterface
+	Meta *job.JobMetadata
+	Tags []string
+}
+
+// MirrorOpts configures a Mirror
+type MirrorOpts struct {
+	Position     *int64        // resume the delta stream from this pos rather than doing a full fetch
+	PollInterval time.Duration // how long to wait before reconnecting once the delta stream ends
+	Tags         map[string][]string
+	Store        *graph.Store // previously saved contents to resume from
+}
+
+type MirrorOptFunc func(*MirrorOpts)
+
+func defaultMirrorOpts() MirrorOpts {
+	return MirrorOpts{
+		PollInterval: 2 * time.Second,
+	}
+}
+
+// WithMirrorPosition resumes a mirror from a previously saved pos instead of bootstrapping with a full fetch. The
+// store should be provided using WithMirrorStore, or the mirror will only contain changes made after pos.
+func WithMirrorPosition(pos int64) MirrorOptFunc {
+	return func(opts *MirrorOpts) {
+		opts.Position = &pos
+	}
+}
+
+// WithMirrorStore seeds the mirror with a previously saved copy of the graph, such as an earlier Snapshot()
+func WithMirrorStore(store *graph.Store) MirrorOptFunc {
+	return func(opts *MirrorOpts) {
+		opts.Store = store
+	}
+}
+
+func WithMirrorPollInterval(interval time.Duration) MirrorOptFunc {
+	return func(opts *MirrorOpts) {
+		opts.PollInterval = interval
+	}
+}
+
+// WithMirrorTags passes tag queries to the delta stream. Matching tags are reported on each MirrorEvent.
+func WithMirrorTags(tags map[string][]string) MirrorOptFunc {
+	return func(opts *MirrorOpts) {
+		opts.Tags = tags
+	}
+}
+
+// Mirror keeps a local copy of a job's graph up to date. It bootstraps from a full fetch (GetJob) and then applies
+// updates from the delta stream as they arrive. Readers get consistent point in time copies using Snapshot().
+type Mirror struct {
+	client *LGClient
+	uuid   string
+	opts   MirrorOpts
+
+	mu    sync.RWMutex
+	store *graph.Store
+	meta  job.JobMetadata
+	pos   int64
+	ready bool
+
+	subMu       sync.Mutex
+	subscribers map[int]chan MirrorEvent
+	nextSub     int
+}
+
+// NewMirror creates a mirror of the given job. Nothing is fetched until Bootstrap or Run is called.
+func NewMirror(c *LGClient, uuid string, opts ...MirrorOptFunc) *Mirror {
+	o := defaultMirrorOpts()
+	for _, fn := range opts {
+		fn(&o)
+	}
+
+	m := &Mirror{
+		client:      c,
+		uuid:        uuid,
+		opts:        o,
+		store:       graph.NewStore(),
+		subscribers: map[int]chan MirrorEvent{},
+	}
+
+	if o.Store != nil {
+		m.store = o.Store.Clone()
+	}
+
+	if o.Position != nil {
+		m.pos = *o.Position
+		m.ready = true
+	}
+
+	return m
+}
+
+// Bootstrap replaces the contents of the mirror with a full fetch of the job
+func (m *Mirror) Bootstrap() error {
+	detail, err := m.client.GetJob(m.uuid)
+	if err != nil {
+		return fmt.Errorf("failed to fetch job %s: %w", m.uuid, err)
+	}
+
+	store, err := detail.Store()
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.store = store
+	m.meta = detail.Meta
+	m.pos = int64(detail.MaxID)
+	m.ready = true
+	pos := m.pos
+	m.mu.Unlock()
+
+	m.publish(MirrorEvent{Kind: MirrorReset, Pos: pos})
+	return nil
+}
+
+// Sync applies every update available on the delta stream since the last applied pos and returns once the stream
+// ends. The mirror is bootstrapped first if needed. Updates after one that could not be read or applied are skipped
+// and the pos is left where it was, so the next Sync fetches them again.
+func (m *Mirror) Sync(ctx context.Context) error {
+	m.mu.RLock()
+	ready := m.ready
+	m.mu.RUnlock()
+
+	if !ready {
+		if err := m.Bootstrap(); err != nil {
+			return err
+		}
+	}
+
+	pos := m.Pos()
+	params := &DeltaParams{Position: &pos, Tags: m.opts.Tags}
+
+	var syncErr error
+	var last *DeltaHeader
+	err := m.client.StreamDeltaContext(ctx, m.uuid, params, func(header *DeltaHeader, flags int64, data interface{}, err error) {
+		last = header
+		if syncErr != nil {
+			return
+		}
+		if err != nil {
+			syncErr = err
+			return
+		}
+		if data == nil {
+			return
+		}
+		syncErr = m.apply(header, flags, data)
+	})
+	if err != nil {
+		return err
+	}
+	if syncErr != nil {
+		return syncErr
+	}
+
+	// every update up to the header's pos has now been applied
+	if last != nil {
+		m.mu.Lock()
+		if last.Pos > m.pos {
+			m.pos = last.Pos
+		}
+		m.mu.Unlock()
+	}
+
+	return nil
+}
+
+// Run keeps the mirror up to date until the context is cancelled, reconnecting to the delta stream every
+// PollInterval. Errors from individual syncs are passed to onError (if not nil) and do not stop the mirror.
+func (m *Mirror) Run(ctx context.Context, onError func(error)) error {
+	for {
+		if err := m.Sync(ctx); err != nil && ctx.Err() == nil && onError != nil {
+			onError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(m.opts.PollInterval):
+		}
+	}
+}
+
+func (m *Mirror) apply(header *DeltaHeader, flags int64, data interface{}) error {
+	event := MirrorEvent{Pos: header.Pos, Tags: GetTags(flags, header)}
+
+	m.mu.Lock()
+	switch v := data.(type) {
+	case job.JobMetadata:
+		m.meta = v
+		meta := v
+		event.Kind = MirrorMetadata
+		event.Meta = &meta
+	case graph.EdgeInterface:
+		// edges satisfy NodeInterface too, so they must be matched first
+		if err := m.store.AddEdge(v); err != nil {
+			m.mu.Unlock()
+			return fmt.Errorf("failed to apply edge %d: %w", v.GetID(), err)
+		}
+		event.Kind = MirrorEdge
+		event.Edge = v
+	case graph.NodeInterface:
+		m.store.AddNode(v)
+		event.Kind = MirrorNode
+		event.Node = v
+	default:
+		m.mu.Unlock()
+		return nil
+	}
+	m.mu.Unlock()
+
+	m.publish(event)
+	return nil
+}
+
+// Snapshot returns a copy of the mirrored graph which is not affected by later updates
+func (m *Mirror) Snapshot() *graph.Store {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.store.Clone()
+}
+
+// Meta returns the last known job metadata
+func (m *Mirror) Meta() job.JobMetadata {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.meta
+}
+
+// Pos returns the last applied delta position, which can be saved and passed to WithMirrorPosition to resume
+func (m *Mirror) Pos() int64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.pos
+}
+
+// Subscribe returns a channel of change notifications and a function to stop receiving them. Events are dropped for
+// subscribers whose buffer is full, so slow readers should re-read Snapshot() rather than rely on every event.
+func (m *Mirror) Subscribe(buffer int) (<-chan MirrorEvent, func()) {
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+
+	id := m.nextSub
+	m.nextSub++
+	ch := make(chan MirrorEvent, buffer)
+	m.subscribers[id] = ch
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			m.subMu.Lock()
+			defer m.subMu.Unlock()
+			delete(m.subscribers, id)
+			close(ch)
+		})
+	}
+}
+
+func (m *Mirror) publish(event MirrorEvent) {
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+
+	for _, ch := range m.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
@@ -0,0 +1,440 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/skyleronken/lemonclient/pkg/task"
+)
+
+// OutboxEntry is a task's results held in an Outbox until the server acknowledges them
+type OutboxEntry struct {
+	Job      string          `json:"job"`
+	Task     string          `json:"task"`
+	Results  json.RawMessage `json:"results"` // the body of the POST, exactly as it is sent
+	Queued   time.Time       `json:"queued"`
+	Attempts int             `json:"attempts"`
+	LastErr  string          `json:"last_error,omitempty"`
+	Rejected bool            `json:"rejected,omitempty"` // the server refused the results, so they are not retried
+	seq      uint64
+}
+
+// outboxRecord is one line of an outbox file. A put adds or replaces the entry for a job/task, an attempt records a
+// failed send, and done removes the entry once it has been sent or purged. Attempt and done records carry the seq of
+// the put they apply to, so they never touch newer results for the same task.
+type outboxRecord struct {
+	Op       string       `json:"op"`
+	Seq      uint64       `json:"seq"`
+	Entry    *OutboxEntry `json:"entry,omitempty"`
+	Job      string       `json:"job,omitempty"`
+	Task     string       `json:"task,omitempty"`
+	Err      string       `json:"error,omitempty"`
+	Rejected bool         `json:"rejected,omitempty"`
+}
+
+const (
+	outboxPut     = "put"
+	outboxAttempt = "attempt"
+	outboxDone    = "done"
+)
+
+// OutboxOpts configures an Outbox
+type OutboxOpts struct {
+	MinRetry time.Duration // delay before Run retries after a failed send
+	MaxRetry time.Duration // longest delay, reached by doubling while sends keep failing
+}
+
+type OutboxOptFunc func(*OutboxOpts)
+
+func defaultOutboxOpts() OutboxOpts {
+	return OutboxOpts{
+		MinRetry: time.Second,
+		MaxRetry: time.Minute,
+	}
+}
+
+// WithOutboxRetry sets the shortest and longest delay between retries in Run
+func WithOutboxRetry(min time.Duration, max time.Duration) OutboxOptFunc {
+	return func(opts *OutboxOpts) {
+		opts.MinRetry = min
+		opts.MaxRetry = max
+	}
+}
+
+// Outbox makes posting task results durable. Results are appended to a file, and synced, before they are sent, and
+// stay there until the server acknowledges them, so results survive the server restarting or the adapter crashing.
+// Entries are keyed by job and task: posting again for the same task replaces its results, so a task is never sent
+// more than one set of results from the outbox, though a set may be sent more than once (at-least-once).
+//
+// The file is an append-only log, compacted when the outbox is opened. OpenOutbox locks it (with a PATH.lock file
+// beside it), so only one Outbox at a time can have it open.
+type Outbox struct {
+	client  *LGClient
+	path    string
+	opts    OutboxOpts
+	mu      sync.Mutex
+	file    *os.File
+	lock    *os.File
+	seq     uint64
+	entries map[string]*OutboxEntry
+	sending map[string]bool
+}
+
+// ErrOutboxLocked is returned by OpenOutbox when another Outbox, in this process or another, has the file open
+var ErrOutboxLocked = errors.New("outbox is already open")
+
+func outboxKey(jobId, taskId string) string {
+	return jobId + "/" + taskId
+}
+
+// OpenOutbox locks and opens the outbox file at path, creating it if it does not exist, and loads the entries still
+// waiting to be sent. It fails with ErrOutboxLocked if the outbox is already open. The lock is held until Close.
+func OpenOutbox(client *LGClient, path string, opts ...OutboxOptFunc) (*Outbox, error) {
+	o := defaultOutboxOpts()
+	for _, fn := range opts {
+		fn(&o)
+	}
+
+	lock, err := lockOutbox(path)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, seq, err := loadOutbox(path)
+	if err != nil {
+		unlockOutbox(lock)
+		return nil, err
+	}
+
+	ob := &Outbox{
+		client:  client,
+		path:    path,
+		opts:    o,
+		lock:    lock,
+		seq:     seq,
+		entries: entries,
+		sending: map[string]bool{},
+	}
+	if err := ob.compact(); err != nil {
+		unlockOutbox(lock)
+		return nil, err
+	}
+	return ob, nil
+}
+
+// ReadOutbox lists the entries in an outbox file without opening it for writing, in the order they were queued
+func ReadOutbox(path string) ([]OutboxEntry, error) {
+	entries, _, err := loadOutbox(path)
+	if err != nil {
+		return nil, err
+	}
+	return sortedEntries(entries), nil
+}
+
+// loadOutbox replays an outbox file. A partly written last line, left by a crash, is ignored.
+func loadOutbox(path string) (map[string]*OutboxEntry, uint64, error) {
+	entries := map[string]*OutboxEntry{}
+	var seq uint64
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return entries, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for line := 1; ; line++ {
+		data, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+
+		var rec outboxRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return nil, 0, fmt.Errorf("outbox %s line %d: %w", path, line, err)
+		}
+		if rec.Seq > seq {
+			seq = rec.Seq
+		}
+
+		switch rec.Op {
+		case outboxPut:
+			if rec.Entry == nil {
+				return nil, 0, fmt.Errorf("outbox %s line %d: put without an entry", path, line)
+			}
+			rec.Entry.seq = rec.Seq
+			entries[outboxKey(rec.Entry.Job, rec.Entry.Task)] = rec.Entry
+		case outboxAttempt:
+			if e, ok := entries[outboxKey(rec.Job, rec.Task)]; ok && e.seq == rec.Seq {
+				e.Attempts++
+				e.LastErr = rec.Err
+				e.Rejected = rec.Rejected
+			}
+		case outboxDone:
+			if e, ok := entries[outboxKey(rec.Job, rec.Task)]; ok && e.seq == rec.Seq {
+				delete(entries, outboxKey(rec.Job, rec.Task))
+			}
+		default:
+			return nil, 0, fmt.Errorf("outbox %s line %d: unknown op %q", path, line, rec.Op)
+		}
+	}
+	return entries, seq, nil
+}
+
+func sortedEntries(entries map[string]*OutboxEntry) []OutboxEntry {
+	list := make([]OutboxEntry, 0, len(entries))
+	for _, e := range entries {
+		list = append(list, *e)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].seq < list[j].seq })
+	return list
+}
+
+// compact rewrites the file with only the waiting entries, then reopens it for appending
+func (o *Outbox) compact() error {
+	var buf bytes.Buffer
+	for _, e := range sortedEntries(o.entries) {
+		e := e
+		data, err := json.Marshal(outboxRecord{Op: outboxPut, Seq: e.seq, Entry: &e})
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+
+	tmp := o.path + ".tmp"
+	if err := writeFileSync(tmp, buf.Bytes()); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, o.path); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(o.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	o.file = f
+	return nil
+}
+
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// append writes a record and syncs it to disk. o.mu must be held.
+func (o *Outbox) append(rec outboxRecord) error {
+	if o.file == nil {
+		return fmt.Errorf("outbox %s is closed", o.path)
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := o.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return o.file.Sync()
+}
+
+// Post saves a task's results to the outbox and then tries to send them, as PostTaskResults would. An error is
+// only returned if the results could not be saved; a failed send is kept for Flush or Run to retry, and can be
+// seen in Pending.
+func (o *Outbox) Post(jobId, taskId string, tResults task.TaskResults) error {
+	if err := validateTaskResults(tResults); err != nil {
+		return err
+	}
+	results, err := json.Marshal(tResults)
+	if err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	o.seq++
+	entry := &OutboxEntry{Job: jobId, Task: taskId, Results: results, Queued: time.Now().UTC(), seq: o.seq}
+	if err := o.append(outboxRecord{Op: outboxPut, Seq: entry.seq, Entry: entry}); err != nil {
+		o.mu.Unlock()
+		return fmt.Errorf("saving results for task %s: %w", taskId, err)
+	}
+	key := outboxKey(jobId, taskId)
+	o.entries[key] = entry
+	if o.sending[key] {
+		// a send of older results is in flight; Flush or Run will send these
+		o.mu.Unlock()
+		return nil
+	}
+	o.sending[key] = true
+	o.mu.Unlock()
+
+	o.send(*entry)
+	return nil
+}
+
+// send posts an entry and records the outcome. The caller must have marked the entry as sending.
+func (o *Outbox) send(entry OutboxEntry) error {
+	resultsUrl := fmt.Sprintf("/lg/task/%s/%s", entry.Job, entry.Task)
+	resp, sendErr := o.client.sendPost(resultsUrl, nil, entry.Results, nil)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	key := outboxKey(entry.Job, entry.Task)
+	delete(o.sending, key)
+	current, ok := o.entries[key]
+	if !ok || current.seq != entry.seq {
+		// purged or replaced while it was being sent
+		return sendErr
+	}
+
+	if sendErr == nil {
+		if err := o.append(outboxRecord{Op: outboxDone, Seq: entry.seq, Job: entry.Job, Task: entry.Task}); err != nil {
+			return err
+		}
+		delete(o.entries, key)
+		return nil
+	}
+
+	// the server understood the request and refused it, so sending it again would not help
+	rejected := resp != nil && resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests
+	current.Attempts++
+	current.LastErr = sendErr.Error()
+	current.Rejected = rejected
+	if err := o.append(outboxRecord{Op: outboxAttempt, Seq: entry.seq, Job: entry.Job, Task: entry.Task, Err: current.LastErr, Rejected: rejected}); err != nil {
+		return errors.Join(sendErr, err)
+	}
+	return sendErr
+}
+
+// Flush tries to send every waiting entry which has not been rejected, oldest first, and returns how many were
+// sent. The errors of those which failed are joined.
+func (o *Outbox) Flush(ctx context.Context) (int, error) {
+	o.mu.Lock()
+	var batch []OutboxEntry
+	for _, e := range sortedEntries(o.entries) {
+		key := outboxKey(e.Job, e.Task)
+		if e.Rejected || o.sending[key] {
+			continue
+		}
+		o.sending[key] = true
+		batch = append(batch, e)
+	}
+	o.mu.Unlock()
+
+	sent := 0
+	var errs []error
+	for idx, e := range batch {
+		if ctx.Err() != nil {
+			o.mu.Lock()
+			for _, skipped := range batch[idx:] {
+				delete(o.sending, outboxKey(skipped.Job, skipped.Task))
+			}
+			o.mu.Unlock()
+			errs = append(errs, ctx.Err())
+			break
+		}
+		if err := o.send(e); err != nil {
+			errs = append(errs, fmt.Errorf("task %s/%s: %w", e.Job, e.Task, err))
+			continue
+		}
+		sent++
+	}
+	return sent, errors.Join(errs...)
+}
+
+// Run flushes the outbox until ctx is done, backing off while sends fail. It returns ctx.Err().
+func (o *Outbox) Run(ctx context.Context) error {
+	interval := o.opts.MinRetry
+	for {
+		_, err := o.Flush(ctx)
+		if err == nil {
+			interval = o.opts.MinRetry
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+
+		if err != nil {
+			interval *= 2
+			if interval > o.opts.MaxRetry {
+				interval = o.opts.MaxRetry
+			}
+		}
+	}
+}
+
+// Pending lists the entries waiting to be sent, including rejected ones, in the order they were queued
+func (o *Outbox) Pending() []OutboxEntry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return sortedEntries(o.entries)
+}
+
+// Purge removes entries without sending them and returns how many were removed. An empty jobId or taskId matches
+// any job or task, so Purge("", "") empties the outbox.
+func (o *Outbox) Purge(jobId, taskId string) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	purged := 0
+	for key, e := range o.entries {
+		if (jobId != "" && e.Job != jobId) || (taskId != "" && e.Task != taskId) {
+			continue
+		}
+		if err := o.append(outboxRecord{Op: outboxDone, Seq: e.seq, Job: e.Job, Task: e.Task}); err != nil {
+			return purged, err
+		}
+		delete(o.entries, key)
+		purged++
+	}
+	return purged, nil
+}
+
+// Close closes the outbox file and releases its lock. Waiting entries stay in it for the next OpenOutbox.
+func (o *Outbox) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var errs []error
+	if o.file != nil {
+		errs = append(errs, o.file.Close())
+		o.file = nil
+	}
+	if o.lock != nil {
+		errs = append(errs, unlockOutbox(o.lock))
+		o.lock = nil
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,30 @@
+//go:build !unix
+
+package client
+
+import (
+	"fmt"
+	"os"
+)
+
+// lockOutbox creates a lock file beside the outbox, failing if it already exists. Unlike the flock used on unix, the
+// file is left behind if the process crashes, and must be removed by hand once no process has the outbox open.
+func lockOutbox(path string) (*os.File, error) {
+	f, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o600)
+	if os.IsExist(err) {
+		return nil, fmt.Errorf("%w: %s (remove %s.lock if no process has it open)", ErrOutboxLocked, path, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock outbox %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// unlockOutbox releases the lock by removing the lock file
+func unlockOutbox(f *os.File) error {
+	closeErr := f.Close()
+	if err := os.Remove(f.Name()); err != nil {
+		return err
+	}
+	return closeErr
+}
@@ -0,0 +1,33 @@
+//go:build unix
+
+package client
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// lockOutbox takes an exclusive flock on a lock file beside the outbox. The outbox file itself is replaced when it is
+// compacted, so it cannot hold the lock. The lock is released when the process exits, however it exits.
+func lockOutbox(path string) (*os.File, error) {
+	f, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open outbox lock: %w", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, fmt.Errorf("%w: %s", ErrOutboxLocked, path)
+		}
+		return nil, fmt.Errorf("failed to lock outbox %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// unlockOutbox releases the lock. The lock file is left in place, as removing it could race with another process
+// locking it.
+func unlockOutbox(f *os.File) error {
+	return f.Close()
+}
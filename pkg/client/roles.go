@@ -0,0 +1,59 @@
+package client
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/skyleronken/lemonclient/pkg/job"
+	"github.com/skyleronken/lemonclient/pkg/permissions"
+)
+
+// rolesUpdate is a metadata merge which only changes roles, in the name to permissions map the server uses
+type rolesUpdate struct {
+	Roles map[string]permissions.Permissions `json:"roles"`
+}
+
+// GetJobRoles lists the roles on a job, ordered by user name
+func (s *LGClient) GetJobRoles(uuid string) ([]permissions.User, error) {
+	meta, err := s.GetJobMetadata(uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := append([]permissions.User(nil), meta.Roles...)
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	return roles, nil
+}
+
+// GrantJobRole gives a user each permission set in perms on an existing job, keeping any they already have
+func (s *LGClient) GrantJobRole(uuid string, name string, perms permissions.Permissions) error {
+	return s.updateJobRole(uuid, name, func(meta *job.JobMetadata) { meta.Grant(name, perms) })
+}
+
+// RevokeJobRole takes each permission set in perms away from a user on an existing job
+func (s *LGClient) RevokeJobRole(uuid string, name string, perms permissions.Permissions) error {
+	return s.updateJobRole(uuid, name, func(meta *job.JobMetadata) { meta.Revoke(name, perms) })
+}
+
+// updateJobRole applies change to the job's current metadata and merges the resulting roles back in. Every role is
+// sent, and revoked users are kept with no permissions, so the result is the same whether the server merges the
+// roles map or replaces it. Other metadata is left alone.
+// PUT /graph/{uuid}/meta
+func (s *LGClient) updateJobRole(uuid string, name string, change func(*job.JobMetadata)) error {
+	if name == "" {
+		return fmt.Errorf("user name cannot be empty")
+	}
+
+	meta, err := s.GetJobMetadata(uuid)
+	if err != nil {
+		return err
+	}
+	change(&meta)
+
+	update := rolesUpdate{Roles: map[string]permissions.Permissions{}}
+	for _, user := range meta.Roles {
+		update.Roles[user.Name] = user.Permissions
+	}
+	_, err = s.sendPut(fmt.Sprintf("/graph/%s/meta", uuid), nil, update, nil)
+	return err
+}
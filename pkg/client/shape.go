@@ -0,0 +1,143 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/skyleronken/lemonclient/pkg/query"
+	"github.com/skyleronken/lemonclient/pkg/task"
+)
+
+// PollOpts controls the optional checks PollAdapter applies to the tasks it receives
+type PollOpts struct {
+	ValidateShape   bool // check each TaskChain against the task's query
+	ErrorMismatched bool // mark tasks with mismatched chains as errored on the server
+}
+
+type PollOptFunc func(*PollOpts)
+
+// WithShapeValidation checks the length, node/edge alternation and types of each polled TaskChain against the query
+// in the TaskMetadata. Mismatches are returned as a *ShapeError.
+func WithShapeValidation() PollOptFunc {
+	return func(opts *PollOpts) {
+		opts.ValidateShape = true
+	}
+}
+
+// WithMismatchedTasksErrored implies WithShapeValidation and also sets the state of any task with mismatched chains
+// to `error` so it is not reissued.
+func WithMismatchedTasksErrored() PollOptFunc {
+	return func(opts *PollOpts) {
+		opts.ValidateShape = true
+		opts.ErrorMismatched = true
+	}
+}
+
+// ChainShapeError describes every way a single TaskChain differs from its query
+type ChainShapeError struct {
+	Index    int      // index of the chain in the polled results
+	Problems []string // one entry per mismatch
+}
+
+func (e ChainShapeError) Error() string {
+	return fmt.Sprintf("chain %d: %s", e.Index, strings.Join(e.Problems, "; "))
+}
+
+// ShapeError is returned when polled TaskChains do not match the shape of the task's query
+type ShapeError struct {
+	Job     string
+	Task    string
+	Query   string
+	Chains  []ChainShapeError
+	Errored bool // the task was marked as errored on the server
+}
+
+func (e *ShapeError) Error() string {
+	msgs := make([]string, len(e.Chains))
+	for idx, c := range e.Chains {
+		msgs[idx] = c.Error()
+	}
+	return fmt.Sprintf("task %s returned %d chain(s) not matching query %q: %s", e.Task, len(e.Chains), e.Query, strings.Join(msgs, ", "))
+}
+
+// ValidateTaskChains checks each chain against a query string. The returned []ChainShapeError is empty if every
+// chain matches. An error is only returned if the query itself can not be parsed.
+func ValidateTaskChains(q string, chains []TaskChain) ([]ChainShapeError, error) {
+	parsed, err := query.Parse(q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse task query: %w", err)
+	}
+
+	types := parsed.Types()
+
+	var mismatches []ChainShapeError
+	for idx, chain := range chains {
+		var problems []string
+
+		if len(chain) != parsed.Length() {
+			problems = append(problems, fmt.Sprintf("expected %d elements, got %d", parsed.Length(), len(chain)))
+		}
+
+		for pos := 0; pos < len(chain) && pos < parsed.Length(); pos++ {
+			element := chain[pos]
+			expected := parsed.Elements[pos].Kind
+
+			_, hasSource := element["src"]
+			_, hasTarget := element["tgt"]
+			actual := query.NodeElement
+			if hasSource && hasTarget {
+				actual = query.EdgeElement
+			}
+			if actual != expected {
+				problems = append(problems, fmt.Sprintf("element %d is a %s, expected a %s", pos, actual, expected))
+			}
+
+			if types[pos] != "" && element["type"] != types[pos] {
+				problems = append(problems, fmt.Sprintf("element %d has type %v, expected %q", pos, element["type"], types[pos]))
+			}
+		}
+
+		if len(problems) > 0 {
+			mismatches = append(mismatches, ChainShapeError{Index: idx, Problems: problems})
+		}
+	}
+
+	return mismatches, nil
+}
+
+// checkShape applies the PollOpts to the results of a poll
+func (s *LGClient) checkShape(opts PollOpts, metadata TaskMetadata, chains []TaskChain) error {
+	if !opts.ValidateShape {
+		return nil
+	}
+
+	mismatches, err := ValidateTaskChains(metadata.Query, chains)
+	if err != nil {
+		return err
+	}
+	if len(mismatches) == 0 {
+		return nil
+	}
+
+	shapeErr := &ShapeError{
+		Job:    metadata.Job,
+		Task:   metadata.Task,
+		Query:  metadata.Query,
+		Chains: mismatches,
+	}
+
+	if opts.ErrorMismatched {
+		tResults := task.PrepareTaskResults(
+			task.WithStateSetTo(task.TaskState_Errr),
+			task.WithDetails(shapeErr.Error()),
+		)
+		taskUrl := fmt.Sprintf("/lg/task/%s/%s", metadata.Job, metadata.Task)
+		if _, err := s.sendPost(taskUrl, nil, tResults, nil); err != nil {
+			return errors.Join(shapeErr, fmt.Errorf("failed to mark task %s errored: %w", metadata.Task, err))
+		}
+		shapeErr.Errored = true
+	}
+
+	return shapeErr
+}
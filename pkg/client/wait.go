@@ -0,0 +1,262 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/skyleronken/lemonclient/pkg/graph"
+	"github.com/skyleronken/lemonclient/pkg/job"
+)
+
+// WaitOpts configures WaitForJob
+type WaitOpts struct {
+	MinInterval time.Duration // delay after a check which saw the job change
+	MaxInterval time.Duration // longest delay, reached by doubling while the job does not change
+	QuietChecks int           // consecutive idle checks needed before the job is considered quiescent
+	UseDelta    bool
+	Progress    func(JobProgress)
+}
+
+type WaitOptFunc func(*WaitOpts)
+
+func defaultWaitOpts() WaitOpts {
+	return WaitOpts{
+		MinInterval: 500 * time.Millisecond,
+		MaxInterval: 30 * time.Second,
+		QuietChecks: 2,
+	}
+}
+
+// WithWaitBackoff sets the shortest and longest delay between checks
+func WithWaitBackoff(min time.Duration, max time.Duration) WaitOptFunc {
+	return func(opts *WaitOpts) {
+		opts.MinInterval = min
+		opts.MaxInterval = max
+	}
+}
+
+// WithQuietChecks sets how many consecutive idle checks are needed before the job is considered quiescent
+func WithQuietChecks(checks int) WaitOptFunc {
+	return func(opts *WaitOpts) {
+		opts.QuietChecks = checks
+	}
+}
+
+// WithDeltaStream waits on the delta stream between checks rather than sleeping. A check is made as soon as the
+// graph changes, and once the job looks idle an empty delta confirms it straight away instead of waiting for
+// further checks.
+func WithDeltaStream() WaitOptFunc {
+	return func(opts *WaitOpts) {
+		opts.UseDelta = true
+	}
+}
+
+// WithProgress calls fn with the state of the job after every check
+func WithProgress(fn func(JobProgress)) WaitOptFunc {
+	return func(opts *WaitOpts) {
+		opts.Progress = fn
+	}
+}
+
+// QueryProgress is how far one adapter query has got through a job
+type QueryProgress struct {
+	Adapter string
+	Query   string
+	Pos     int
+	Behind  int // IDs up to MaxID the query has yet to process
+	Tasks   int // outstanding tasks
+	Active  bool
+	Enabled bool
+}
+
+// Idle reports whether the query has nothing left to do. Disabled queries without tasks are idle, as they will not
+// process anything further until they are enabled.
+func (q QueryProgress) Idle() bool {
+	return !q.Active && q.Tasks == 0 && (q.Behind == 0 || !q.Enabled)
+}
+
+// JobProgress is the state of a job at one check made by WaitForJob
+type JobProgress struct {
+	Job     string
+	Nodes   int
+	Edges   int
+	MaxID   int
+	Queries []QueryProgress // ordered by adapter, then query
+	Tasks   int             // outstanding tasks across every query
+	Idle    bool            // every query is idle
+	Done    bool            // the job is quiescent, this is the last progress reported
+	Checks  int
+	Elapsed time.Duration
+}
+
+// progressOf summarises the config and status of a job
+func progressOf(uuid string, config job.JobConfig, status JobGraph) JobProgress {
+	p := JobProgress{
+		Job:   uuid,
+		Nodes: status.TotalNodes,
+		Edges: status.TotalEdges,
+		MaxID: status.MaxID,
+		Idle:  true,
+	}
+
+	for adapter, queries := range config {
+		for q, qc := range queries {
+			behind := status.MaxID - qc.Pos + 1
+			if behind < 0 {
+				behind = 0
+			}
+			qp := QueryProgress{
+				Adapter: adapter,
+				Query:   q,
+				Pos:     qc.Pos,
+				Behind:  behind,
+				Tasks:   qc.Tasks,
+				Active:  qc.Active,
+				Enabled: qc.Enabled,
+			}
+			p.Queries = append(p.Queries, qp)
+			p.Tasks += qc.Tasks
+			p.Idle = p.Idle && qp.Idle()
+		}
+	}
+	sort.Slice(p.Queries, func(i, j int) bool {
+		if p.Queries[i].Adapter != p.Queries[j].Adapter {
+			return p.Queries[i].Adapter < p.Queries[j].Adapter
+		}
+		return p.Queries[i].Query < p.Queries[j].Query
+	})
+
+	return p
+}
+
+// WaitForJob blocks until every adapter query on the job is idle: not active, without outstanding tasks, and past
+// the job's MaxID. The job is checked with GetJobConfig and GetJobStatus, backing off while it does not change, and
+// is considered quiescent after QuietChecks idle checks in a row (see WithDeltaStream for a faster alternative).
+// The progress of the last check is returned, along with any error from the server or ctx.
+func (s *LGClient) WaitForJob(ctx context.Context, uuid string, opts ...WaitOptFunc) (JobProgress, error) {
+	o := defaultWaitOpts()
+	for _, fn := range opts {
+		fn(&o)
+	}
+	if o.QuietChecks < 1 {
+		o.QuietChecks = 1
+	}
+
+	start := time.Now()
+	interval := o.MinInterval
+	quiet := 0
+	var last JobProgress
+
+	for checks := 1; ; checks++ {
+		if err := ctx.Err(); err != nil {
+			return last, err
+		}
+
+		config, err := s.GetJobConfig(uuid)
+		if err != nil {
+			return last, err
+		}
+		status, err := s.GetJobStatus(uuid)
+		if err != nil {
+			return last, err
+		}
+
+		p := progressOf(uuid, config, status)
+		p.Checks = checks
+		p.Elapsed = time.Since(start)
+
+		if p.Idle {
+			quiet++
+		} else {
+			quiet = 0
+		}
+
+		if checks == 1 || p.MaxID != last.MaxID || p.Tasks != last.Tasks {
+			interval = o.MinInterval
+		} else {
+			interval *= 2
+			if interval > o.MaxInterval {
+				interval = o.MaxInterval
+			}
+		}
+		last = p
+
+		if quiet >= o.QuietChecks {
+			return finishWait(last, o), nil
+		}
+
+		if o.UseDelta {
+			// an idle job whose graph has not moved since the check is quiescent, so read the delta once rather
+			// than waiting for it to change
+			graphChanged, err := s.waitForDelta(ctx, uuid, p.MaxID, interval, !p.Idle)
+			if err != nil {
+				return last, err
+			}
+			if p.Idle && !graphChanged {
+				return finishWait(last, o), nil
+			}
+			reportProgress(last, o)
+			continue
+		}
+
+		reportProgress(last, o)
+		select {
+		case <-ctx.Done():
+			return last, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+func reportProgress(p JobProgress, o WaitOpts) {
+	if o.Progress != nil {
+		o.Progress(p)
+	}
+}
+
+func finishWait(p JobProgress, o WaitOpts) JobProgress {
+	p.Done = true
+	reportProgress(p, o)
+	return p
+}
+
+// waitForDelta streams deltas after maxID for up to timeout, returning true as soon as a node or edge with a higher
+// ID arrives. If the stream ends early without changes, the rest of the timeout is slept when sleepRest is set.
+func (s *LGClient) waitForDelta(ctx context.Context, uuid string, maxID int, timeout time.Duration, sleepRest bool) (bool, error) {
+	deadline := time.Now().Add(timeout)
+	streamCtx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	changed := false
+	position := int64(maxID)
+	err := s.StreamDeltaContext(streamCtx, uuid, &DeltaParams{Position: &position}, func(header *DeltaHeader, flags int64, data interface{}, err error) {
+		var id int
+		switch v := data.(type) {
+		case graph.EdgeInterface:
+			id = v.GetID()
+		case graph.NodeInterface:
+			id = v.GetID()
+		}
+		if id > maxID {
+			changed = true
+			cancel()
+		}
+	})
+	if ctx.Err() != nil {
+		return changed, ctx.Err()
+	}
+	if err != nil && !changed && !errors.Is(err, context.DeadlineExceeded) {
+		return false, err
+	}
+
+	if !changed && sleepRest {
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(time.Until(deadline)):
+		}
+	}
+	return changed, nil
+}
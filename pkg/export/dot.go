@@ -0,0 +1,63 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/skyleronken/lemonclient/pkg/graph"
+)
+
+// dotReserved are the attributes WriteDOT writes for every element. Properties with these names are written with a
+// "p_" prefix so each attribute appears once.
+var dotReserved = map[string]bool{"label": true, "type": true, "value": true, "ID": true}
+
+// WriteDOT writes the store as a Graphviz digraph. Nodes are labelled with their value and edges with their type,
+// and every other field is written as a quoted attribute.
+func WriteDOT(w io.Writer, s *graph.Store, opts ...OptFunc) error {
+	o := buildOpts(opts)
+	g := flattenStore(s)
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "digraph %s {\n", dotQuote(o.Name))
+
+	attrs := func(label string, el element, schema Schema) string {
+		parts := []string{
+			"label=" + dotQuote(label),
+			"type=" + dotQuote(el.typ),
+			"value=" + dotQuote(el.value),
+		}
+		if el.lgID != 0 {
+			parts = append(parts, fmt.Sprintf("ID=%d", el.lgID))
+		}
+		for _, attr := range schema {
+			if v, ok := el.properties[attr.Name]; ok {
+				name := attr.Name
+				if dotReserved[name] {
+					name = "p_" + name
+				}
+				parts = append(parts, dotQuote(name)+"="+dotQuote(FormatValue(v, attr.Type)))
+			}
+		}
+		return strings.Join(parts, ", ")
+	}
+
+	for _, n := range g.nodes {
+		fmt.Fprintf(bw, "  %s [%s];\n", dotQuote(n.id), attrs(n.value, n, g.nodeSchema))
+	}
+	for _, e := range g.edges {
+		fmt.Fprintf(bw, "  %s -> %s [%s];\n", dotQuote(e.source), dotQuote(e.target), attrs(e.typ, e.element, g.edgeSchema))
+	}
+
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// dotQuote returns s as a DOT quoted string
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
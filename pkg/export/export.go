@@ -0,0 +1,251 @@
+// The export package writes job graphs held in a graph.Store to formats understood by other tools (Gephi, yEd,
+// Graphviz, Neo4j, ...). A store can be built from a job with client.JobDetail.Store() or client.D3View.Store().
+//
+// Node and edge properties are flattened before they are written: nested maps become dotted keys ("a.b") and lists
+// are JSON encoded. The type of each attribute is inferred from the values seen across every node (or edge).
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/skyleronken/lemonclient/pkg/graph"
+)
+
+// Opts configures an export
+type Opts struct {
+	Name  string // name of the graph where the format supports one
+	Label string // Neo4j label given to every node
+}
+
+type OptFunc func(*Opts)
+
+func defaultOpts() Opts {
+	return Opts{
+		Name:  "lemongraph",
+		Label: DefaultNeo4jLabel,
+	}
+}
+
+func WithName(name string) OptFunc {
+	return func(opts *Opts) {
+		opts.Name = name
+	}
+}
+
+func WithLabel(label string) OptFunc {
+	return func(opts *Opts) {
+		opts.Label = label
+	}
+}
+
+func buildOpts(opts []OptFunc) Opts {
+	o := defaultOpts()
+	for _, fn := range opts {
+		fn(&o)
+	}
+	return o
+}
+
+// AttrType is the inferred type of a flattened property
+type AttrType int
+
+const (
+	AttrBool AttrType = iota
+	AttrLong
+	AttrDouble
+	AttrString
+)
+
+// Attribute is a flattened property key and the type inferred for it
+type Attribute struct {
+	Name string
+	Type AttrType
+}
+
+// Schema lists the attributes found across a set of nodes or edges, ordered by name
+type Schema []Attribute
+
+// element is a node or edge with its properties flattened
+type element struct {
+	id         string
+	typ        string
+	value      string
+	lgID       int
+	properties map[string]interface{}
+}
+
+type edgeElement struct {
+	element
+	source string
+	target string
+}
+
+// exportGraph is the flattened form of a store shared by every exporter
+type exportGraph struct {
+	nodes      []element
+	edges      []edgeElement
+	nodeSchema Schema
+	edgeSchema Schema
+}
+
+func flattenStore(s *graph.Store) exportGraph {
+	g := exportGraph{}
+	ids := map[graph.NodeKey]string{}
+
+	for idx, n := range s.Nodes() {
+		id := fmt.Sprintf("n%d", n.GetID())
+		if n.GetID() == 0 {
+			id = fmt.Sprintf("new%d", idx)
+		}
+		ids[graph.KeyOf(n)] = id
+		g.nodes = append(g.nodes, element{
+			id:         id,
+			typ:        n.GetType(),
+			value:      n.GetValue(),
+			lgID:       n.GetID(),
+			properties: FlattenProperties(n.GetProperties()),
+		})
+	}
+
+	idx := 0
+	s.EachEdge(func(e graph.EdgeInterface, src, tgt graph.NodeInterface) {
+		id := fmt.Sprintf("e%d", e.GetID())
+		if e.GetID() == 0 {
+			id = fmt.Sprintf("newedge%d", idx)
+		}
+		idx++
+		g.edges = append(g.edges, edgeElement{
+			element: element{
+				id:         id,
+				typ:        e.GetType(),
+				value:      e.GetValue(),
+				lgID:       e.GetID(),
+				properties: FlattenProperties(e.GetProperties()),
+			},
+			source: ids[graph.KeyOf(src)],
+			target: ids[graph.KeyOf(tgt)],
+		})
+	})
+
+	nodeProps := make([]map[string]interface{}, len(g.nodes))
+	for i, n := range g.nodes {
+		nodeProps[i] = n.properties
+	}
+	edgeProps := make([]map[string]interface{}, len(g.edges))
+	for i, e := range g.edges {
+		edgeProps[i] = e.properties
+	}
+	g.nodeSchema = InferSchema(nodeProps)
+	g.edgeSchema = InferSchema(edgeProps)
+
+	return g
+}
+
+// FlattenProperties flattens nested maps into dotted keys and encodes lists as JSON strings
+func FlattenProperties(properties map[string]interface{}) map[string]interface{} {
+	flat := map[string]interface{}{}
+	var walk func(prefix string, v interface{})
+	walk = func(prefix string, v interface{}) {
+		switch val := v.(type) {
+		case map[string]interface{}:
+			for k, sub := range val {
+				walk(prefix+"."+k, sub)
+			}
+		case nil:
+			// nothing to store
+		case string, bool, float64, float32, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			flat[prefix] = val
+		default:
+			raw, err := json.Marshal(val)
+			if err != nil {
+				flat[prefix] = fmt.Sprint(val)
+			} else {
+				flat[prefix] = string(raw)
+			}
+		}
+	}
+
+	for k, v := range properties {
+		walk(k, v)
+	}
+	return flat
+}
+
+// InferSchema determines the type of every key across a set of flattened property maps. Keys holding only whole
+// numbers are longs, any other numbers make them doubles, and keys holding mixed kinds of values are strings.
+func InferSchema(properties []map[string]interface{}) Schema {
+	types := map[string]AttrType{}
+	for _, props := range properties {
+		for k, v := range props {
+			t := typeOf(v)
+			prev, seen := types[k]
+			switch {
+			case !seen:
+				types[k] = t
+			case prev == t:
+			case (prev == AttrLong && t == AttrDouble) || (prev == AttrDouble && t == AttrLong):
+				types[k] = AttrDouble
+			default:
+				types[k] = AttrString
+			}
+		}
+	}
+
+	schema := make(Schema, 0, len(types))
+	for k, t := range types {
+		schema = append(schema, Attribute{Name: k, Type: t})
+	}
+	sort.Slice(schema, func(i, j int) bool { return schema[i].Name < schema[j].Name })
+	return schema
+}
+
+func typeOf(v interface{}) AttrType {
+	switch val := v.(type) {
+	case bool:
+		return AttrBool
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return AttrLong
+	case float32:
+		return typeOf(float64(val))
+	case float64:
+		if val == math.Trunc(val) && !math.IsInf(val, 0) && math.Abs(val) < 1<<53 {
+			return AttrLong
+		}
+		return AttrDouble
+	}
+	return AttrString
+}
+
+// FormatValue renders a flattened property value as the given attribute type
+func FormatValue(v interface{}, t AttrType) string {
+	switch t {
+	case AttrBool:
+		if b, ok := v.(bool); ok {
+			return strconv.FormatBool(b)
+		}
+	case AttrLong:
+		switch val := v.(type) {
+		case float64:
+			return strconv.FormatInt(int64(val), 10)
+		case float32:
+			return strconv.FormatInt(int64(val), 10)
+		}
+		return fmt.Sprint(v)
+	case AttrDouble:
+		switch val := v.(type) {
+		case float64:
+			return strconv.FormatFloat(val, 'g', -1, 64)
+		case float32:
+			return strconv.FormatFloat(float64(val), 'g', -1, 32)
+		}
+		return fmt.Sprint(v)
+	}
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,132 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/skyleronken/lemonclient/pkg/graph"
+	"github.com/stretchr/testify/assert"
+)
+
+func testStore(t *testing.T) *graph.Store {
+	a, err := graph.JsonToNode([]byte(`{"ID": 1, "type": "domain", "value": "a.com", "score": 3, "whois": {"registrar": "x"}, "tags": ["a", "b"]}`))
+	assert.NoError(t, err)
+	b, err := graph.JsonToNode([]byte(`{"ID": 2, "type": "ip", "value": "1.1.1.1", "score": 2.5, "seen": true}`))
+	assert.NoError(t, err)
+	e, err := graph.JsonToEdge([]byte(`{"ID": 3, "type": "resolves", "srcID": 1, "tgtID": 2, "note": "say \"hi\""}`))
+	assert.NoError(t, err)
+
+	s := graph.NewStore()
+	s.AddNode(a)
+	s.AddNode(b)
+	assert.NoError(t, s.AddEdge(e))
+	return s
+}
+
+func Test_InferSchema(t *testing.T) {
+	schema := InferSchema([]map[string]interface{}{
+		{"a": float64(1), "b": true, "c": "x", "d": float64(1)},
+		{"a": float64(2), "b": float64(1), "d": 1.5},
+	})
+
+	assert.Equal(t, Schema{
+		{Name: "a", Type: AttrLong},
+		{Name: "b", Type: AttrString},
+		{Name: "c", Type: AttrString},
+		{Name: "d", Type: AttrDouble},
+	}, schema)
+
+	flat := FlattenProperties(map[string]interface{}{"a": map[string]interface{}{"b": map[string]interface{}{"c": 1}}, "l": []interface{}{1, "x"}})
+	assert.Equal(t, map[string]interface{}{"a.b.c": 1, "l": `[1,"x"]`}, flat)
+}
+
+func Test_WriteGraphML(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WriteGraphML(&buf, testStore(t), WithName("job1")))
+
+	var doc graphMLDocument
+	assert.NoError(t, xml.Unmarshal(buf.Bytes(), &doc))
+	assert.Equal(t, "job1", doc.Graph.ID)
+	assert.Len(t, doc.Graph.Nodes, 2)
+	assert.Len(t, doc.Graph.Edges, 1)
+	assert.Equal(t, "n1", doc.Graph.Edges[0].Source)
+	assert.Equal(t, "n2", doc.Graph.Edges[0].Target)
+
+	assert.Contains(t, doc.Keys, graphMLKey{ID: "n_p_score", For: "node", AttrName: "score", AttrType: "double"})
+	assert.Contains(t, doc.Keys, graphMLKey{ID: "n_p_seen", For: "node", AttrName: "seen", AttrType: "boolean"})
+	assert.Contains(t, doc.Keys, graphMLKey{ID: "n_p_whois.registrar", For: "node", AttrName: "whois.registrar", AttrType: "string"})
+	assert.Contains(t, doc.Keys, graphMLKey{ID: "e_p_note", For: "edge", AttrName: "note", AttrType: "string"})
+
+	assert.Contains(t, doc.Graph.Nodes[0].Data, graphMLData{Key: "n_type", Value: "domain"})
+	assert.Contains(t, doc.Graph.Nodes[0].Data, graphMLData{Key: "n_p_score", Value: "3"})
+	assert.Contains(t, doc.Graph.Nodes[0].Data, graphMLData{Key: "n_p_tags", Value: `["a","b"]`})
+	assert.Contains(t, doc.Graph.Edges[0].Data, graphMLData{Key: "e_p_note", Value: `say "hi"`})
+}
+
+func Test_WriteGEXF(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WriteGEXF(&buf, testStore(t)))
+
+	var doc gexfDocument
+	assert.NoError(t, xml.Unmarshal(buf.Bytes(), &doc))
+	assert.Equal(t, "1.2", doc.Version)
+	assert.Equal(t, "http://www.gexf.net/1.2draft", doc.Xmlns)
+	assert.Len(t, doc.Graph.Nodes, 2)
+	assert.Equal(t, "a.com", doc.Graph.Nodes[0].Label)
+	assert.Equal(t, "resolves", doc.Graph.Edges[0].Label)
+	assert.Equal(t, "n1", doc.Graph.Edges[0].Source)
+	assert.Contains(t, doc.Graph.Attributes[0].Attributes, gexfAttribute{ID: "p_score", Title: "score", Type: "double"})
+	assert.Contains(t, doc.Graph.Nodes[1].AttValues, gexfAttValue{For: "p_seen", Value: "true"})
+}
+
+func Test_WriteDOT(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WriteDOT(&buf, testStore(t)))
+
+	out := buf.String()
+	assert.True(t, strings.HasPrefix(out, `digraph "lemongraph" {`))
+	assert.Contains(t, out, `"n1" [label="a.com", type="domain", value="a.com", ID=1, "score"="3"`)
+	assert.Contains(t, out, `"n1" -> "n2" [label="resolves", type="resolves", value="", ID=3, "note"="say \"hi\""];`)
+
+	// properties named after the fixed attributes are prefixed rather than repeating them
+	n, err := graph.JsonToNode([]byte(`{"ID": 4, "type": "host", "value": "h1", "label": "web"}`))
+	assert.NoError(t, err)
+	s := graph.NewStore()
+	s.AddNode(n)
+	buf.Reset()
+	assert.NoError(t, WriteDOT(&buf, s))
+	assert.Contains(t, buf.String(), `"n4" [label="h1", type="host", value="h1", ID=4, "p_label"="web"`)
+	assert.Equal(t, 1, strings.Count(buf.String(), "label="))
+}
+
+func Test_WriteCypher(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WriteCypher(&buf, testStore(t)))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 4)
+	assert.Equal(t, "CREATE INDEX `lg_LGNode` IF NOT EXISTS FOR (n:`LGNode`) ON (n.type, n.value);", lines[0])
+	assert.Contains(t, lines, "MERGE (n:`LGNode` {type: 'ip', value: '1.1.1.1'}) SET n:`ip`, n.ID = 2, n.`score` = 2.5, n.`seen` = true;")
+	assert.Contains(t, lines, "MATCH (a:`LGNode` {type: 'domain', value: 'a.com'}), (b:`LGNode` {type: 'ip', value: '1.1.1.1'}) "+
+		"MERGE (a)-[r:`resolves` {value: ''}]->(b) SET r.ID = 3, r.`note` = 'say \"hi\"';")
+}
+
+func Test_WriteNeo4jCSV(t *testing.T) {
+	var nodes, rels bytes.Buffer
+	assert.NoError(t, WriteNeo4jCSV(&nodes, &rels, testStore(t), WithLabel("Lemon")))
+
+	n, err := csv.NewReader(&nodes).ReadAll()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{":ID", "type", "value", "ID:long", "score:double", "seen:boolean", "tags:string", "whois.registrar:string", ":LABEL"}, n[0])
+	assert.Contains(t, n, []string{"n2", "ip", "1.1.1.1", "2", "2.5", "true", "", "", "Lemon;ip"})
+
+	r, err := csv.NewReader(&rels).ReadAll()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{":START_ID", ":END_ID", ":TYPE", "type", "value", "ID:long", "note:string"},
+		{"n1", "n2", "resolves", "resolves", "", "3", `say "hi"`},
+	}, r)
+}
@@ -0,0 +1,120 @@
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/skyleronken/lemonclient/pkg/graph"
+)
+
+var gexfTypes = map[AttrType]string{
+	AttrBool:   "boolean",
+	AttrLong:   "long",
+	AttrDouble: "double",
+	AttrString: "string",
+}
+
+type gexfAttribute struct {
+	ID    string `xml:"id,attr"`
+	Title string `xml:"title,attr"`
+	Type  string `xml:"type,attr"`
+}
+
+type gexfAttributes struct {
+	Class      string          `xml:"class,attr"`
+	Attributes []gexfAttribute `xml:"attribute"`
+}
+
+type gexfAttValue struct {
+	For   string `xml:"for,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type gexfNode struct {
+	ID        string         `xml:"id,attr"`
+	Label     string         `xml:"label,attr"`
+	AttValues []gexfAttValue `xml:"attvalues>attvalue,omitempty"`
+}
+
+type gexfEdge struct {
+	ID        string         `xml:"id,attr"`
+	Source    string         `xml:"source,attr"`
+	Target    string         `xml:"target,attr"`
+	Label     string         `xml:"label,attr"`
+	AttValues []gexfAttValue `xml:"attvalues>attvalue,omitempty"`
+}
+
+type gexfGraph struct {
+	DefaultEdgeType string           `xml:"defaultedgetype,attr"`
+	Mode            string           `xml:"mode,attr"`
+	Attributes      []gexfAttributes `xml:"attributes"`
+	Nodes           []gexfNode       `xml:"nodes>node"`
+	Edges           []gexfEdge       `xml:"edges>edge"`
+}
+
+type gexfMeta struct {
+	Description string `xml:"description"`
+}
+
+type gexfDocument struct {
+	XMLName xml.Name  `xml:"gexf"`
+	Xmlns   string    `xml:"xmlns,attr"`
+	Version string    `xml:"version,attr"`
+	Meta    gexfMeta  `xml:"meta"`
+	Graph   gexfGraph `xml:"graph"`
+}
+
+// WriteGEXF writes the store as GEXF 1.2. Nodes are labelled with their value and edges with their type.
+func WriteGEXF(w io.Writer, s *graph.Store, opts ...OptFunc) error {
+	o := buildOpts(opts)
+	g := flattenStore(s)
+
+	doc := gexfDocument{
+		Xmlns:   "http://www.gexf.net/1.2draft",
+		Version: "1.2",
+		Meta:    gexfMeta{Description: o.Name},
+		Graph:   gexfGraph{DefaultEdgeType: "directed", Mode: "static"},
+	}
+
+	attributes := func(class string, schema Schema) gexfAttributes {
+		attrs := gexfAttributes{Class: class, Attributes: []gexfAttribute{
+			{ID: "type", Title: "type", Type: "string"},
+			{ID: "value", Title: "value", Type: "string"},
+			{ID: "ID", Title: "ID", Type: "long"},
+		}}
+		for _, attr := range schema {
+			attrs.Attributes = append(attrs.Attributes, gexfAttribute{ID: "p_" + attr.Name, Title: attr.Name, Type: gexfTypes[attr.Type]})
+		}
+		return attrs
+	}
+	doc.Graph.Attributes = []gexfAttributes{attributes("node", g.nodeSchema), attributes("edge", g.edgeSchema)}
+
+	values := func(el element, schema Schema) []gexfAttValue {
+		vals := []gexfAttValue{{For: "type", Value: el.typ}, {For: "value", Value: el.value}}
+		if el.lgID != 0 {
+			vals = append(vals, gexfAttValue{For: "ID", Value: fmt.Sprint(el.lgID)})
+		}
+		for _, attr := range schema {
+			if v, ok := el.properties[attr.Name]; ok {
+				vals = append(vals, gexfAttValue{For: "p_" + attr.Name, Value: FormatValue(v, attr.Type)})
+			}
+		}
+		return vals
+	}
+
+	for _, n := range g.nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, gexfNode{ID: n.id, Label: n.value, AttValues: values(n, g.nodeSchema)})
+	}
+	for _, e := range g.edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, gexfEdge{
+			ID:        e.id,
+			Source:    e.source,
+			Target:    e.target,
+			Label:     e.typ,
+			AttValues: values(e.element, g.edgeSchema),
+		})
+	}
+
+	return writeXML(w, doc)
+}
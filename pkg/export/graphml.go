@@ -0,0 +1,135 @@
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/skyleronken/lemonclient/pkg/graph"
+)
+
+var graphMLTypes = map[AttrType]string{
+	AttrBool:   "boolean",
+	AttrLong:   "long",
+	AttrDouble: "double",
+	AttrString: "string",
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	ID     string        `xml:"id,attr"`
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLDocument struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+// Keys used for the LG fields of every node and edge. Properties are keyed as "n_<name>" and "e_<name>".
+const (
+	graphMLType  = "type"
+	graphMLValue = "value"
+	graphMLID    = "ID"
+)
+
+// WriteGraphML writes the store as GraphML
+func WriteGraphML(w io.Writer, s *graph.Store, opts ...OptFunc) error {
+	o := buildOpts(opts)
+	g := flattenStore(s)
+
+	doc := graphMLDocument{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Graph: graphMLGraph{ID: o.Name, EdgeDefault: "directed"},
+	}
+
+	for _, scope := range []struct {
+		prefix string
+		target string
+		schema Schema
+	}{{"n", "node", g.nodeSchema}, {"e", "edge", g.edgeSchema}} {
+		doc.Keys = append(doc.Keys,
+			graphMLKey{ID: scope.prefix + "_" + graphMLType, For: scope.target, AttrName: graphMLType, AttrType: "string"},
+			graphMLKey{ID: scope.prefix + "_" + graphMLValue, For: scope.target, AttrName: graphMLValue, AttrType: "string"},
+			graphMLKey{ID: scope.prefix + "_" + graphMLID, For: scope.target, AttrName: graphMLID, AttrType: "long"},
+		)
+		for _, attr := range scope.schema {
+			doc.Keys = append(doc.Keys, graphMLKey{
+				ID:       fmt.Sprintf("%s_p_%s", scope.prefix, attr.Name),
+				For:      scope.target,
+				AttrName: attr.Name,
+				AttrType: graphMLTypes[attr.Type],
+			})
+		}
+	}
+
+	data := func(prefix string, el element, schema Schema) []graphMLData {
+		d := []graphMLData{
+			{Key: prefix + "_" + graphMLType, Value: el.typ},
+			{Key: prefix + "_" + graphMLValue, Value: el.value},
+		}
+		if el.lgID != 0 {
+			d = append(d, graphMLData{Key: prefix + "_" + graphMLID, Value: fmt.Sprint(el.lgID)})
+		}
+		for _, attr := range schema {
+			if v, ok := el.properties[attr.Name]; ok {
+				d = append(d, graphMLData{Key: fmt.Sprintf("%s_p_%s", prefix, attr.Name), Value: FormatValue(v, attr.Type)})
+			}
+		}
+		return d
+	}
+
+	for _, n := range g.nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{ID: n.id, Data: data("n", n, g.nodeSchema)})
+	}
+	for _, e := range g.edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			ID:     e.id,
+			Source: e.source,
+			Target: e.target,
+			Data:   data("e", e.element, g.edgeSchema),
+		})
+	}
+
+	return writeXML(w, doc)
+}
+
+func writeXML(w io.Writer, doc interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,140 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/skyleronken/lemonclient/pkg/graph"
+)
+
+// DefaultNeo4jLabel is the label given to every exported node so that (type, value) can be indexed
+const DefaultNeo4jLabel = "LGNode"
+
+var neo4jTypes = map[AttrType]string{
+	AttrBool:   "boolean",
+	AttrLong:   "long",
+	AttrDouble: "double",
+	AttrString: "string",
+}
+
+// WriteCypher writes the store as a Cypher script. Nodes are MERGEd on (type, value) under the Neo4j label set
+// with WithLabel, and also get their LG type as a label. Edges are MERGEd between their endpoints on (type, value)
+// with the LG type as the relationship type. The remaining fields are SET with their inferred types.
+func WriteCypher(w io.Writer, s *graph.Store, opts ...OptFunc) error {
+	o := buildOpts(opts)
+	g := flattenStore(s)
+	label := cypherName(o.Label)
+
+	nodes := map[string]element{}
+	for _, n := range g.nodes {
+		nodes[n.id] = n
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "CREATE INDEX %s IF NOT EXISTS FOR (n:%s) ON (n.type, n.value);\n", cypherName("lg_"+o.Label), label)
+
+	for _, n := range g.nodes {
+		set := append([]string{"n:" + cypherName(n.typ)}, cypherSet("n", n, g.nodeSchema)...)
+		fmt.Fprintf(bw, "MERGE (n:%s {type: %s, value: %s}) SET %s;\n",
+			label, cypherString(n.typ), cypherString(n.value), strings.Join(set, ", "))
+	}
+	for _, e := range g.edges {
+		src, tgt := nodes[e.source], nodes[e.target]
+		set := ""
+		if parts := cypherSet("r", e.element, g.edgeSchema); len(parts) > 0 {
+			set = " SET " + strings.Join(parts, ", ")
+		}
+		fmt.Fprintf(bw, "MATCH (a:%s {type: %s, value: %s}), (b:%s {type: %s, value: %s}) MERGE (a)-[r:%s {value: %s}]->(b)%s;\n",
+			label, cypherString(src.typ), cypherString(src.value),
+			label, cypherString(tgt.typ), cypherString(tgt.value),
+			cypherName(e.typ), cypherString(e.value), set)
+	}
+
+	return bw.Flush()
+}
+
+// cypherSet returns the assignments of the LG ID and properties of el to the variable v
+func cypherSet(v string, el element, schema Schema) []string {
+	var parts []string
+	if el.lgID != 0 {
+		parts = append(parts, fmt.Sprintf("%s.ID = %d", v, el.lgID))
+	}
+	for _, attr := range schema {
+		if val, ok := el.properties[attr.Name]; ok {
+			parts = append(parts, fmt.Sprintf("%s.%s = %s", v, cypherName(attr.Name), cypherValue(val, attr.Type)))
+		}
+	}
+	return parts
+}
+
+func cypherValue(v interface{}, t AttrType) string {
+	if t == AttrString {
+		return cypherString(FormatValue(v, t))
+	}
+	return FormatValue(v, t)
+}
+
+// cypherString returns s as a single quoted Cypher string literal
+func cypherString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `'`, `\'`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return "'" + s + "'"
+}
+
+// cypherName returns s as a backtick quoted Cypher label, relationship type or property name
+func cypherName(s string) string {
+	return "`" + strings.ReplaceAll(s, "`", "``") + "`"
+}
+
+// WriteNeo4jCSV writes the store as a pair of neo4j-admin import files, one for nodes and one for relationships.
+// Node IDs are only unique within the export. Every node is given the label set with WithLabel and its LG type.
+func WriteNeo4jCSV(nodes io.Writer, relationships io.Writer, s *graph.Store, opts ...OptFunc) error {
+	o := buildOpts(opts)
+	g := flattenStore(s)
+
+	row := func(el element, schema Schema, lead ...string) []string {
+		r := append(lead, el.typ, el.value, "")
+		if el.lgID != 0 {
+			r[len(r)-1] = fmt.Sprint(el.lgID)
+		}
+		for _, attr := range schema {
+			v, ok := el.properties[attr.Name]
+			if !ok {
+				r = append(r, "")
+				continue
+			}
+			r = append(r, FormatValue(v, attr.Type))
+		}
+		return r
+	}
+
+	header := func(schema Schema, lead ...string) []string {
+		h := append(lead, "type", "value", "ID:long")
+		for _, attr := range schema {
+			h = append(h, attr.Name+":"+neo4jTypes[attr.Type])
+		}
+		return h
+	}
+
+	nw := csv.NewWriter(nodes)
+	nw.Write(append(header(g.nodeSchema, ":ID"), ":LABEL"))
+	for _, n := range g.nodes {
+		nw.Write(append(row(n, g.nodeSchema, n.id), o.Label+";"+n.typ))
+	}
+	nw.Flush()
+	if err := nw.Error(); err != nil {
+		return err
+	}
+
+	rw := csv.NewWriter(relationships)
+	rw.Write(header(g.edgeSchema, ":START_ID", ":END_ID", ":TYPE"))
+	for _, e := range g.edges {
+		rw.Write(row(e.element, g.edgeSchema, e.source, e.target, e.typ))
+	}
+	rw.Flush()
+	return rw.Error()
+}
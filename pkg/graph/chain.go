@@ -100,23 +100,34 @@ func (c *chain) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// ChainToJson turns each element of a chain into JSON, nodes as NodeToJson and edges as EdgeToJson would. With
+// `minimal`, elements which already exist (have an ID) are reduced to their core fields and edges leave out their
+// endpoints, which the chain supplies.
 func ChainToJson(c ChainInterface, minimal bool) ([][]byte, error) {
-	// Marshal the entire chain
-	chainJson, err := json.Marshal(c)
-	if err != nil {
-		return nil, err
-	}
-
-	// Unmarshal into array of raw messages to split into separate elements
-	var elements []json.RawMessage
-	if err := json.Unmarshal(chainJson, &elements); err != nil {
-		return nil, err
+	if c == nil {
+		return nil, fmt.Errorf("chain is nil")
 	}
 
-	// Convert to [][]byte
+	elements := c.GetElements()
 	result := make([][]byte, len(elements))
-	for i, element := range elements {
-		result[i] = []byte(element)
+	for idx, element := range elements {
+		var err error
+		if idx%2 == 0 { // nodes at even indices
+			node, ok := element.(NodeInterface)
+			if !ok {
+				return nil, fmt.Errorf("invalid node at index %d", idx)
+			}
+			result[idx], err = NodeToJson(node, minimal)
+		} else { // edges at odd indices
+			edge, ok := element.(EdgeInterface)
+			if !ok {
+				return nil, fmt.Errorf("invalid edge at index %d", idx)
+			}
+			result[idx], err = EdgeToJson(edge, minimal, false)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("element %d: %w", idx, err)
+		}
 	}
 
 	return result, nil
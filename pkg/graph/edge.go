@@ -35,11 +35,11 @@ type EdgeMembers struct {
 	Source       NodeInterface `json:"src,omitempty" mapstructure:"src"`
 	Target       NodeInterface `json:"tgt,omitempty" mapstructure:"tgt"`
 	ID           int           `json:"ID,omitempty"`
-	SourceId     string        `json:"srcID,omitempty" mapstructure:"srcID"`
-	TargetId     string        `json:"tgtID,omitempty" mapstructure:"tgtID"`
+	SourceId     string        `json:"srcID,omitempty" mapstructure:"srcID" lg:"srcID"`
+	TargetId     string        `json:"tgtID,omitempty" mapstructure:"tgtID" lg:"tgtID"`
 	Type         string        `json:"type" mapstructure:"type"`
 	Value        string        `json:"value" mapstructure:"value"`
-	LastModified string        `json:"last_modified,omitempty" mapstructure:"last_modified"`
+	LastModified string        `json:"last_modified,omitempty" mapstructure:"last_modified" lg:"last_modified"`
 }
 
 func (e edge) GetSource() NodeInterface              { return e.Source }
@@ -65,90 +65,144 @@ func (e *edge) SetProperty(key string, value interface{}) error {
 // An Edge should be used in one of two ways:
 // 1) If updating an edge, instantiate it with its ID and modify it accordingly.
 // 2) If creating an edge, create it as part of a Chain, and leave the Source and Target nil.
+// Fields can be renamed, skipped or marked as key fields using the `lg` struct tag (see tags.go).
+// Embedded structs (including EdgeMembers) and pointers to them are processed recursively, as with Node().
 func Edge(obj interface{}) (EdgeInterface, error) {
 
 	// Get the value and handle pointer types
-	sValue := reflect.ValueOf(obj)
-	if sValue.Kind() == reflect.Ptr {
-		sValue = sValue.Elem()
+	sValue, err := structSource(obj)
+	if err != nil {
+		return nil, fmt.Errorf("edges must be created from a struct: %w", err)
 	}
-	sType := sValue.Type()
 
 	e := &edge{
 		Properties: make(map[string]interface{}),
 	}
 
-	// hasType, hasSource, hasTarget, hasValue := false, false, false, false
-
-	for i := 0; i < sValue.NumField(); i++ {
-		field := sType.Field(i)
-		value := sValue.Field(i)
-		name := field.Name
-
-		if name == "ID" {
-			e.ID = int(value.Int())
-		} else if name == "Type" {
-			// hasType = true
-			e.Type = value.String()
-		} else if name == "Value" {
-			//hasValue = true
-			e.Value = value.String()
-		} else if name == "Source" {
-			//hasSource = true
-			if field.Type.Implements(nodeInterfaceType) {
-				e.Source = value.Interface().(NodeInterface)
-			} else {
-				// Try to convert to Node
-				node, err := Node(value.Interface())
+	// Helper to turn a Source/Target field into a node
+	toNode := func(field reflect.StructField, value reflect.Value) (NodeInterface, error) {
+		if (value.Kind() == reflect.Interface || value.Kind() == reflect.Ptr) && value.IsNil() {
+			return nil, nil
+		}
+		if field.Type.Implements(nodeInterfaceType) {
+			return value.Interface().(NodeInterface), nil
+		}
+		// Try to convert to Node
+		return Node(value.Interface())
+	}
+
+	// Helper function to process struct fields recursively
+	var processFields func(reflect.Value) error
+	processFields = func(val reflect.Value) error {
+		typ := val.Type()
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			value := val.Field(i)
+
+			// Handle embedded structs
+			if field.Anonymous {
+				if field.Tag.Get(lgTagName) == "-" {
+					continue
+				}
+				if embedded, ok := embeddedStruct(value); ok {
+					if err := processFields(embedded); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+
+			f := parseLGField(field, edgeKeyFields, edgeKeyTags)
+			if f.Skip || !field.IsExported() {
+				continue
+			}
+
+			if !f.IsKey {
+				if f.OmitEmpty && value.IsZero() {
+					continue
+				}
+				e.Properties[f.Name] = value.Interface()
+				continue
+			}
+
+			switch f.Name {
+			case "ID":
+				e.ID = coerceID(value)
+			case "type":
+				e.Type = value.String()
+			case "value":
+				e.Value = value.String()
+			case "src":
+				node, err := toNode(field, value)
 				if err != nil {
-					return nil, fmt.Errorf("failed to convert Source to Node: %w", err)
+					return fmt.Errorf("failed to convert Source to Node: %w", err)
 				}
 				e.Source = node
-			}
-		} else if name == "Target" {
-			//hasTarget = true
-			if field.Type.Implements(nodeInterfaceType) {
-				e.Target = value.Interface().(NodeInterface)
-			} else {
-				// Try to convert to Node
-				node, err := Node(value.Interface())
+			case "tgt":
+				node, err := toNode(field, value)
 				if err != nil {
-					return nil, fmt.Errorf("failed to convert Target to Node: %w", err)
+					return fmt.Errorf("failed to convert Target to Node: %w", err)
 				}
 				e.Target = node
+			case "srcID":
+				e.SourceId = coerceIDString(value)
+			case "tgtID":
+				e.TargetId = coerceIDString(value)
+			case "last_modified":
+				e.LastModified = value.String()
 			}
-		} else if name == "EdgeMembers" {
-			// hasType = true
-			// hasSource = true
-			// hasTarget = true
-			e.EdgeMembers = value.Interface().(EdgeMembers)
-		} else {
-			e.Properties[name] = value.Interface()
 		}
+		return nil
 	}
 
-	// if e.ID == 0 && (!hasType || !hasSource || !hasTarget || !hasValue) {
-	// 	return nil, fmt.Errorf("structs representing edges must contain a `Type`, `Value`, `Source`, and `Target` member")
-	// }
+	// Process the main struct
+	if err := processFields(sValue); err != nil {
+		return nil, err
+	}
 
-	if len(e.Type) == 0 {
-		return nil, fmt.Errorf("`Type` cannot be an empty string")
+	// New edges must declare a type. Existing edges are identified by their ID.
+	if e.ID == 0 && len(e.Type) == 0 {
+		return nil, fmt.Errorf("`Type` cannot be an empty string for new edges")
 	}
 
 	return e, nil
 
 }
 
+// ValidateEdge checks that an edge can be used on its own, outside of a chain. Chains supply the endpoints of their
+// edges, but a standalone edge which is being created (ID is 0) must have a type and must reference its endpoints
+// either as Source/Target nodes or as srcID/tgtID.
+func ValidateEdge(e EdgeInterface) error {
+	if e == nil {
+		return fmt.Errorf("edge is nil")
+	}
+
+	if e.GetID() != 0 {
+		return nil
+	}
+
+	if len(e.GetType()) == 0 {
+		return fmt.Errorf("`Type` cannot be an empty string for new edges")
+	}
+
+	if e.GetSource() != nil && e.GetTarget() != nil {
+		return nil
+	}
+
+	if ee, ok := e.(*edge); ok && ee.SourceId != "" && ee.TargetId != "" {
+		return nil
+	}
+
+	return fmt.Errorf("new edges outside of a chain must have either a `Source` and `Target` or a `SourceId` and `TargetId`")
+}
+
 // This will turn an Edge into JSON. `minimal` indicates if the properties should be included. If ID is nil, it will always include them for creation purposes.
 // The `includeNodes` flag determines if the src and tgt values should be included in the JSON. This should be set to `false` when creating a chain.
 func EdgeToJson(e EdgeInterface, minimal bool, includeNodes bool) ([]byte, error) {
 	if minimal && e.GetID() != 0 {
 		// For minimal output with an ID, create a new map with just the core fields
-		minimalEdge := make(map[string]interface{})
-		if e.GetID() != 0 {
-			minimalEdge["ID"] = e.GetID()
-		} else {
-			minimalEdge["type"] = e.GetType()
+		minimalEdge := map[string]interface{}{
+			"ID": e.GetID(),
 		}
 		if e.GetValue() != "" {
 			minimalEdge["value"] = e.GetValue()
@@ -185,6 +239,10 @@ func JsonToEdge(jsonBytes []byte) (EdgeInterface, error) {
 
 func EdgeToChain(e EdgeInterface) (ChainInterface, error) {
 
+	if e == nil || e.GetSource() == nil || e.GetTarget() == nil {
+		return nil, fmt.Errorf("edges must have a `Source` and `Target` to be turned into a chain")
+	}
+
 	// c := Chain{
 	// 		Source:      e.GetSource(),
 	// 		Destination: e.GetTarget(),
@@ -303,13 +361,9 @@ func (e *edge) UnmarshalJSON(data []byte) error {
 				e.Target = tgtNode
 			}
 		case "srcID":
-			if s, ok := value.(string); ok {
-				e.SourceId = s
-			}
+			e.SourceId = idString(value)
 		case "tgtID":
-			if s, ok := value.(string); ok {
-				e.TargetId = s
-			}
+			e.TargetId = idString(value)
 		case "last_modified":
 			if s, ok := value.(string); ok {
 				e.LastModified = s
@@ -321,3 +375,103 @@ func (e *edge) UnmarshalJSON(data []byte) error {
 
 	return nil
 }
+
+// EdgeToStruct is the reverse of Edge(). It populates the struct pointed to by `out` using the same `lg` tag rules,
+// so a struct passed through Edge() and back is unchanged. Source and target fields may either be NodeInterface
+// values or structs, in which case they are decoded with NodeToStruct.
+func EdgeToStruct(e EdgeInterface, out interface{}) error {
+	sValue, err := structTarget(out)
+	if err != nil {
+		return err
+	}
+
+	var members EdgeMembers
+	if ee, ok := e.(*edge); ok {
+		members = ee.EdgeMembers
+	}
+	properties := e.GetProperties()
+
+	setNode := func(dst reflect.Value, n NodeInterface) error {
+		if n == nil {
+			return nil
+		}
+		if dst.Type() == nodeInterfaceType {
+			dst.Set(reflect.ValueOf(n))
+			return nil
+		}
+		if dst.Kind() == reflect.Ptr && dst.Type().Elem().Kind() == reflect.Struct {
+			if dst.IsNil() {
+				dst.Set(reflect.New(dst.Type().Elem()))
+			}
+			return NodeToStruct(n, dst.Interface())
+		}
+		if dst.Kind() == reflect.Struct {
+			return NodeToStruct(n, dst.Addr().Interface())
+		}
+		return fmt.Errorf("unsupported node field type %s", dst.Type())
+	}
+
+	var processFields func(reflect.Value) error
+	processFields = func(val reflect.Value) error {
+		typ := val.Type()
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			value := val.Field(i)
+
+			// Handle embedded structs
+			if field.Anonymous {
+				if field.Tag.Get(lgTagName) == "-" {
+					continue
+				}
+				if embedded, ok := embeddedTarget(value); ok {
+					if err := processFields(embedded); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+
+			f := parseLGField(field, edgeKeyFields, edgeKeyTags)
+			if f.Skip || !field.IsExported() {
+				continue
+			}
+
+			if !f.IsKey {
+				prop, ok := properties[f.Name]
+				if !ok {
+					continue
+				}
+				if err := assignValue(value, prop); err != nil {
+					return fmt.Errorf("failed to set field %s from property %s: %w", field.Name, f.Name, err)
+				}
+				continue
+			}
+
+			switch f.Name {
+			case "ID":
+				setID(value, e.GetID())
+			case "type":
+				setString(value, e.GetType())
+			case "value":
+				setString(value, e.GetValue())
+			case "src":
+				if err := setNode(value, e.GetSource()); err != nil {
+					return fmt.Errorf("failed to set field %s from source node: %w", field.Name, err)
+				}
+			case "tgt":
+				if err := setNode(value, e.GetTarget()); err != nil {
+					return fmt.Errorf("failed to set field %s from target node: %w", field.Name, err)
+				}
+			case "srcID":
+				setString(value, members.SourceId)
+			case "tgtID":
+				setString(value, members.TargetId)
+			case "last_modified":
+				setString(value, members.LastModified)
+			}
+		}
+		return nil
+	}
+
+	return processFields(sValue)
+}
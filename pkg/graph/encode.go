@@ -0,0 +1,111 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// The functions below are the canonical JSON encoding of the nodes, chains and edges sent to LemonGraph. job.Job and
+// task.TaskResults both use them, so the same elements encode the same way in either, and decode back to what was
+// encoded. Nodes and chain elements follow NodeToJson and ChainToJson; edges outside of a chain follow EdgeToJson and
+// always carry their endpoints.
+
+// NodesToJson encodes each node. A nil or empty list encodes to nil.
+func NodesToJson(nodes []NodeInterface, minimal bool) ([]json.RawMessage, error) {
+	if len(nodes) == 0 {
+		return nil, nil
+	}
+	result := make([]json.RawMessage, len(nodes))
+	for idx, n := range nodes {
+		if n == nil {
+			return nil, fmt.Errorf("node %d: node is nil", idx)
+		}
+		data, err := NodeToJson(n, minimal)
+		if err != nil {
+			return nil, fmt.Errorf("node %d: %w", idx, err)
+		}
+		result[idx] = data
+	}
+	return result, nil
+}
+
+// ChainsToJson encodes each chain as a list of its elements. A nil or empty list encodes to nil.
+func ChainsToJson(chains []ChainInterface, minimal bool) ([][]json.RawMessage, error) {
+	if len(chains) == 0 {
+		return nil, nil
+	}
+	result := make([][]json.RawMessage, len(chains))
+	for idx, c := range chains {
+		elements, err := ChainToJson(c, minimal)
+		if err != nil {
+			return nil, fmt.Errorf("chain %d: %w", idx, err)
+		}
+		result[idx] = make([]json.RawMessage, len(elements))
+		for e := range elements {
+			result[idx][e] = elements[e]
+		}
+	}
+	return result, nil
+}
+
+// EdgesToJson encodes each edge with its endpoints. A nil or empty list encodes to nil.
+func EdgesToJson(edges []EdgeInterface, minimal bool) ([]json.RawMessage, error) {
+	if len(edges) == 0 {
+		return nil, nil
+	}
+	result := make([]json.RawMessage, len(edges))
+	for idx, e := range edges {
+		if e == nil {
+			return nil, fmt.Errorf("edge %d: edge is nil", idx)
+		}
+		data, err := EdgeToJson(e, minimal, true)
+		if err != nil {
+			return nil, fmt.Errorf("edge %d: %w", idx, err)
+		}
+		result[idx] = data
+	}
+	return result, nil
+}
+
+// JsonToNodes decodes nodes encoded by NodesToJson. An empty list decodes to nil.
+func JsonToNodes(raw []json.RawMessage) ([]NodeInterface, error) {
+	var nodes []NodeInterface
+	for idx, data := range raw {
+		n, err := JsonToNode(data)
+		if err != nil {
+			return nil, fmt.Errorf("node %d: %w", idx, err)
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
+
+// JsonToChains decodes chains encoded by ChainsToJson. An empty list decodes to nil.
+func JsonToChains(raw [][]json.RawMessage) ([]ChainInterface, error) {
+	var chains []ChainInterface
+	for idx, rawChain := range raw {
+		elements := make([][]byte, len(rawChain))
+		for e := range rawChain {
+			elements[e] = rawChain[e]
+		}
+		c, err := JsonToChain(elements)
+		if err != nil {
+			return nil, fmt.Errorf("chain %d: %w", idx, err)
+		}
+		chains = append(chains, c)
+	}
+	return chains, nil
+}
+
+// JsonToEdges decodes edges encoded by EdgesToJson. An empty list decodes to nil.
+func JsonToEdges(raw []json.RawMessage) ([]EdgeInterface, error) {
+	var edges []EdgeInterface
+	for idx, data := range raw {
+		e, err := JsonToEdge(data)
+		if err != nil {
+			return nil, fmt.Errorf("edge %d: %w", idx, err)
+		}
+		edges = append(edges, e)
+	}
+	return edges, nil
+}
@@ -1,6 +1,7 @@
 package graph
 
 import (
+	"encoding/json"
 	"os"
 	"testing"
 
@@ -220,6 +221,42 @@ func Test_EdgeToChain(t *testing.T) {
 	assert.Equal(t, e1.GetType(), (edge).GetType())
 }
 
+func Test_ChainToJson(t *testing.T) {
+	assert := assert.New(t)
+
+	c, err := CreateChain(n1, e2, n2)
+	assert.NoError(err)
+
+	// full is what the chain marshals to
+	full, err := ChainToJson(c, false)
+	assert.NoError(err)
+	assert.Len(full, 3)
+	whole, err := json.Marshal(c)
+	assert.NoError(err)
+	encoded, err := ChainsToJson([]ChainInterface{c}, false)
+	assert.NoError(err)
+	again, err := json.Marshal(encoded[0])
+	assert.NoError(err)
+	assert.JSONEq(string(whole), string(again))
+
+	// minimal reduces nodes to their core fields, and the edge leaves its endpoints to the chain
+	minimal, err := ChainToJson(c, true)
+	assert.NoError(err)
+	assert.JSONEq(`{"ID": 0, "type": "TestType", "value": "TestTypeValue1"}`, string(minimal[0]))
+	assert.JSONEq(`{"ID": 1, "type": "TestType", "value": "TestTypeValue2"}`, string(minimal[2]))
+	assert.JSONEq(`{"ID": 1}`, string(minimal[1]))
+
+	chains, err := JsonToChains(encoded)
+	assert.NoError(err)
+	assert.Len(chains, 1)
+	assert.Equal(n2.GetValue(), chains[0].GetElements()[2].(NodeInterface).GetValue())
+
+	_, err = ChainToJson(nil, false)
+	assert.Error(err)
+	_, err = NodesToJson([]NodeInterface{n1, nil}, false)
+	assert.ErrorContains(err, "node 1")
+}
+
 func Test_BadChains(t *testing.T) {
 
 	// not enough elements
@@ -230,3 +267,261 @@ func Test_BadChains(t *testing.T) {
 	_, err = CreateChain(e1, n2, n1)
 	assert.Error(t, err)
 }
+
+func Test_StructTags(t *testing.T) {
+	assert := assert.New(t)
+
+	type TaggedNode struct {
+		NodeMembers
+		Foo    string `lg:"foo"`
+		Bar    int    `lg:"bar,omitempty"`
+		Secret string `lg:"-"`
+	}
+
+	tn := TaggedNode{
+		NodeMembers: NodeMembers{Type: "tagged", Value: "v1"},
+		Foo:         "foo1",
+		Secret:      "hidden",
+	}
+
+	n, err := Node(tn)
+	assert.NoError(err)
+	assert.Equal("foo1", n.GetProperties()["foo"])
+	assert.NotContains(n.GetProperties(), "Foo")
+	assert.NotContains(n.GetProperties(), "bar")
+	assert.NotContains(n.GetProperties(), "Secret")
+	assert.NotContains(n.GetProperties(), "LastModified")
+
+	// key fields can be renamed with tags
+	type KeyedNode struct {
+		ID   string `lg:"ID"`
+		Kind string `lg:"type"`
+		Name string `lg:"value"`
+		Foo  string `lg:"foo"`
+	}
+
+	n, err = Node(KeyedNode{ID: "3", Kind: "keyed", Name: "v2", Foo: "foo2"})
+	assert.NoError(err)
+	assert.Equal(3, n.GetID())
+	assert.Equal("keyed", n.GetType())
+	assert.Equal("v2", n.GetValue())
+
+	// round trip through JSON and back into the struct
+	nJson, err := NodeToJson(n, false)
+	assert.NoError(err)
+	decoded, err := JsonToNode(nJson)
+	assert.NoError(err)
+
+	var kn KeyedNode
+	assert.NoError(NodeToStruct(decoded, &kn))
+	assert.Equal(KeyedNode{ID: "3", Kind: "keyed", Name: "v2", Foo: "foo2"}, kn)
+
+	tn.Bar = 7
+	n, err = Node(tn)
+	assert.NoError(err)
+	nJson, err = NodeToJson(n, false)
+	assert.NoError(err)
+	decoded, err = JsonToNode(nJson)
+	assert.NoError(err)
+
+	var tn2 TaggedNode
+	assert.NoError(NodeToStruct(decoded, &tn2))
+	tn.Secret = ""
+	assert.Equal(tn, tn2)
+
+	// edges follow the same rules
+	type TaggedEdge struct {
+		EdgeMembers
+		Weight float64 `lg:"weight"`
+		Note   string  `lg:"note,omitempty"`
+	}
+
+	e, err := Edge(TaggedEdge{EdgeMembers: EdgeMembers{Type: "tagged", Source: n1, Target: n2}, Weight: 0.5})
+	assert.NoError(err)
+	assert.Equal(0.5, e.GetProperties()["weight"])
+	assert.NotContains(e.GetProperties(), "note")
+
+	eJson, err := EdgeToJson(e, false, true)
+	assert.NoError(err)
+	decodedEdge, err := JsonToEdge(eJson)
+	assert.NoError(err)
+
+	var te TaggedEdge
+	assert.NoError(EdgeToStruct(decodedEdge, &te))
+	assert.Equal("tagged", te.Type)
+	assert.Equal(0.5, te.Weight)
+	assert.Equal(n1.GetValue(), te.Source.GetValue())
+	assert.Equal(n2.GetValue(), te.Target.GetValue())
+
+	assert.Error(NodeToStruct(n, tn2))
+}
+
+func Test_EdgeEmbeddedAndValidation(t *testing.T) {
+	assert := assert.New(t)
+
+	type Inner struct {
+		Weight int
+	}
+
+	type PtrEdge struct {
+		*EdgeMembers
+		*Inner
+		Note string
+	}
+
+	e, err := Edge(&PtrEdge{
+		EdgeMembers: &EdgeMembers{Type: "ptr", Source: n1, Target: n2},
+		Inner:       &Inner{Weight: 3},
+		Note:        "note",
+	})
+	assert.NoError(err)
+	assert.Equal("ptr", e.GetType())
+	assert.Equal(3, e.GetProperties()["Weight"])
+	assert.Equal("note", e.GetProperties()["Note"])
+	assert.NotContains(e.GetProperties(), "LastModified")
+	assert.NoError(ValidateEdge(e))
+
+	var decoded PtrEdge
+	assert.NoError(EdgeToStruct(e, &decoded))
+	assert.Equal("ptr", decoded.Type)
+	assert.Equal(3, decoded.Weight)
+
+	// string IDs are coerced, and existing edges do not need a type
+	type StringIDEdge struct {
+		ID       string
+		SourceId int `lg:"srcID"`
+		TargetId int `lg:"tgtID"`
+	}
+
+	e, err = Edge(StringIDEdge{ID: "12"})
+	assert.NoError(err)
+	assert.Equal(12, e.GetID())
+	assert.NoError(ValidateEdge(e))
+
+	// new edges need a type
+	_, err = Edge(StringIDEdge{})
+	assert.Error(err)
+
+	// new edges outside of a chain need endpoints
+	e, err = Edge(EdgeMembers{Type: "loose"})
+	assert.NoError(err)
+	assert.Error(ValidateEdge(e))
+
+	e, err = Edge(EdgeMembers{Type: "loose", SourceId: "1", TargetId: "2"})
+	assert.NoError(err)
+	assert.NoError(ValidateEdge(e))
+
+	_, err = Edge("not a struct")
+	assert.Error(err)
+	_, err = Node((*GoodTestType)(nil))
+	assert.Error(err)
+}
+
+func Test_Store(t *testing.T) {
+	assert := assert.New(t)
+
+	mkNode := func(id int, typ, value string) NodeInterface {
+		n, err := Node(NodeMembers{ID: id, Type: typ, Value: value})
+		assert.NoError(err)
+		return n
+	}
+	mkEdge := func(id int, typ string, src, tgt NodeInterface) EdgeInterface {
+		e, err := Edge(EdgeMembers{ID: id, Type: typ, Source: src, Target: tgt})
+		assert.NoError(err)
+		return e
+	}
+
+	a := mkNode(1, "domain", "a.com")
+	b := mkNode(2, "ip", "1.1.1.1")
+	c := mkNode(3, "ip", "2.2.2.2")
+	d := mkNode(4, "asn", "13335")
+	lone := mkNode(5, "domain", "lone.com")
+
+	s := NewStore()
+	s.AddNode(lone)
+
+	ab := mkEdge(10, "resolves", a, b)
+	chain, err := CreateChain(a, ab, b)
+	assert.NoError(err)
+	assert.NoError(s.AddChain(chain))
+	assert.NoError(s.AddEdge(mkEdge(11, "resolves", a, c)))
+	assert.NoError(s.AddEdge(mkEdge(12, "member", b, d)))
+
+	// edges which only reference endpoints by ID wait for the node
+	byID, err := Edge(EdgeMembers{ID: 13, Type: "member", SourceId: "3", TargetId: "6"})
+	assert.NoError(err)
+	assert.NoError(s.AddEdge(byID))
+	_, ok := s.Edge(13)
+	assert.False(ok)
+	s.AddNode(mkNode(6, "asn", "15169"))
+	_, ok = s.Edge(13)
+	assert.True(ok)
+
+	nodes, edges := s.Len()
+	assert.Equal(6, nodes)
+	assert.Equal(4, edges)
+
+	n, ok := s.NodeByID(2)
+	assert.True(ok)
+	assert.Equal("1.1.1.1", n.GetValue())
+	assert.Len(s.NodesByType("ip"), 2)
+
+	src, tgt, ok := s.Endpoints(12)
+	assert.True(ok)
+	assert.Equal(b.GetValue(), src.GetValue())
+	assert.Equal(d.GetValue(), tgt.GetValue())
+
+	assert.Equal(2, s.Degree(KeyOf(a), Outgoing))
+	assert.Equal(0, s.Degree(KeyOf(a), Incoming))
+	assert.Equal(2, s.Degree(KeyOf(b), Both))
+
+	values := func(nodes []NodeInterface) []string {
+		var vs []string
+		for _, n := range nodes {
+			vs = append(vs, n.GetValue())
+		}
+		return vs
+	}
+
+	assert.Equal([]string{"1.1.1.1", "2.2.2.2"}, values(s.Neighbours(KeyOf(a), Outgoing)))
+	assert.Equal([]string{"a.com"}, values(s.Neighbours(KeyOf(b), Incoming)))
+	assert.Equal([]string{"a.com", "1.1.1.1", "2.2.2.2"}, values(s.Expand(KeyOf(a), 1, Outgoing)))
+	assert.Len(s.Expand(KeyOf(a), 2, Outgoing), 5)
+
+	path, err := s.ShortestPath(KeyOf(a), KeyOf(d), Outgoing)
+	assert.NoError(err)
+	assert.Len(path.GetElements(), 5)
+	assert.Equal("13335", path.GetElements()[4].(NodeInterface).GetValue())
+
+	_, err = s.ShortestPath(KeyOf(d), KeyOf(a), Outgoing)
+	assert.Error(err)
+	path, err = s.ShortestPath(KeyOf(d), KeyOf(a), Both)
+	assert.NoError(err)
+	assert.Len(path.GetElements(), 5)
+
+	// a node's path to itself is just the node
+	path, err = s.ShortestPath(KeyOf(a), KeyOf(a), Both)
+	assert.NoError(err)
+	assert.Len(path.GetElements(), 1)
+	assert.Equal(a.GetValue(), path.GetElements()[0].(NodeInterface).GetValue())
+
+	components := s.ConnectedComponents()
+	assert.Len(components, 2)
+	assert.Len(components[0], 5)
+	assert.Equal([]string{"lone.com"}, values(components[1]))
+
+	count := 0
+	s.EachEdge(func(e EdgeInterface, src, tgt NodeInterface) {
+		assert.NotNil(src)
+		assert.NotNil(tgt)
+		count++
+	})
+	assert.Equal(4, count)
+
+	s.RemoveNode(KeyOf(b))
+	nodes, edges = s.Len()
+	assert.Equal(5, nodes)
+	assert.Equal(2, edges)
+	s.RemoveEdge(11)
+	assert.Equal(0, s.Degree(KeyOf(a), Both))
+}
@@ -7,13 +7,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
-	"strconv"
 )
 
 // Private node struct to represent an LG node
 type node struct {
 	NodeInterface `json:",omitempty"`
-	Properties    map[string]interface{} `json:"properties,omitempty" mapstructure:"properties,omitempty`
+	Properties    map[string]interface{} `json:"properties,omitempty" mapstructure:"properties,omitempty"`
 	NodeMembers
 }
 
@@ -32,7 +31,7 @@ type NodeMembers struct {
 	ID           int    `json:"ID,omitempty"`
 	Type         string `json:"type" mapstructure:"type"`
 	Value        string `json:"value" mapstructure:"value"`
-	LastModified string `json:"last_modified,omitempty" mapstructure:"last_modified"`
+	LastModified string `json:"last_modified,omitempty" mapstructure:"last_modified" lg:"last_modified"`
 }
 
 func (n node) GetID() int                            { return n.ID }
@@ -55,14 +54,14 @@ func (n *node) SetProperty(key string, value interface{}) error {
 }
 
 // This is the constructor which should be used to take an arbitrary struct and turn it into an LG node.
+// Fields can be renamed, skipped or marked as key fields using the `lg` struct tag (see tags.go).
 
 func Node(obj interface{}, properties ...map[string]interface{}) (NodeInterface, error) {
 	// Get the value and handle pointer types
-	sValue := reflect.ValueOf(obj)
-	if sValue.Kind() == reflect.Ptr {
-		sValue = sValue.Elem()
+	sValue, err := structSource(obj)
+	if err != nil {
+		return nil, fmt.Errorf("nodes must be created from a struct: %w", err)
 	}
-	sType := sValue.Type()
 
 	n := &node{
 		Properties: make(map[string]interface{}),
@@ -79,36 +78,45 @@ func Node(obj interface{}, properties ...map[string]interface{}) (NodeInterface,
 
 			// Handle embedded structs
 			if field.Anonymous {
-				if value.Kind() == reflect.Struct {
-					processFields(value, field.Type)
+				if field.Tag.Get(lgTagName) == "-" {
+					continue
+				}
+				if embedded, ok := embeddedStruct(value); ok {
+					processFields(embedded, embedded.Type())
 				}
 				continue
 			}
 
-			name := field.Name
-			switch name {
-			case "ID":
-				switch value.Kind() {
-				case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-					n.ID = int(value.Int())
-				case reflect.String:
-					id, _ := strconv.Atoi(value.String())
-					n.ID = id
+			f := parseLGField(field, nodeKeyFields, nodeKeyTags)
+			if f.Skip || !field.IsExported() {
+				continue
+			}
+
+			if !f.IsKey {
+				if f.OmitEmpty && value.IsZero() {
+					continue
 				}
-			case "Type":
+				n.Properties[f.Name] = value.Interface()
+				continue
+			}
+
+			switch f.Name {
+			case "ID":
+				n.ID = coerceID(value)
+			case "type":
 				hasType = true
 				n.Type = value.String()
-			case "Value":
+			case "value":
 				hasValue = true
 				n.Value = value.String()
-			default:
-				n.Properties[name] = value.Interface()
+			case "last_modified":
+				n.LastModified = value.String()
 			}
 		}
 	}
 
 	// Process the main struct
-	processFields(sValue, sType)
+	processFields(sValue, sValue.Type())
 
 	// Override properties if provided
 	if len(properties) > 0 {
@@ -256,3 +264,70 @@ func MapToNode(rawNode map[string]interface{}) (NodeInterface, error) {
 
 	return node, nil
 }
+
+// NodeToStruct is the reverse of Node(). It populates the struct pointed to by `out` using the same `lg` tag rules,
+// so a struct passed through Node() and back is unchanged. Properties without a matching field are ignored.
+func NodeToStruct(n NodeInterface, out interface{}) error {
+	sValue, err := structTarget(out)
+	if err != nil {
+		return err
+	}
+
+	lastModified := ""
+	if nn, ok := n.(*node); ok {
+		lastModified = nn.LastModified
+	}
+	properties := n.GetProperties()
+
+	var processFields func(reflect.Value) error
+	processFields = func(val reflect.Value) error {
+		typ := val.Type()
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			value := val.Field(i)
+
+			// Handle embedded structs
+			if field.Anonymous {
+				if field.Tag.Get(lgTagName) == "-" {
+					continue
+				}
+				if embedded, ok := embeddedTarget(value); ok {
+					if err := processFields(embedded); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+
+			f := parseLGField(field, nodeKeyFields, nodeKeyTags)
+			if f.Skip || !field.IsExported() {
+				continue
+			}
+
+			if !f.IsKey {
+				prop, ok := properties[f.Name]
+				if !ok {
+					continue
+				}
+				if err := assignValue(value, prop); err != nil {
+					return fmt.Errorf("failed to set field %s from property %s: %w", field.Name, f.Name, err)
+				}
+				continue
+			}
+
+			switch f.Name {
+			case "ID":
+				setID(value, n.GetID())
+			case "type":
+				setString(value, n.GetType())
+			case "value":
+				setString(value, n.GetValue())
+			case "last_modified":
+				setString(value, lastModified)
+			}
+		}
+		return nil
+	}
+
+	return processFields(sValue)
+}
@@ -0,0 +1,653 @@
+// An in-memory model of a graph so that results from the server (views, delta streams, task chains) can be reasoned
+// about locally. Nodes are unique on (type, value) as they are in LemonGraph, and are also indexed by ID once known.
+package graph
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Direction selects which edges are followed when traversing a Store
+type Direction int
+
+const (
+	Outgoing Direction = iota
+	Incoming
+	Both
+)
+
+// NodeKey is the (type, value) primary key of a node
+type NodeKey struct {
+	Type  string
+	Value string
+}
+
+func (k NodeKey) String() string {
+	return fmt.Sprintf("%s:%s", k.Type, k.Value)
+}
+
+// KeyOf returns the NodeKey of a node
+func KeyOf(n NodeInterface) NodeKey {
+	return NodeKey{Type: n.GetType(), Value: n.GetValue()}
+}
+
+// nodeRef is an edge endpoint which may only be known by ID until the node itself is added
+type nodeRef struct {
+	ID     int
+	Key    NodeKey
+	HasKey bool
+}
+
+type storedEdge struct {
+	edge     EdgeInterface
+	src, tgt nodeRef
+}
+
+type edgeKey struct {
+	ID       int
+	Src, Tgt NodeKey
+	Type     string
+	Value    string
+}
+
+// Store indexes nodes by ID and by (type, value), and edges by their source and target. It is safe for concurrent use.
+type Store struct {
+	mu      sync.RWMutex
+	nodes   map[NodeKey]NodeInterface
+	byID    map[int]NodeKey
+	edges   map[edgeKey]*storedEdge
+	edgeIDs map[int]edgeKey
+	out     map[NodeKey]map[edgeKey]bool
+	in      map[NodeKey]map[edgeKey]bool
+	pending map[edgeKey]*storedEdge // edges with an endpoint only known by an ID not yet in the store
+}
+
+// NewStore returns an empty Store
+func NewStore() *Store {
+	return &Store{
+		nodes:   map[NodeKey]NodeInterface{},
+		byID:    map[int]NodeKey{},
+		edges:   map[edgeKey]*storedEdge{},
+		edgeIDs: map[int]edgeKey{},
+		out:     map[NodeKey]map[edgeKey]bool{},
+		in:      map[NodeKey]map[edgeKey]bool{},
+		pending: map[edgeKey]*storedEdge{},
+	}
+}
+
+// AddNode inserts or replaces a node
+func (s *Store) AddNode(n NodeInterface) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addNode(n, true)
+}
+
+func (s *Store) addNode(n NodeInterface, replace bool) NodeKey {
+	key := KeyOf(n)
+	old, exists := s.nodes[key]
+	if exists && !replace {
+		return key
+	}
+	if exists && old.GetID() != 0 && old.GetID() != n.GetID() {
+		delete(s.byID, old.GetID())
+	}
+
+	s.nodes[key] = n
+	if n.GetID() != 0 {
+		s.byID[n.GetID()] = key
+		s.resolvePending(n.GetID())
+	}
+	return key
+}
+
+// AddEdge inserts or replaces an edge. Its endpoints are taken from its Source/Target nodes, or from its srcID/tgtID.
+// Endpoint nodes which are not yet in the store are added. Edges which only reference their endpoints by an unknown
+// ID are held until a node with that ID is added.
+func (s *Store) AddEdge(e EdgeInterface) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	src, err := s.endpoint(e.GetSource(), edgeEndpointID(e, true))
+	if err != nil {
+		return fmt.Errorf("edge source: %w", err)
+	}
+	tgt, err := s.endpoint(e.GetTarget(), edgeEndpointID(e, false))
+	if err != nil {
+		return fmt.Errorf("edge target: %w", err)
+	}
+
+	s.addEdge(&storedEdge{edge: e, src: src, tgt: tgt})
+	return nil
+}
+
+// AddChain adds every node and edge of a chain. Edges are connected to the nodes either side of them in the chain.
+func (s *Store) AddChain(c ChainInterface) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elements := c.GetElements()
+	for idx := 0; idx < len(elements); idx += 2 {
+		if n, ok := elements[idx].(NodeInterface); ok {
+			s.addNode(n, true)
+		}
+	}
+
+	for idx := 1; idx < len(elements)-1; idx += 2 {
+		e, ok := elements[idx].(EdgeInterface)
+		if !ok {
+			return fmt.Errorf("invalid edge at index %d", idx)
+		}
+		src := KeyOf(elements[idx-1].(NodeInterface))
+		tgt := KeyOf(elements[idx+1].(NodeInterface))
+		s.addEdge(&storedEdge{
+			edge: e,
+			src:  nodeRef{Key: src, HasKey: true},
+			tgt:  nodeRef{Key: tgt, HasKey: true},
+		})
+	}
+
+	return nil
+}
+
+// RemoveNode removes a node and every edge connected to it
+func (s *Store) RemoveNode(key NodeKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n, ok := s.nodes[key]
+	if !ok {
+		return
+	}
+	for ek := range s.out[key] {
+		s.removeEdge(ek)
+	}
+	for ek := range s.in[key] {
+		s.removeEdge(ek)
+	}
+	delete(s.nodes, key)
+	delete(s.out, key)
+	delete(s.in, key)
+	if n.GetID() != 0 {
+		delete(s.byID, n.GetID())
+	}
+}
+
+// RemoveEdge removes an edge by ID
+func (s *Store) RemoveEdge(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ek, ok := s.edgeIDs[id]; ok {
+		s.removeEdge(ek)
+	}
+	for ek := range s.pending {
+		if ek.ID == id {
+			delete(s.pending, ek)
+		}
+	}
+}
+
+// Clone returns a copy of the store which is unaffected by later changes to the original. Nodes and edges themselves
+// are shared.
+func (s *Store) Clone() *Store {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c := NewStore()
+	for k, v := range s.nodes {
+		c.nodes[k] = v
+	}
+	for k, v := range s.byID {
+		c.byID[k] = v
+	}
+	for k, v := range s.edges {
+		se := *v
+		c.edges[k] = &se
+	}
+	for k, v := range s.edgeIDs {
+		c.edgeIDs[k] = v
+	}
+	for k, v := range s.pending {
+		se := *v
+		c.pending[k] = &se
+	}
+	copyAdjacency := func(dst, src map[NodeKey]map[edgeKey]bool) {
+		for k, edges := range src {
+			dst[k] = make(map[edgeKey]bool, len(edges))
+			for ek := range edges {
+				dst[k][ek] = true
+			}
+		}
+	}
+	copyAdjacency(c.out, s.out)
+	copyAdjacency(c.in, s.in)
+
+	return c
+}
+
+// Node looks up a node by (type, value)
+func (s *Store) Node(key NodeKey) (NodeInterface, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	n, ok := s.nodes[key]
+	return n, ok
+}
+
+// NodeByID looks up a node by ID
+func (s *Store) NodeByID(id int) (NodeInterface, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key, ok := s.byID[id]
+	if !ok {
+		return nil, false
+	}
+	n, ok := s.nodes[key]
+	return n, ok
+}
+
+// Edge looks up an edge by ID
+func (s *Store) Edge(id int) (EdgeInterface, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ek, ok := s.edgeIDs[id]
+	if !ok {
+		return nil, false
+	}
+	return s.edges[ek].edge, true
+}
+
+// Endpoints returns the source and target nodes of an edge in the store
+func (s *Store) Endpoints(id int) (NodeInterface, NodeInterface, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ek, ok := s.edgeIDs[id]
+	if !ok {
+		return nil, nil, false
+	}
+	return s.nodes[ek.Src], s.nodes[ek.Tgt], true
+}
+
+// Nodes returns every node, ordered by key
+func (s *Store) Nodes() []NodeInterface {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.nodesFor(s.sortedKeys())
+}
+
+// NodesByType returns every node of the given type, ordered by value
+func (s *Store) NodesByType(typ string) []NodeInterface {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keys []NodeKey
+	for key := range s.nodes {
+		if key.Type == typ {
+			keys = append(keys, key)
+		}
+	}
+	sortKeys(keys)
+	return s.nodesFor(keys)
+}
+
+// Edges returns every edge whose endpoints are known, ordered by source, target and type
+func (s *Store) Edges() []EdgeInterface {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]edgeKey, 0, len(s.edges))
+	for ek := range s.edges {
+		keys = append(keys, ek)
+	}
+	sortEdgeKeys(keys)
+
+	edges := make([]EdgeInterface, len(keys))
+	for idx, ek := range keys {
+		edges[idx] = s.edges[ek].edge
+	}
+	return edges
+}
+
+// EachEdge calls fn for every edge whose endpoints are known, along with its source and target nodes, in the same
+// order as Edges(). The store must not be modified from within fn.
+func (s *Store) EachEdge(fn func(e EdgeInterface, src, tgt NodeInterface)) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]edgeKey, 0, len(s.edges))
+	for ek := range s.edges {
+		keys = append(keys, ek)
+	}
+	sortEdgeKeys(keys)
+
+	for _, ek := range keys {
+		fn(s.edges[ek].edge, s.nodes[ek.Src], s.nodes[ek.Tgt])
+	}
+}
+
+// Len returns the number of nodes and edges in the store
+func (s *Store) Len() (nodes int, edges int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.nodes), len(s.edges)
+}
+
+// Degree counts the edges connected to a node in the given direction
+func (s *Store) Degree(key NodeKey, dir Direction) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	degree := 0
+	if dir != Incoming {
+		degree += len(s.out[key])
+	}
+	if dir != Outgoing {
+		degree += len(s.in[key])
+	}
+	return degree
+}
+
+// Neighbours returns the nodes directly connected to a node in the given direction, ordered by key
+func (s *Store) Neighbours(key NodeKey, dir Direction) []NodeInterface {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seen := map[NodeKey]bool{}
+	for _, hop := range s.hops(key, dir) {
+		seen[hop.node] = true
+	}
+	keys := make([]NodeKey, 0, len(seen))
+	for k := range seen {
+		keys = append(keys, k)
+	}
+	sortKeys(keys)
+	return s.nodesFor(keys)
+}
+
+// Expand returns every node within k hops of a node (including the node itself), ordered by key
+func (s *Store) Expand(key NodeKey, k int, dir Direction) []NodeInterface {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.nodes[key]; !ok {
+		return nil
+	}
+
+	seen := map[NodeKey]bool{key: true}
+	frontier := []NodeKey{key}
+	for depth := 0; depth < k && len(frontier) > 0; depth++ {
+		var next []NodeKey
+		for _, cur := range frontier {
+			for _, hop := range s.hops(cur, dir) {
+				if !seen[hop.node] {
+					seen[hop.node] = true
+					next = append(next, hop.node)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	keys := make([]NodeKey, 0, len(seen))
+	for k := range seen {
+		keys = append(keys, k)
+	}
+	sortKeys(keys)
+	return s.nodesFor(keys)
+}
+
+// ShortestPath finds the path with the fewest edges between two nodes and returns it as a chain. Edges are returned
+// as stored, so a path followed against an edge's direction (using Incoming or Both) keeps the edge's own endpoints.
+// The path from a node to itself is a chain of just that node, without following any edges.
+func (s *Store) ShortestPath(from, to NodeKey, dir Direction) (ChainInterface, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.nodes[from]; !ok {
+		return nil, fmt.Errorf("node %s not in store", from)
+	}
+	if _, ok := s.nodes[to]; !ok {
+		return nil, fmt.Errorf("node %s not in store", to)
+	}
+	if from == to {
+		return chain{elements: []interface{}{s.nodes[from]}}, nil
+	}
+
+	type step struct {
+		prev NodeKey
+		edge edgeKey
+	}
+	visited := map[NodeKey]*step{from: nil}
+	frontier := []NodeKey{from}
+
+	for len(frontier) > 0 {
+		if _, found := visited[to]; found {
+			break
+		}
+		var next []NodeKey
+		for _, cur := range frontier {
+			for _, hop := range s.hops(cur, dir) {
+				if _, ok := visited[hop.node]; !ok {
+					visited[hop.node] = &step{prev: cur, edge: hop.edge}
+					next = append(next, hop.node)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	if _, found := visited[to]; !found {
+		return nil, fmt.Errorf("no path from %s to %s", from, to)
+	}
+
+	elements := []interface{}{s.nodes[to]}
+	for cur := to; visited[cur] != nil; cur = visited[cur].prev {
+		st := visited[cur]
+		elements = append([]interface{}{s.nodes[st.prev], s.edges[st.edge].edge}, elements...)
+	}
+
+	return CreateChain(elements...)
+}
+
+// ConnectedComponents groups nodes which are connected ignoring edge direction. Components are ordered by size
+// (largest first) and their nodes by key.
+func (s *Store) ConnectedComponents() [][]NodeInterface {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seen := map[NodeKey]bool{}
+	var components [][]NodeKey
+
+	for _, start := range s.sortedKeys() {
+		if seen[start] {
+			continue
+		}
+		seen[start] = true
+		component := []NodeKey{start}
+		for idx := 0; idx < len(component); idx++ {
+			for _, hop := range s.hops(component[idx], Both) {
+				if !seen[hop.node] {
+					seen[hop.node] = true
+					component = append(component, hop.node)
+				}
+			}
+		}
+		sortKeys(component)
+		components = append(components, component)
+	}
+
+	sort.SliceStable(components, func(i, j int) bool { return len(components[i]) > len(components[j]) })
+
+	result := make([][]NodeInterface, len(components))
+	for idx, component := range components {
+		result[idx] = s.nodesFor(component)
+	}
+	return result
+}
+
+// Private helpers. Callers must hold the lock.
+
+type hop struct {
+	node NodeKey
+	edge edgeKey
+}
+
+// hops lists the (neighbour, edge) pairs of a node, ordered so traversals are deterministic
+func (s *Store) hops(key NodeKey, dir Direction) []hop {
+	var hops []hop
+	if dir != Incoming {
+		for ek := range s.out[key] {
+			hops = append(hops, hop{node: ek.Tgt, edge: ek})
+		}
+	}
+	if dir != Outgoing {
+		for ek := range s.in[key] {
+			hops = append(hops, hop{node: ek.Src, edge: ek})
+		}
+	}
+	sort.Slice(hops, func(i, j int) bool {
+		if hops[i].node != hops[j].node {
+			return lessKey(hops[i].node, hops[j].node)
+		}
+		return lessEdgeKey(hops[i].edge, hops[j].edge)
+	})
+	return hops
+}
+
+func (s *Store) endpoint(n NodeInterface, id int) (nodeRef, error) {
+	if n != nil {
+		if n.GetType() != "" && n.GetValue() != "" {
+			return nodeRef{ID: n.GetID(), Key: s.addNode(n, false), HasKey: true}, nil
+		}
+		id = n.GetID()
+	}
+	if id == 0 {
+		return nodeRef{}, fmt.Errorf("edge endpoint has neither a node nor an ID")
+	}
+	if key, ok := s.byID[id]; ok {
+		return nodeRef{ID: id, Key: key, HasKey: true}, nil
+	}
+	return nodeRef{ID: id}, nil
+}
+
+func (s *Store) addEdge(se *storedEdge) {
+	e := se.edge
+	if !se.src.HasKey || !se.tgt.HasKey {
+		s.pending[edgeKey{ID: e.GetID(), Type: e.GetType(), Value: e.GetValue(),
+			Src: NodeKey{Value: strconv.Itoa(se.src.ID)}, Tgt: NodeKey{Value: strconv.Itoa(se.tgt.ID)}}] = se
+		return
+	}
+
+	ek := edgeKey{ID: e.GetID()}
+	if ek.ID == 0 {
+		ek = edgeKey{Src: se.src.Key, Tgt: se.tgt.Key, Type: e.GetType(), Value: e.GetValue()}
+	}
+	// keep the endpoints on the key so adjacency lookups don't need to dereference the edge
+	ek.Src, ek.Tgt = se.src.Key, se.tgt.Key
+
+	if old, ok := s.edgeIDs[e.GetID()]; ok && e.GetID() != 0 {
+		s.removeEdge(old)
+	}
+
+	s.edges[ek] = se
+	if e.GetID() != 0 {
+		s.edgeIDs[e.GetID()] = ek
+	}
+	if s.out[ek.Src] == nil {
+		s.out[ek.Src] = map[edgeKey]bool{}
+	}
+	if s.in[ek.Tgt] == nil {
+		s.in[ek.Tgt] = map[edgeKey]bool{}
+	}
+	s.out[ek.Src][ek] = true
+	s.in[ek.Tgt][ek] = true
+}
+
+func (s *Store) removeEdge(ek edgeKey) {
+	delete(s.edges, ek)
+	delete(s.out[ek.Src], ek)
+	delete(s.in[ek.Tgt], ek)
+	if ek.ID != 0 {
+		delete(s.edgeIDs, ek.ID)
+	}
+}
+
+func (s *Store) resolvePending(id int) {
+	for pk, se := range s.pending {
+		if !se.src.HasKey && se.src.ID == id {
+			se.src = nodeRef{ID: id, Key: s.byID[id], HasKey: true}
+		}
+		if !se.tgt.HasKey && se.tgt.ID == id {
+			se.tgt = nodeRef{ID: id, Key: s.byID[id], HasKey: true}
+		}
+		if se.src.HasKey && se.tgt.HasKey {
+			delete(s.pending, pk)
+			s.addEdge(se)
+		}
+	}
+}
+
+func (s *Store) sortedKeys() []NodeKey {
+	keys := make([]NodeKey, 0, len(s.nodes))
+	for key := range s.nodes {
+		keys = append(keys, key)
+	}
+	sortKeys(keys)
+	return keys
+}
+
+func (s *Store) nodesFor(keys []NodeKey) []NodeInterface {
+	nodes := make([]NodeInterface, 0, len(keys))
+	for _, key := range keys {
+		if n, ok := s.nodes[key]; ok {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+// edgeEndpointID returns the srcID or tgtID of an edge created by this package
+func edgeEndpointID(e EdgeInterface, source bool) int {
+	ee, ok := e.(*edge)
+	if !ok {
+		return 0
+	}
+	ref := ee.TargetId
+	if source {
+		ref = ee.SourceId
+	}
+	id, _ := strconv.Atoi(ref)
+	return id
+}
+
+func lessKey(a, b NodeKey) bool {
+	if a.Type != b.Type {
+		return a.Type < b.Type
+	}
+	return a.Value < b.Value
+}
+
+func lessEdgeKey(a, b edgeKey) bool {
+	if a.Src != b.Src {
+		return lessKey(a.Src, b.Src)
+	}
+	if a.Tgt != b.Tgt {
+		return lessKey(a.Tgt, b.Tgt)
+	}
+	if a.Type != b.Type {
+		return a.Type < b.Type
+	}
+	if a.Value != b.Value {
+		return a.Value < b.Value
+	}
+	return a.ID < b.ID
+}
+
+func sortKeys(keys []NodeKey) {
+	sort.Slice(keys, func(i, j int) bool { return lessKey(keys[i], keys[j]) })
+}
+
+func sortEdgeKeys(keys []edgeKey) {
+	sort.Slice(keys, func(i, j int) bool { return lessEdgeKey(keys[i], keys[j]) })
+}
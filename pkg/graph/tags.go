@@ -0,0 +1,230 @@
+// Struct tag handling shared by Node(), Edge() and the decoders which turn nodes and edges back into structs.
+//
+// By default every exported field which is not a key field (ID, Type, Value, ...) is stored as a property under its
+// Go field name. The `lg` struct tag can be used to change this:
+//
+//	Foo  string `lg:"foo"`           // stored as the property "foo"
+//	Bar  string `lg:"bar,omitempty"` // not stored when it holds its zero value
+//	Baz  string `lg:"-"`             // never stored
+//	Kind string `lg:"type"`          // used as the Type key field
+//	Name string `lg:"value"`         // used as the Value key field
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const lgTagName = "lg"
+
+// Untagged field names which are treated as key fields rather than properties
+var (
+	nodeKeyFields = map[string]string{
+		"ID":    "ID",
+		"Type":  "type",
+		"Value": "value",
+	}
+	edgeKeyFields = map[string]string{
+		"ID":     "ID",
+		"Type":   "type",
+		"Value":  "value",
+		"Source": "src",
+		"Target": "tgt",
+	}
+)
+
+// Tag names which mark a field as a key field
+var (
+	nodeKeyTags = map[string]bool{"ID": true, "type": true, "value": true, "last_modified": true}
+	edgeKeyTags = map[string]bool{"ID": true, "type": true, "value": true, "last_modified": true,
+		"src": true, "tgt": true, "srcID": true, "tgtID": true}
+)
+
+// lgField is the parsed form of a struct field and its `lg` tag
+type lgField struct {
+	Name      string // property name, or the key field name if IsKey is set
+	IsKey     bool
+	OmitEmpty bool
+	Skip      bool
+	Tagged    bool
+}
+
+// parseLGField resolves the property or key name of a struct field. keyFields maps untagged Go field names to key
+// names and keyTags lists the tag names which mark a key field.
+func parseLGField(field reflect.StructField, keyFields map[string]string, keyTags map[string]bool) lgField {
+	f := lgField{Name: field.Name}
+
+	tag, ok := field.Tag.Lookup(lgTagName)
+	if !ok {
+		if key, isKey := keyFields[field.Name]; isKey {
+			f.Name = key
+			f.IsKey = true
+		}
+		return f
+	}
+
+	if tag == "-" {
+		f.Skip = true
+		return f
+	}
+
+	parts := strings.Split(tag, ",")
+	f.Tagged = parts[0] != ""
+	if f.Tagged {
+		f.Name = parts[0]
+	} else if key, isKey := keyFields[field.Name]; isKey {
+		f.Name = key
+		f.IsKey = true
+	}
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			f.OmitEmpty = true
+		}
+	}
+
+	if f.Tagged && keyTags[f.Name] {
+		f.IsKey = true
+	}
+
+	return f
+}
+
+// assignValue sets dst to src. Values which came back from the server (float64, map[string]interface{}, ...) are
+// not directly assignable to typed struct fields, so they are passed through JSON as a fallback.
+func assignValue(dst reflect.Value, src interface{}) error {
+	if src == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+
+	sValue := reflect.ValueOf(src)
+	if sValue.Type().AssignableTo(dst.Type()) {
+		dst.Set(sValue)
+		return nil
+	}
+
+	raw, err := json.Marshal(src)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, dst.Addr().Interface())
+}
+
+// structTarget validates that out is a non-nil pointer to a struct and returns the struct value
+func structTarget(out interface{}) (reflect.Value, error) {
+	ptr := reflect.ValueOf(out)
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() {
+		return reflect.Value{}, fmt.Errorf("target must be a non-nil pointer to a struct")
+	}
+	sValue := ptr.Elem()
+	if sValue.Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("target must be a non-nil pointer to a struct")
+	}
+	return sValue, nil
+}
+
+// structSource dereferences obj and checks that it is a struct which can be reflected into a node or edge
+func structSource(obj interface{}) (reflect.Value, error) {
+	sValue := reflect.ValueOf(obj)
+	for sValue.Kind() == reflect.Ptr {
+		if sValue.IsNil() {
+			return reflect.Value{}, fmt.Errorf("nil pointer")
+		}
+		sValue = sValue.Elem()
+	}
+	if sValue.Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("got %s", sValue.Kind())
+	}
+	return sValue, nil
+}
+
+// embeddedStruct returns the struct behind an embedded field, following non-nil pointers
+func embeddedStruct(value reflect.Value) (reflect.Value, bool) {
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return reflect.Value{}, false
+		}
+		value = value.Elem()
+	}
+	return value, value.Kind() == reflect.Struct
+}
+
+// embeddedTarget is the decoding counterpart of embeddedStruct. Nil pointers to embedded structs are allocated.
+func embeddedTarget(value reflect.Value) (reflect.Value, bool) {
+	if value.Kind() == reflect.Ptr {
+		if value.Type().Elem().Kind() != reflect.Struct {
+			return reflect.Value{}, false
+		}
+		if value.IsNil() {
+			if !value.CanSet() {
+				return reflect.Value{}, false
+			}
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+		value = value.Elem()
+	}
+	return value, value.Kind() == reflect.Struct
+}
+
+// coerceID reads an ID from an integer or numeric string field. Anything else is treated as a new element (0).
+func coerceID(value reflect.Value) int {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(value.Uint())
+	case reflect.String:
+		id, _ := strconv.Atoi(value.String())
+		return id
+	}
+	return 0
+}
+
+// coerceIDString reads a srcID/tgtID reference from a string or integer field
+func coerceIDString(value reflect.Value) string {
+	switch value.Kind() {
+	case reflect.String:
+		return value.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if id := coerceID(value); id != 0 {
+			return strconv.Itoa(id)
+		}
+	}
+	return ""
+}
+
+// idString reads a srcID/tgtID reference decoded from JSON, where the server sends numbers
+func idString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.Itoa(int(v))
+	case int:
+		return strconv.Itoa(v)
+	}
+	return ""
+}
+
+// setID stores an ID in an int or string field, mirroring the coercion done by Node() and Edge()
+func setID(dst reflect.Value, id int) {
+	switch dst.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		dst.SetInt(int64(id))
+	case reflect.String:
+		if id != 0 {
+			dst.SetString(fmt.Sprint(id))
+		}
+	}
+}
+
+// setString stores s in a string field and leaves fields of any other kind untouched
+func setString(dst reflect.Value, s string) {
+	if dst.Kind() == reflect.String {
+		dst.SetString(s)
+	}
+}
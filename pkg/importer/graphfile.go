@@ -0,0 +1,450 @@
+package importer
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/skyleronken/lemonclient/pkg/graph"
+)
+
+// Rule gives GraphML and GEXF elements whose attributes match an LG type and value. Rules are tried in order and
+// the first one which matches, and yields both a type and a value, is used. Elements no rule matches are given the
+// default type (see WithDefaultTypes) and the value described for ValueFrom. Edges may have an empty value.
+//
+// Attributes are named by their GraphML attr.name or GEXF title. GEXF labels are available as the "label" attribute.
+// Attributes which are not used for the type or value become properties, except for "ID" and "label".
+//
+// The rule {TypeFrom: "type"} is always tried last, so files written by the export package import without any rules.
+type Rule struct {
+	When      map[string]string `json:"when,omitempty" yaml:"when,omitempty"`             // attribute name to a regexp its whole value must match
+	Type      string            `json:"type,omitempty" yaml:"type,omitempty"`             // fixed type
+	TypeFrom  string            `json:"type_from,omitempty" yaml:"type_from,omitempty"`   // attribute holding the type, if Type is empty
+	ValueFrom string            `json:"value_from,omitempty" yaml:"value_from,omitempty"` // attribute holding the value; "value", then "label", then the element id by default
+}
+
+type compiledRule struct {
+	Rule
+	when map[string]*regexp.Regexp
+}
+
+func compileRules(kind string, rules []Rule) ([]compiledRule, error) {
+	compiled := make([]compiledRule, 0, len(rules)+1)
+	for i, r := range append(rules, Rule{TypeFrom: "type"}) {
+		cr := compiledRule{Rule: r, when: map[string]*regexp.Regexp{}}
+		for attr, pattern := range r.When {
+			re, err := regexp.Compile("^(?:" + pattern + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("%s rule %d: %w", kind, i, err)
+			}
+			cr.when[attr] = re
+		}
+		compiled = append(compiled, cr)
+	}
+	return compiled, nil
+}
+
+// graphElement is a node or edge read from a graph file, with its attributes converted to their declared types
+type graphElement struct {
+	id     string
+	source string
+	target string
+	attrs  map[string]interface{}
+}
+
+// resolve applies the rules to el, returning its type and value along with the attributes they were taken from.
+// Edges may have an empty value.
+func resolve(el graphElement, rules []compiledRule, defaultType string, isEdge bool) (string, string, []string, error) {
+	for _, r := range rules {
+		matched := true
+		for attr, re := range r.when {
+			v, ok := el.attrs[attr]
+			if !ok || !re.MatchString(text(v)) {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		typ, used := r.Type, []string{}
+		if typ == "" && r.TypeFrom != "" {
+			typ = text(el.attrs[r.TypeFrom])
+			used = append(used, r.TypeFrom)
+		}
+		value, valueAttr := elementValue(el, r.ValueFrom, isEdge)
+		if typ != "" && (value != "" || isEdge) {
+			return typ, value, append(used, valueAttr), nil
+		}
+	}
+
+	if defaultType == "" {
+		return "", "", nil, fmt.Errorf("no rule gives it a type")
+	}
+	value, valueAttr := elementValue(el, "", isEdge)
+	return defaultType, value, []string{valueAttr}, nil
+}
+
+// elementValue returns the value of el and the attribute it came from. Without from, nodes use their "value" or
+// "label" attribute or their id, and edges their "value" attribute, as labels and ids are rarely meaningful values.
+func elementValue(el graphElement, from string, isEdge bool) (string, string) {
+	if from != "" {
+		return text(el.attrs[from]), from
+	}
+	if isEdge {
+		return text(el.attrs["value"]), "value"
+	}
+	for _, attr := range []string{"value", "label"} {
+		if v := text(el.attrs[attr]); v != "" {
+			return v, attr
+		}
+	}
+	return el.id, ""
+}
+
+// graphImport turns graph file elements into nodes and chains
+type graphImport struct {
+	opts      Opts
+	nodeRules []compiledRule
+	edgeRules []compiledRule
+	result    *Result
+
+	nodes     map[graph.NodeKey]graph.NodeInterface
+	nodeOrder []graph.NodeKey
+	byID      map[string]graph.NodeKey
+	chained   map[graph.NodeKey]bool
+}
+
+func newGraphImport(opts []OptFunc) (*graphImport, error) {
+	o := buildOpts(opts)
+	nodeRules, err := compileRules("node", o.NodeRules)
+	if err != nil {
+		return nil, err
+	}
+	edgeRules, err := compileRules("edge", o.EdgeRules)
+	if err != nil {
+		return nil, err
+	}
+	return &graphImport{
+		opts:      o,
+		nodeRules: nodeRules,
+		edgeRules: edgeRules,
+		result:    &Result{},
+		nodes:     map[graph.NodeKey]graph.NodeInterface{},
+		byID:      map[string]graph.NodeKey{},
+		chained:   map[graph.NodeKey]bool{},
+	}, nil
+}
+
+func (g *graphImport) fail(id string, err error) error {
+	g.result.Errors = append(g.result.Errors, RowError{Element: id, Err: err})
+	if g.opts.MaxErrors > 0 && len(g.result.Errors) >= g.opts.MaxErrors {
+		return ErrTooManyErrors
+	}
+	return nil
+}
+
+// elementProperties returns the attributes of el which were not used for its type or value
+func elementProperties(el graphElement, used []string) map[string]interface{} {
+	props := map[string]interface{}{}
+	for k, v := range el.attrs {
+		props[k] = v
+	}
+	for _, k := range append(used, "ID", "label", "type", "value") {
+		delete(props, k)
+	}
+	return props
+}
+
+func (g *graphImport) run(nodes []graphElement, edges []graphElement) (*Result, error) {
+	for _, el := range nodes {
+		g.result.Rows++
+		if err := g.addNode(el); err != nil {
+			if err := g.fail(el.id, err); err != nil {
+				return g.finish(), err
+			}
+		}
+	}
+
+	// edges are grouped into paths, each becoming one chain: an edge extends the chain ending at its source
+	var paths [][]interface{}
+	tails := map[graph.NodeKey][]int{}
+	for _, el := range edges {
+		g.result.Rows++
+		src, srcOK := g.byID[el.source]
+		tgt, tgtOK := g.byID[el.target]
+		var err error
+		switch {
+		case !srcOK:
+			err = fmt.Errorf("source node %s was not imported", el.source)
+		case !tgtOK:
+			err = fmt.Errorf("target node %s was not imported", el.target)
+		}
+
+		var e graph.EdgeInterface
+		if err == nil {
+			e, err = g.edge(el)
+		}
+		if err != nil {
+			if err := g.fail(el.id, err); err != nil {
+				return g.finish(), err
+			}
+			continue
+		}
+
+		g.chained[src] = true
+		g.chained[tgt] = true
+		if open := tails[src]; len(open) > 0 {
+			idx := open[len(open)-1]
+			tails[src] = open[:len(open)-1]
+			paths[idx] = append(paths[idx], e, g.nodes[tgt])
+			tails[tgt] = append(tails[tgt], idx)
+			continue
+		}
+		paths = append(paths, []interface{}{g.nodes[src], e, g.nodes[tgt]})
+		tails[tgt] = append(tails[tgt], len(paths)-1)
+	}
+
+	for _, p := range paths {
+		chain, err := graph.CreateChain(p...)
+		if err != nil {
+			return g.finish(), err
+		}
+		g.result.Chains = append(g.result.Chains, chain)
+	}
+	return g.finish(), nil
+}
+
+func (g *graphImport) addNode(el graphElement) error {
+	if _, ok := g.byID[el.id]; ok {
+		return fmt.Errorf("duplicate node id")
+	}
+	typ, value, used, err := resolve(el, g.nodeRules, g.opts.DefaultNodeType, false)
+	if err != nil {
+		return err
+	}
+
+	key := graph.NodeKey{Type: typ, Value: value}
+	n, ok := g.nodes[key]
+	if !ok {
+		if n, err = graph.MapToNode(map[string]interface{}{"type": typ, "value": value}); err != nil {
+			return err
+		}
+		g.nodes[key] = n
+		g.nodeOrder = append(g.nodeOrder, key)
+	}
+	g.byID[el.id] = key
+	return mergeProperties(n.GetProperties(), elementProperties(el, used), n.SetProperty)
+}
+
+func (g *graphImport) edge(el graphElement) (graph.EdgeInterface, error) {
+	typ, value, used, err := resolve(el, g.edgeRules, g.opts.DefaultEdgeType, true)
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := graph.Edge(graph.EdgeMembers{Type: typ, Value: value})
+	if err != nil {
+		return nil, err
+	}
+	props := elementProperties(el, used)
+	delete(props, "source")
+	delete(props, "target")
+	if err := mergeProperties(nil, props, e.SetProperty); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (g *graphImport) finish() *Result {
+	for _, key := range g.nodeOrder {
+		if !g.chained[key] {
+			g.result.Nodes = append(g.result.Nodes, g.nodes[key])
+		}
+	}
+	return g.result
+}
+
+// typedAttribute converts the text of an attribute to its declared GraphML or GEXF type, keeping the text if it
+// does not parse
+func typedAttribute(raw string, typ string) interface{} {
+	switch strings.ToLower(typ) {
+	case "int", "integer", "long":
+		if i, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64); err == nil {
+			return i
+		}
+	case "float", "double":
+		if f, err := strconv.ParseFloat(strings.TrimSpace(raw), 64); err == nil {
+			return f
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(strings.TrimSpace(raw)); err == nil {
+			return b
+		}
+	}
+	return raw
+}
+
+type graphMLKey struct {
+	ID       string  `xml:"id,attr"`
+	For      string  `xml:"for,attr"`
+	AttrName string  `xml:"attr.name,attr"`
+	AttrType string  `xml:"attr.type,attr"`
+	Default  *string `xml:"default"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphMLElement struct {
+	ID     string        `xml:"id,attr"`
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLDocument struct {
+	Keys  []graphMLKey `xml:"key"`
+	Graph struct {
+		Nodes []graphMLElement `xml:"node"`
+		Edges []graphMLElement `xml:"edge"`
+	} `xml:"graph"`
+}
+
+// ImportGraphML imports the nodes and edges of the first graph in a GraphML file. Nested graphs and hyperedges
+// are ignored.
+func ImportGraphML(r io.Reader, opts ...OptFunc) (*Result, error) {
+	g, err := newGraphImport(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc graphMLDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid GraphML: %w", err)
+	}
+
+	keys := map[string]graphMLKey{}
+	for _, k := range doc.Keys {
+		if k.AttrName == "" {
+			k.AttrName = k.ID
+		}
+		keys[k.ID] = k
+	}
+
+	convert := func(els []graphMLElement, target string) []graphElement {
+		out := make([]graphElement, len(els))
+		for i, el := range els {
+			attrs := map[string]interface{}{}
+			for _, k := range keys {
+				if k.Default != nil && (k.For == target || k.For == "all") {
+					attrs[k.AttrName] = typedAttribute(*k.Default, k.AttrType)
+				}
+			}
+			for _, d := range el.Data {
+				k, ok := keys[d.Key]
+				if !ok {
+					k = graphMLKey{AttrName: d.Key}
+				}
+				attrs[k.AttrName] = typedAttribute(d.Value, k.AttrType)
+			}
+			out[i] = graphElement{id: el.ID, source: el.Source, target: el.Target, attrs: attrs}
+		}
+		return out
+	}
+
+	return g.run(convert(doc.Graph.Nodes, "node"), convert(doc.Graph.Edges, "edge"))
+}
+
+type gexfAttribute struct {
+	ID      string  `xml:"id,attr"`
+	Title   string  `xml:"title,attr"`
+	Type    string  `xml:"type,attr"`
+	Default *string `xml:"default"`
+}
+
+type gexfAttValue struct {
+	For   string `xml:"for,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type gexfElement struct {
+	ID        string         `xml:"id,attr"`
+	Label     *string        `xml:"label,attr"`
+	Source    string         `xml:"source,attr"`
+	Target    string         `xml:"target,attr"`
+	AttValues []gexfAttValue `xml:"attvalues>attvalue"`
+}
+
+type gexfDocument struct {
+	Graph struct {
+		Attributes []struct {
+			Class      string          `xml:"class,attr"`
+			Attributes []gexfAttribute `xml:"attribute"`
+		} `xml:"attributes"`
+		Nodes []gexfElement `xml:"nodes>node"`
+		Edges []gexfElement `xml:"edges>edge"`
+	} `xml:"graph"`
+}
+
+// ImportGEXF imports the nodes and edges of a GEXF file. Dynamic attributes are read as if they were static.
+func ImportGEXF(r io.Reader, opts ...OptFunc) (*Result, error) {
+	g, err := newGraphImport(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc gexfDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid GEXF: %w", err)
+	}
+
+	attributes := map[string]map[string]gexfAttribute{"node": {}, "edge": {}}
+	for _, group := range doc.Graph.Attributes {
+		class := group.Class
+		if class == "" {
+			class = "node"
+		}
+		if attributes[class] == nil {
+			continue
+		}
+		for _, a := range group.Attributes {
+			if a.Title == "" {
+				a.Title = a.ID
+			}
+			attributes[class][a.ID] = a
+		}
+	}
+
+	convert := func(els []gexfElement, class string) []graphElement {
+		out := make([]graphElement, len(els))
+		for i, el := range els {
+			attrs := map[string]interface{}{}
+			for _, a := range attributes[class] {
+				if a.Default != nil {
+					attrs[a.Title] = typedAttribute(*a.Default, a.Type)
+				}
+			}
+			if el.Label != nil {
+				attrs["label"] = *el.Label
+			}
+			for _, v := range el.AttValues {
+				a, ok := attributes[class][v.For]
+				if !ok {
+					a = gexfAttribute{Title: v.For}
+				}
+				attrs[a.Title] = typedAttribute(v.Value, a.Type)
+			}
+			out[i] = graphElement{id: el.ID, source: el.Source, target: el.Target, attrs: attrs}
+		}
+		return out
+	}
+
+	return g.run(convert(doc.Graph.Nodes, "node"), convert(doc.Graph.Edges, "edge"))
+}
@@ -0,0 +1,528 @@
+// The importer package turns tabular data (CSV and JSONL) and graph files (GraphML and GEXF) into nodes and chains
+// that can seed a job with job.WithNodes and job.WithChains. Which columns become nodes, edges and properties is
+// declared in a Mapping; graph files are mapped with Rules (see graphfile.go).
+//
+// Rows that cannot be imported are reported in Result.Errors along with their line number and do not stop the
+// import. Nodes are deduplicated on (type, value): properties from later rows are added to the first node but do
+// not overwrite values already set. Edges are deduplicated in the same way on their type, value and endpoints.
+package importer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/skyleronken/lemonclient/pkg/graph"
+)
+
+type Opts struct {
+	MaxErrors int // stop importing once this many rows have failed, 0 for no limit
+
+	// GraphML and GEXF only, see Rule
+	NodeRules       []Rule
+	EdgeRules       []Rule
+	DefaultNodeType string // type of nodes no rule matches, nodes are skipped with an error if empty
+	DefaultEdgeType string // type of edges no rule matches, edges are skipped with an error if empty
+}
+
+type OptFunc func(*Opts)
+
+func defaultOpts() Opts {
+	return Opts{}
+}
+
+func WithMaxErrors(max int) OptFunc {
+	return func(opts *Opts) {
+		opts.MaxErrors = max
+	}
+}
+
+// WithNodeRules sets the rules giving imported GraphML and GEXF nodes their LG type and value
+func WithNodeRules(rules ...Rule) OptFunc {
+	return func(opts *Opts) {
+		opts.NodeRules = rules
+	}
+}
+
+// WithEdgeRules sets the rules giving imported GraphML and GEXF edges their LG type and value
+func WithEdgeRules(rules ...Rule) OptFunc {
+	return func(opts *Opts) {
+		opts.EdgeRules = rules
+	}
+}
+
+// WithDefaultTypes sets the types given to GraphML and GEXF nodes and edges which match no rule
+func WithDefaultTypes(nodeType string, edgeType string) OptFunc {
+	return func(opts *Opts) {
+		opts.DefaultNodeType = nodeType
+		opts.DefaultEdgeType = edgeType
+	}
+}
+
+// ErrTooManyErrors is returned when more rows than allowed by WithMaxErrors fail to import
+var ErrTooManyErrors = errors.New("too many rows failed to import")
+
+// RowError describes a row, or a GraphML/GEXF element, that could not be imported
+type RowError struct {
+	Line    int    // line of the input the row starts on
+	Element string // id of the node or edge, for GraphML and GEXF
+	Err     error
+}
+
+func (e RowError) Error() string {
+	if e.Element != "" {
+		return fmt.Sprintf("element %s: %v", e.Element, e.Err)
+	}
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+func (e RowError) Unwrap() error { return e.Err }
+
+// Result holds everything imported from one input
+type Result struct {
+	Nodes  []graph.NodeInterface  // unique nodes which are not part of any chain
+	Chains []graph.ChainInterface // one node-edge-node chain per unique edge
+	Errors []RowError
+	Rows   int // rows read, including those which failed
+}
+
+// Err joins the row errors, or returns nil if every row was imported
+func (r *Result) Err() error {
+	errs := make([]error, len(r.Errors))
+	for i := range r.Errors {
+		errs[i] = r.Errors[i]
+	}
+	return errors.Join(errs...)
+}
+
+// lookup returns the value of a column in the current row, or nil if the row does not have it
+type lookup func(column string) interface{}
+
+type edgeKey struct {
+	source graph.NodeKey
+	target graph.NodeKey
+	typ    string
+	value  string
+}
+
+// builder accumulates nodes and edges across rows
+type builder struct {
+	mapping Mapping
+	opts    Opts
+	result  *Result
+
+	nodes     map[graph.NodeKey]graph.NodeInterface
+	nodeOrder []graph.NodeKey
+	chained   map[graph.NodeKey]bool
+	edges     map[edgeKey]graph.EdgeInterface
+}
+
+func buildOpts(opts []OptFunc) Opts {
+	o := defaultOpts()
+	for _, fn := range opts {
+		fn(&o)
+	}
+	return o
+}
+
+func newBuilder(m Mapping, opts []OptFunc) (*builder, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	o := buildOpts(opts)
+	return &builder{
+		mapping: m,
+		opts:    o,
+		result:  &Result{},
+		nodes:   map[graph.NodeKey]graph.NodeInterface{},
+		chained: map[graph.NodeKey]bool{},
+		edges:   map[edgeKey]graph.EdgeInterface{},
+	}, nil
+}
+
+// fail records a row error, returning ErrTooManyErrors once the limit is reached
+func (b *builder) fail(line int, err error) error {
+	b.result.Errors = append(b.result.Errors, RowError{Line: line, Err: err})
+	if b.opts.MaxErrors > 0 && len(b.result.Errors) >= b.opts.MaxErrors {
+		return ErrTooManyErrors
+	}
+	return nil
+}
+
+type rowNode struct {
+	key        graph.NodeKey
+	properties map[string]interface{}
+}
+
+type rowEdge struct {
+	key        edgeKey
+	properties map[string]interface{}
+}
+
+// row maps a single row. Nothing is added unless the whole row is valid.
+func (b *builder) row(get lookup) error {
+	mapped := map[string]*rowNode{}
+	var nodes []*rowNode
+
+	for _, nm := range b.mapping.Nodes {
+		value := text(get(nm.Value))
+		typ := nm.Type
+		if nm.TypeColumn != "" {
+			typ = text(get(nm.TypeColumn))
+		}
+		if value == "" || typ == "" {
+			if nm.Optional {
+				continue
+			}
+			if value == "" {
+				return fmt.Errorf("node %s: column %q is empty", nm.name(), nm.Value)
+			}
+			return fmt.Errorf("node %s: column %q is empty", nm.name(), nm.TypeColumn)
+		}
+
+		props, err := properties(get, nm.Properties)
+		if err != nil {
+			return fmt.Errorf("node %s: %w", nm.name(), err)
+		}
+		n := &rowNode{key: graph.NodeKey{Type: typ, Value: value}, properties: props}
+		mapped[nm.name()] = n
+		nodes = append(nodes, n)
+	}
+
+	var edges []rowEdge
+	for i, em := range b.mapping.Edges {
+		src, tgt := mapped[em.Source], mapped[em.Target]
+		if src == nil || tgt == nil {
+			continue
+		}
+		props, err := properties(get, em.Properties)
+		if err != nil {
+			return fmt.Errorf("edge %d: %w", i, err)
+		}
+		value := ""
+		if em.ValueColumn != "" {
+			value = text(get(em.ValueColumn))
+		}
+		edges = append(edges, rowEdge{
+			key:        edgeKey{source: src.key, target: tgt.key, typ: em.Type, value: value},
+			properties: props,
+		})
+	}
+
+	for _, n := range nodes {
+		if err := b.addNode(n); err != nil {
+			return err
+		}
+	}
+	for _, e := range edges {
+		if err := b.addEdge(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *builder) addNode(n *rowNode) error {
+	existing, ok := b.nodes[n.key]
+	if !ok {
+		node, err := graph.MapToNode(map[string]interface{}{"type": n.key.Type, "value": n.key.Value})
+		if err != nil {
+			return err
+		}
+		b.nodes[n.key] = node
+		b.nodeOrder = append(b.nodeOrder, n.key)
+		existing = node
+	}
+	return mergeProperties(existing.GetProperties(), n.properties, existing.SetProperty)
+}
+
+func (b *builder) addEdge(e rowEdge) error {
+	existing, ok := b.edges[e.key]
+	if !ok {
+		edge, err := graph.Edge(graph.EdgeMembers{Type: e.key.typ, Value: e.key.value})
+		if err != nil {
+			return err
+		}
+		chain, err := graph.CreateChain(b.nodes[e.key.source], edge, b.nodes[e.key.target])
+		if err != nil {
+			return err
+		}
+		b.edges[e.key] = edge
+		b.chained[e.key.source] = true
+		b.chained[e.key.target] = true
+		b.result.Chains = append(b.result.Chains, chain)
+		existing = edge
+	}
+	return mergeProperties(existing.GetProperties(), e.properties, existing.SetProperty)
+}
+
+// mergeProperties sets the properties which are not already present
+func mergeProperties(current, props map[string]interface{}, set func(string, interface{}) error) error {
+	for k, v := range props {
+		if _, ok := current[k]; ok {
+			continue
+		}
+		if err := set(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *builder) finish() *Result {
+	for _, key := range b.nodeOrder {
+		if !b.chained[key] {
+			b.result.Nodes = append(b.result.Nodes, b.nodes[key])
+		}
+	}
+	return b.result
+}
+
+// ImportCSV imports a CSV file whose first row holds the column names
+func ImportCSV(r io.Reader, m Mapping, opts ...OptFunc) (*Result, error) {
+	b, err := newBuilder(m, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	cr := csv.NewReader(r)
+	if m.Delimiter != "" {
+		cr.Comma = []rune(m.Delimiter)[0]
+	}
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	var missing []string
+	for _, c := range m.columns() {
+		if _, ok := columns[c]; !ok {
+			missing = append(missing, strconv.Quote(c))
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("CSV header is missing columns %s", strings.Join(missing, ", "))
+	}
+
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		b.result.Rows++
+
+		var pe *csv.ParseError
+		if errors.As(err, &pe) {
+			if err := b.fail(pe.StartLine, pe.Err); err != nil {
+				return b.finish(), err
+			}
+			continue
+		} else if err != nil {
+			return b.finish(), err
+		}
+
+		line, _ := cr.FieldPos(0)
+		err = b.row(func(column string) interface{} {
+			if idx := columns[column]; idx < len(record) {
+				return record[idx]
+			}
+			return nil
+		})
+		if err != nil {
+			if err := b.fail(line, err); err != nil {
+				return b.finish(), err
+			}
+		}
+	}
+
+	return b.finish(), nil
+}
+
+// ImportJSONL imports newline delimited JSON objects. Columns name top level keys, or nested keys using dots
+// ("whois.registrar"). Blank lines are ignored.
+func ImportJSONL(r io.Reader, m Mapping, opts ...OptFunc) (*Result, error) {
+	b, err := newBuilder(m, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		raw := strings.TrimSpace(scanner.Text())
+		if raw == "" {
+			continue
+		}
+		b.result.Rows++
+
+		var obj map[string]interface{}
+		err := json.Unmarshal([]byte(raw), &obj)
+		if err == nil {
+			err = b.row(func(column string) interface{} {
+				return lookupPath(obj, column)
+			})
+		}
+		if err != nil {
+			if err := b.fail(line, err); err != nil {
+				return b.finish(), err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return b.finish(), fmt.Errorf("line %d: %w", line+1, err)
+	}
+
+	return b.finish(), nil
+}
+
+// columns lists every column referred to by the mapping
+func (m Mapping) columns() []string {
+	var cols []string
+	seen := map[string]bool{}
+	add := func(props []PropertyMapping, more ...string) {
+		for _, p := range props {
+			more = append(more, p.Column)
+		}
+		for _, c := range more {
+			if c != "" && !seen[c] {
+				seen[c] = true
+				cols = append(cols, c)
+			}
+		}
+	}
+	for _, n := range m.Nodes {
+		add(n.Properties, n.Value, n.TypeColumn)
+	}
+	for _, e := range m.Edges {
+		add(e.Properties, e.ValueColumn)
+	}
+	return cols
+}
+
+func lookupPath(obj map[string]interface{}, path string) interface{} {
+	if v, ok := obj[path]; ok {
+		return v
+	}
+	var current interface{} = obj
+	for _, part := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = m[part]
+	}
+	return current
+}
+
+func properties(get lookup, mappings []PropertyMapping) (map[string]interface{}, error) {
+	props := map[string]interface{}{}
+	for _, p := range mappings {
+		v := get(p.Column)
+		if isEmpty(v) {
+			continue
+		}
+		converted, err := convert(v, p.Kind)
+		if err != nil {
+			return nil, fmt.Errorf("property %s: %w", p.Name, err)
+		}
+		props[p.Name] = converted
+	}
+	return props, nil
+}
+
+func isEmpty(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	s, ok := v.(string)
+	return ok && strings.TrimSpace(s) == ""
+}
+
+// text returns a cell as a string, as used for types and values
+func text(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(val)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	}
+	raw, _ := json.Marshal(v)
+	return string(raw)
+}
+
+func convert(v interface{}, kind string) (interface{}, error) {
+	s, isString := v.(string)
+	if isString {
+		s = strings.TrimSpace(s)
+	}
+
+	switch kind {
+	case KindAuto:
+		return v, nil
+	case KindString:
+		return text(v), nil
+	case KindInt:
+		switch val := v.(type) {
+		case string:
+			i, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%q is not an int", val)
+			}
+			return i, nil
+		case float64:
+			if val == float64(int64(val)) {
+				return int64(val), nil
+			}
+		}
+		return nil, fmt.Errorf("%v is not an int", v)
+	case KindFloat:
+		switch v.(type) {
+		case string:
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%q is not a float", s)
+			}
+			return f, nil
+		case float64:
+			return v, nil
+		}
+		return nil, fmt.Errorf("%v is not a float", v)
+	case KindBool:
+		switch v.(type) {
+		case string:
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return nil, fmt.Errorf("%q is not a bool", s)
+			}
+			return b, nil
+		case bool:
+			return v, nil
+		}
+		return nil, fmt.Errorf("%v is not a bool", v)
+	case KindJSON:
+		if !isString {
+			return v, nil
+		}
+		var out interface{}
+		if err := json.Unmarshal([]byte(s), &out); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("unknown kind %q", kind)
+}
@@ -0,0 +1,240 @@
+package importer
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/skyleronken/lemonclient/pkg/export"
+	"github.com/skyleronken/lemonclient/pkg/graph"
+	"github.com/stretchr/testify/assert"
+)
+
+const testMapping = `
+nodes:
+  - name: domain
+    type: domain
+    value: indicator
+    properties:
+      - {name: first_seen, column: date}
+      - {name: score, column: score, kind: int}
+  - name: ip
+    type: ip
+    value: resolved_ip
+    optional: true
+edges:
+  - type: resolves
+    source: domain
+    target: ip
+    properties:
+      - {name: feed, column: feed}
+`
+
+func Test_LoadMapping(t *testing.T) {
+	m, err := LoadMapping(strings.NewReader(testMapping))
+	assert.NoError(t, err)
+	assert.Len(t, m.Nodes, 2)
+	assert.Equal(t, "resolves", m.Edges[0].Type)
+
+	// JSON is accepted as well
+	m, err = LoadMapping(strings.NewReader(`{"nodes": [{"type": "domain", "value": "d"}]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "domain", m.Nodes[0].name())
+
+	_, err = LoadMapping(strings.NewReader(`{"nodes": [{"type": "domain", "value": "d", "colour": "red"}]}`))
+	assert.Error(t, err)
+
+	err = Mapping{
+		Nodes: []NodeMapping{
+			{Type: "domain", TypeColumn: "t", Value: "d", Properties: []PropertyMapping{{Name: "ID", Column: "x"}}},
+			{Name: "domain", Value: "d", Properties: []PropertyMapping{{Name: "a", Column: "b", Kind: "date"}}},
+		},
+		Edges: []EdgeMapping{{Source: "domain", Target: "ip"}},
+	}.Validate()
+	assert.Error(t, err)
+	for _, problem := range []string{
+		"exactly one of type or type_column",
+		`duplicate name "domain"`,
+		`property "ID" is reserved`,
+		`unknown kind "date"`,
+		"edge 0: type is required",
+		`edge 0: unknown node "ip"`,
+	} {
+		assert.Contains(t, err.Error(), problem)
+	}
+}
+
+func Test_ImportCSV(t *testing.T) {
+	m, err := LoadMapping(strings.NewReader(testMapping))
+	assert.NoError(t, err)
+
+	input := `indicator,resolved_ip,date,score,feed
+a.com,1.1.1.1,2024-01-01,5,feed1
+a.com,2.2.2.2,,7,feed2
+b.com,,2024-01-02,,
+c.com,1.1.1.1,,high,
+,3.3.3.3,,,
+a.com,1.1.1.1,,,feed3
+`
+	res, err := ImportCSV(strings.NewReader(input), *m)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, res.Rows)
+
+	assert.Len(t, res.Errors, 2)
+	assert.Equal(t, 5, res.Errors[0].Line)
+	assert.Contains(t, res.Errors[0].Error(), `line 5: node domain: property score: "high" is not an int`)
+	assert.Equal(t, 6, res.Errors[1].Line)
+	assert.Error(t, res.Err())
+
+	// b.com has no ip so it is not part of a chain
+	assert.Len(t, res.Nodes, 1)
+	assert.Equal(t, "b.com", res.Nodes[0].GetValue())
+	assert.Equal(t, map[string]interface{}{"first_seen": "2024-01-02"}, res.Nodes[0].GetProperties())
+
+	// the repeated a.com -> 1.1.1.1 edge is merged
+	assert.Len(t, res.Chains, 2)
+	first := res.Chains[0].GetElements()
+	domain := first[0].(graph.NodeInterface)
+	assert.Equal(t, "a.com", domain.GetValue())
+	assert.Equal(t, map[string]interface{}{"first_seen": "2024-01-01", "score": int64(5)}, domain.GetProperties())
+	assert.Equal(t, map[string]interface{}{"feed": "feed1"}, first[1].(graph.EdgeInterface).GetProperties())
+
+	// both chains share the deduplicated a.com node
+	assert.Same(t, domain, res.Chains[1].GetElements()[0])
+	assert.Equal(t, "2.2.2.2", res.Chains[1].GetElements()[2].(graph.NodeInterface).GetValue())
+
+	_, err = ImportCSV(strings.NewReader("indicator,date\n"), *m)
+	assert.ErrorContains(t, err, `CSV header is missing columns "score", "resolved_ip", "feed"`)
+
+	res, err = ImportCSV(strings.NewReader(input), *m, WithMaxErrors(1))
+	assert.True(t, errors.Is(err, ErrTooManyErrors))
+	assert.Len(t, res.Errors, 1)
+}
+
+func Test_ImportJSONL(t *testing.T) {
+	m := Mapping{
+		Nodes: []NodeMapping{{
+			Name:       "indicator",
+			TypeColumn: "kind",
+			Value:      "value",
+			Properties: []PropertyMapping{
+				{Name: "registrar", Column: "whois.registrar"},
+				{Name: "asn", Column: "asn", Kind: KindString},
+			},
+		}},
+	}
+
+	input := `{"kind": "domain", "value": "a.com", "whois": {"registrar": "x"}}
+
+{"kind": "asn", "value": 13335, "asn": 13335}
+{"kind": "domain", "value": "a.com", "whois": {"registrar": "y"}}
+{"value": "b.com"}
+not json
+`
+	res, err := ImportJSONL(strings.NewReader(input), m)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, res.Rows)
+	assert.Len(t, res.Chains, 0)
+
+	assert.Len(t, res.Nodes, 2)
+	assert.Equal(t, map[string]interface{}{"registrar": "x"}, res.Nodes[0].GetProperties())
+	assert.Equal(t, "13335", res.Nodes[1].GetValue())
+	assert.Equal(t, map[string]interface{}{"asn": "13335"}, res.Nodes[1].GetProperties())
+
+	assert.Len(t, res.Errors, 2)
+	assert.Equal(t, 5, res.Errors[0].Line)
+	assert.Contains(t, res.Errors[0].Error(), `column "kind" is empty`)
+	assert.Equal(t, 6, res.Errors[1].Line)
+}
+
+func Test_ImportGraphMLRoundTrip(t *testing.T) {
+	a, _ := graph.JsonToNode([]byte(`{"ID": 1, "type": "domain", "value": "a.com", "score": 3, "seen": true}`))
+	b, _ := graph.JsonToNode([]byte(`{"ID": 2, "type": "ip", "value": "1.1.1.1"}`))
+	c, _ := graph.JsonToNode([]byte(`{"ID": 3, "type": "asn", "value": "13335"}`))
+	e1, _ := graph.JsonToEdge([]byte(`{"ID": 4, "type": "resolves", "srcID": 1, "tgtID": 2, "note": "x"}`))
+	e2, _ := graph.JsonToEdge([]byte(`{"ID": 5, "type": "announced_by", "srcID": 2, "tgtID": 3}`))
+
+	s := graph.NewStore()
+	for _, n := range []graph.NodeInterface{a, b, c} {
+		s.AddNode(n)
+	}
+	assert.NoError(t, s.AddEdge(e1))
+	assert.NoError(t, s.AddEdge(e2))
+
+	var buf bytes.Buffer
+	assert.NoError(t, export.WriteGraphML(&buf, s))
+
+	res, err := ImportGraphML(&buf)
+	assert.NoError(t, err)
+	assert.Empty(t, res.Errors)
+	assert.Empty(t, res.Nodes)
+
+	// both edges are grouped into a single chain
+	assert.Len(t, res.Chains, 1)
+	elements := res.Chains[0].GetElements()
+	assert.Len(t, elements, 5)
+	domain := elements[0].(graph.NodeInterface)
+	assert.Equal(t, "domain", domain.GetType())
+	assert.Equal(t, 0, domain.GetID())
+	assert.Equal(t, map[string]interface{}{"score": int64(3), "seen": true}, domain.GetProperties())
+	assert.Equal(t, "resolves", elements[1].(graph.EdgeInterface).GetType())
+	assert.Equal(t, map[string]interface{}{"note": "x"}, elements[1].(graph.EdgeInterface).GetProperties())
+	assert.Equal(t, "1.1.1.1", elements[2].(graph.NodeInterface).GetValue())
+	assert.Equal(t, "announced_by", elements[3].(graph.EdgeInterface).GetType())
+	assert.Equal(t, "asn", elements[4].(graph.NodeInterface).GetType())
+}
+
+func Test_ImportGEXFRules(t *testing.T) {
+	input := `<?xml version="1.0" encoding="UTF-8"?>
+<gexf xmlns="http://gexf.net/1.2" version="1.2">
+  <graph defaultedgetype="directed">
+    <attributes class="node">
+      <attribute id="0" title="kind" type="string"/>
+      <attribute id="1" title="degree" type="integer"><default>0</default></attribute>
+    </attributes>
+    <nodes>
+      <node id="a" label="a.com"><attvalues><attvalue for="0" value="maltego.Domain"/><attvalue for="1" value="2"/></attvalues></node>
+      <node id="b" label="1.1.1.1"><attvalues><attvalue for="0" value="maltego.IPv4Address"/></attvalues></node>
+      <node id="c" label="unknown"/>
+      <node id="d" label="d.com"><attvalues><attvalue for="0" value="maltego.Domain"/></attvalues></node>
+    </nodes>
+    <edges>
+      <edge id="0" source="a" target="b" label="To IP"/>
+      <edge id="1" source="c" target="b"/>
+      <edge id="2" source="d" target="b"/>
+    </edges>
+  </graph>
+</gexf>`
+
+	res, err := ImportGEXF(strings.NewReader(input),
+		WithNodeRules(
+			Rule{When: map[string]string{"kind": `maltego\.Domain`}, Type: "domain"},
+			Rule{When: map[string]string{"kind": `maltego\.IPv4.*`}, Type: "ip", ValueFrom: "label"},
+		),
+		WithEdgeRules(Rule{When: map[string]string{"label": "To IP"}, Type: "resolves"}),
+		WithDefaultTypes("", "related"),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, res.Rows)
+
+	assert.Len(t, res.Errors, 2)
+	assert.Equal(t, "element c: no rule gives it a type", res.Errors[0].Error())
+	assert.Equal(t, "element 1: source node c was not imported", res.Errors[1].Error())
+
+	assert.Len(t, res.Chains, 2)
+	first := res.Chains[0].GetElements()
+	assert.Equal(t, "a.com", first[0].(graph.NodeInterface).GetValue())
+	assert.Equal(t, map[string]interface{}{"kind": "maltego.Domain", "degree": int64(2)}, first[0].(graph.NodeInterface).GetProperties())
+	assert.Equal(t, "resolves", first[1].(graph.EdgeInterface).GetType())
+	assert.Equal(t, "", first[1].(graph.EdgeInterface).GetValue())
+	assert.Equal(t, "ip", first[2].(graph.NodeInterface).GetType())
+
+	second := res.Chains[1].GetElements()
+	assert.Equal(t, "d.com", second[0].(graph.NodeInterface).GetValue())
+	assert.Equal(t, "related", second[1].(graph.EdgeInterface).GetType())
+	assert.Same(t, first[2], second[2])
+
+	_, err = ImportGEXF(strings.NewReader(input), WithNodeRules(Rule{When: map[string]string{"kind": "("}}))
+	assert.ErrorContains(t, err, "node rule 0")
+}
@@ -0,0 +1,176 @@
+package importer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Mapping declares how the columns of a CSV file (or the keys of JSONL objects) become nodes and edges. It is
+// usually loaded from a YAML or JSON file with LoadMapping, e.g.
+//
+//	nodes:
+//	  - name: domain
+//	    type: domain
+//	    value: indicator
+//	    properties:
+//	      - {name: first_seen, column: date}
+//	      - {name: score, column: score, kind: int}
+//	  - name: ip
+//	    type: ip
+//	    value: resolved_ip
+//	    optional: true
+//	edges:
+//	  - {type: resolves, source: domain, target: ip}
+type Mapping struct {
+	Delimiter string        `json:"delimiter,omitempty" yaml:"delimiter,omitempty"` // CSV field delimiter, "," by default
+	Nodes     []NodeMapping `json:"nodes" yaml:"nodes"`
+	Edges     []EdgeMapping `json:"edges,omitempty" yaml:"edges,omitempty"`
+}
+
+// NodeMapping creates one node per row
+type NodeMapping struct {
+	Name       string            `json:"name,omitempty" yaml:"name,omitempty"`               // name edges refer to, defaults to Type
+	Type       string            `json:"type,omitempty" yaml:"type,omitempty"`               // fixed node type
+	TypeColumn string            `json:"type_column,omitempty" yaml:"type_column,omitempty"` // column holding the node type, instead of Type
+	Value      string            `json:"value" yaml:"value"`                                 // column holding the node value
+	Properties []PropertyMapping `json:"properties,omitempty" yaml:"properties,omitempty"`
+	Optional   bool              `json:"optional,omitempty" yaml:"optional,omitempty"` // skip the node, rather than the row, when its value is empty
+}
+
+// EdgeMapping creates one edge per row between two mapped nodes. Rows where either node was skipped have no edge.
+type EdgeMapping struct {
+	Type        string            `json:"type" yaml:"type"`
+	ValueColumn string            `json:"value_column,omitempty" yaml:"value_column,omitempty"` // column holding the edge value, if any
+	Source      string            `json:"source" yaml:"source"`                                 // name of the source NodeMapping
+	Target      string            `json:"target" yaml:"target"`                                 // name of the target NodeMapping
+	Properties  []PropertyMapping `json:"properties,omitempty" yaml:"properties,omitempty"`
+}
+
+// Property kinds. Values are converted from the text of a CSV cell; JSONL values are used as they are unless a kind
+// other than KindAuto is given.
+const (
+	KindAuto   = ""       // CSV cells stay strings, JSONL values keep their JSON type
+	KindString = "string" // the value as text
+	KindInt    = "int"
+	KindFloat  = "float"
+	KindBool   = "bool"
+	KindJSON   = "json" // the cell holds a JSON document
+)
+
+// PropertyMapping copies a column into a node or edge property. Empty cells are left out.
+type PropertyMapping struct {
+	Name   string `json:"name" yaml:"name"`
+	Column string `json:"column" yaml:"column"`
+	Kind   string `json:"kind,omitempty" yaml:"kind,omitempty"`
+}
+
+// LoadMapping reads a YAML or JSON mapping and validates it
+func LoadMapping(r io.Reader) (*Mapping, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Mapping{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(m); err != nil {
+		return nil, fmt.Errorf("invalid mapping: %w", err)
+	}
+
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// LoadMappingFile reads a YAML or JSON mapping from a file
+func LoadMappingFile(path string) (*Mapping, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadMapping(f)
+}
+
+// Validate checks that the mapping is complete and that edges refer to mapped nodes
+func (m Mapping) Validate() error {
+	var problems []string
+
+	if len(m.Nodes) == 0 {
+		problems = append(problems, "no nodes are mapped")
+	}
+	if len([]rune(m.Delimiter)) > 1 {
+		problems = append(problems, fmt.Sprintf("delimiter %q must be a single character", m.Delimiter))
+	}
+
+	names := map[string]bool{}
+	for i, n := range m.Nodes {
+		name := n.name()
+		switch {
+		case name == "":
+			problems = append(problems, fmt.Sprintf("node %d: name is required when the type comes from a column", i))
+		case names[name]:
+			problems = append(problems, fmt.Sprintf("node %d: duplicate name %q", i, name))
+		}
+		names[name] = true
+
+		if (n.Type == "") == (n.TypeColumn == "") {
+			problems = append(problems, fmt.Sprintf("node %s: exactly one of type or type_column is required", name))
+		}
+		if n.Value == "" {
+			problems = append(problems, fmt.Sprintf("node %s: value column is required", name))
+		}
+		problems = append(problems, validateProperties("node "+name, n.Properties, "type", "value", "ID")...)
+	}
+
+	for i, e := range m.Edges {
+		if e.Type == "" {
+			problems = append(problems, fmt.Sprintf("edge %d: type is required", i))
+		}
+		for _, end := range []string{e.Source, e.Target} {
+			if !names[end] {
+				problems = append(problems, fmt.Sprintf("edge %d: unknown node %q", i, end))
+			}
+		}
+		problems = append(problems, validateProperties(fmt.Sprintf("edge %d", i), e.Properties, "type", "value", "ID", "source", "target")...)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid mapping: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func validateProperties(owner string, props []PropertyMapping, reserved ...string) []string {
+	var problems []string
+	for _, p := range props {
+		if p.Name == "" || p.Column == "" {
+			problems = append(problems, fmt.Sprintf("%s: properties need a name and a column", owner))
+		}
+		for _, r := range reserved {
+			if p.Name == r {
+				problems = append(problems, fmt.Sprintf("%s: property %q is reserved", owner, p.Name))
+			}
+		}
+		switch p.Kind {
+		case KindAuto, KindString, KindInt, KindFloat, KindBool, KindJSON:
+		default:
+			problems = append(problems, fmt.Sprintf("%s: property %s has unknown kind %q", owner, p.Name, p.Kind))
+		}
+	}
+	return problems
+}
+
+func (n NodeMapping) name() string {
+	if n.Name != "" {
+		return n.Name
+	}
+	return n.Type
+}
@@ -1,23 +1,26 @@
 package job
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/skyleronken/lemonclient/pkg/adapter"
 	"github.com/skyleronken/lemonclient/pkg/graph"
 	"github.com/skyleronken/lemonclient/pkg/permissions"
-	"github.com/skyleronken/lemonclient/pkg/utils"
 )
 
 // Structs
 
 type Opts struct {
-	ID       string                         `json:"id,omitempty"`
-	Meta     JobMetadata                    `json:"meta,omitempty"`
-	Seed     bool                           `json:"seed,omitempty"`
-	Nodes    []graph.NodeInterface          `json:"nodes,omitempty"`
-	Chains   []graph.ChainInterface         `json:"chains,omitempty"`
-	Adapters map[string]adapter.AdapterOpts `json:"adapters,omitempty"` // hypothetically these could be a map of an array of adapterparameters for secondary queries
+	ID       string                             `json:"id,omitempty"`
+	Meta     JobMetadata                        `json:"meta,omitempty"`
+	Seed     bool                               `json:"seed,omitempty"`
+	Nodes    []graph.NodeInterface              `json:"nodes,omitempty"`
+	Chains   []graph.ChainInterface             `json:"chains,omitempty"`
+	Adapters map[string]adapter.AdapterOptsList `json:"adapters,omitempty"` // adapter name to the configs of each query it watches (was map[string]adapter.AdapterOpts, see adapter.ListsFrom)
 	//Edges  []graph.EdgeInterface `json:"edges,omitempty"` // Non idiomatic way. Use chains instead for creation
 }
 
@@ -27,10 +30,14 @@ type Job struct {
 	Opts //`json:"Opts"`
 }
 
+// JobMetadata is a job's metadata. Keys other than priority, enabled and roles, such as tags or case numbers, are
+// kept in Extra with the types they decode to from JSON (numbers are json.Number), and read with GetString, GetInt
+// and the other accessors.
 type JobMetadata struct {
-	Priority uint8              `json:"priority,omitempty"`
-	Enabled  bool               `json:"enabled,omitempty"`
-	Roles    []permissions.User `json:"roles,omitempty"`
+	Priority uint8                  `json:"priority,omitempty"`
+	Enabled  bool                   `json:"enabled,omitempty"`
+	Roles    []permissions.User     `json:"roles,omitempty"`
+	Extra    map[string]interface{} `json:"-"`
 }
 
 // JobConfig represents the configuration for all adapters in a job
@@ -58,18 +65,30 @@ func defaultOpts() Opts {
 		Meta: JobMetadata{
 			Enabled: true,
 		},
-		Adapters: map[string]adapter.AdapterOpts{},
+		Adapters: map[string]adapter.AdapterOptsList{},
 	}
 }
 
 // implement validation in the 'with*' functions
 
+// WithAdapters adds the adapters to the job. An adapter may be given more than once with different queries to have
+// it watch each of them.
 func WithAdapters(adapters ...adapter.Adapter) OptFunc {
 	return func(opts *Opts) {
 		for idx := range adapters {
-			// TODO: If key already exists, provide a list of parameters instead of just a single config
 			adapter := adapters[idx]
-			opts.Adapters[adapter.Name] = adapter.AdapterOpts
+			list := opts.Adapters[adapter.Name]
+			list.Add(adapter.AdapterOpts)
+			opts.Adapters[adapter.Name] = list
+		}
+	}
+}
+
+// WithoutAdapters removes the named adapters from the job, for use with NewJobFrom
+func WithoutAdapters(names ...string) OptFunc {
+	return func(opts *Opts) {
+		for _, name := range names {
+			delete(opts.Adapters, strings.ToUpper(name))
 		}
 	}
 }
@@ -86,6 +105,14 @@ func WithRoles(roles ...permissions.User) OptFunc {
 	}
 }
 
+// WithMeta sets a metadata key other than priority, enabled and roles, e.g. WithMeta("case", "CASE-1234").
+// Validate reports keys which are reserved and values which cannot be marshalled to JSON.
+func WithMeta(key string, value interface{}) OptFunc {
+	return func(opts *Opts) {
+		opts.Meta.setExtra(key, value)
+	}
+}
+
 func WithPriority(priority uint8) OptFunc {
 	return func(opts *Opts) {
 		opts.Meta.Priority = priority
@@ -104,9 +131,9 @@ func WithSeed(seed bool) OptFunc {
 	}
 }
 
+// WithChains sets the chains the job is seeded with. Nodes may appear in several chains; Validate reports any that
+// are given conflicting properties.
 func WithChains(chains ...graph.ChainInterface) OptFunc {
-	// TODO: validate job
-	// - What happens if chain contains duplicate nodes?
 	return func(opts *Opts) {
 		opts.Chains = chains
 	}
@@ -118,7 +145,8 @@ func WithNodes(nodes ...graph.NodeInterface) OptFunc {
 	}
 }
 
-// constructor
+// constructor. The job is not validated, so malformed adapter queries are only found by Validate (which CreateJob
+// calls) or the server; BuildJob validates as it builds.
 func NewJob(opts ...OptFunc) *Job {
 	o := defaultOpts()
 	for _, fn := range opts {
@@ -130,80 +158,103 @@ func NewJob(opts ...OptFunc) *Job {
 	}
 }
 
+// NewJobFrom creates a job with the settings of template, then applies opts on top of them. The template is left
+// unchanged, so it can be used for any number of jobs. WithNodes and WithChains replace the template's seeds, and
+// WithAdapters replaces the config of any query the template already has for an adapter.
+func NewJobFrom(template Job, opts ...OptFunc) *Job {
+	o := template.Opts
+	o.Meta.Roles = append([]permissions.User(nil), template.Meta.Roles...)
+	o.Meta.Extra = nil
+	for key, value := range template.Meta.Extra {
+		o.Meta.setExtra(key, value)
+	}
+	o.Nodes = append([]graph.NodeInterface(nil), template.Nodes...)
+	o.Chains = append([]graph.ChainInterface(nil), template.Chains...)
+	o.Adapters = make(map[string]adapter.AdapterOptsList, len(template.Adapters))
+	for name, list := range template.Adapters {
+		o.Adapters[name] = append(adapter.AdapterOptsList(nil), list...)
+	}
+
+	for _, fn := range opts {
+		fn(&o)
+	}
+
+	return &Job{
+		Opts: o,
+	}
+}
+
+// BuildJob is NewJob followed by Validate, so a malformed job is rejected before it is sent to the server
+func BuildJob(opts ...OptFunc) (*Job, error) {
+	j := NewJob(opts...)
+	if err := j.Validate(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// MarshalJSON encodes nodes and chains with graph's canonical encoders, as task.TaskResults does
 func (j Job) MarshalJSON() ([]byte, error) {
 	type Alias Job
 
 	tJob := &struct {
-		Nodes []map[string]interface{} `json:"nodes,omitempty"`
-		//Edges  []map[string]interface{}   `json:"edges,omitempty"`
-		Chains [][]map[string]interface{} `json:"chains,omitempty"`
+		Nodes  []json.RawMessage   `json:"nodes,omitempty"`
+		Chains [][]json.RawMessage `json:"chains,omitempty"`
 		*Alias
 	}{
 		Alias: (*Alias)(&j),
 	}
 
-	// for e := range j.Edges {
-	// 	curEdge := j.Edges[e]
-	// 	edgeJson, _ := graph.EdgeToJson(curEdge, true, false)
-	// 	edgeMap, err := utils.JSONBytesToMap(edgeJson)
-	// 	if err != nil {
-	// 		return nil, err
-	// 	}
-	// 	tJob.Edges = append(tJob.Edges, edgeMap)
-	// }
-
-	for n := range j.Nodes {
-		curNode := j.Nodes[n]
-		nodeJson, _ := graph.NodeToJson(curNode, false)
-		nodeMap, err := utils.JSONBytesToMap(nodeJson)
-		if err != nil {
-			return nil, err
-		}
-		tJob.Nodes = append(tJob.Nodes, nodeMap)
+	var err error
+	if tJob.Nodes, err = graph.NodesToJson(j.Nodes, false); err != nil {
+		return nil, err
 	}
-
-	for c := range j.Chains {
-		curChain := j.Chains[c]
-		chainJson, err := graph.ChainToJson(curChain, false)
-		scArray := []map[string]interface{}{}
-		for chainPart := range chainJson {
-			scMap, _ := utils.JSONBytesToMap(chainJson[chainPart])
-			if err != nil {
-				return nil, err
-			}
-			scArray = append(scArray, scMap)
-
-		}
-		tJob.Chains = append(tJob.Chains, scArray)
+	if tJob.Chains, err = graph.ChainsToJson(j.Chains, false); err != nil {
+		return nil, err
 	}
 
-	d, err := json.Marshal(tJob)
-	return d, err
-
+	return json.Marshal(tJob)
 }
 
-// func (j *Job) UnmarshalJSON(data []byte) error {
-// 	type Alias Job
+// UnmarshalJSON is the reverse of MarshalJSON, so a marshalled job can be read back (see also LoadSpec)
+func (j *Job) UnmarshalJSON(data []byte) error {
+	type Alias Job
+
+	aux := &struct {
+		Nodes  []json.RawMessage   `json:"nodes,omitempty"`
+		Chains [][]json.RawMessage `json:"chains,omitempty"`
+		*Alias
+	}{
+		Alias: (*Alias)(j),
+	}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
 
-// 	aux := &struct {
-// 		*Alias
-// 	}{
-// 		Alias: (*Alias)(j),
-// 	}
+	var err error
+	if j.Nodes, err = graph.JsonToNodes(aux.Nodes); err != nil {
+		return err
+	}
+	if j.Chains, err = graph.JsonToChains(aux.Chains); err != nil {
+		return err
+	}
 
-// 	json.Unmarshal(data, &aux)
+	if j.Adapters == nil {
+		j.Adapters = map[string]adapter.AdapterOptsList{}
+	}
 
-// 	return nil
-// }
+	return nil
+}
 
-func (jm *JobMetadata) MarshalJSON() ([]byte, error) {
+func (jm JobMetadata) MarshalJSON() ([]byte, error) {
 	type Alias JobMetadata
 
 	serMeta := &struct {
 		Roles map[string]permissions.Permissions `json:"roles,omitempty"`
 		*Alias
 	}{
-		Alias: (*Alias)(jm),
+		Alias: (*Alias)(&jm),
 	}
 
 	serMeta.Roles = map[string]permissions.Permissions{}
@@ -211,24 +262,79 @@ func (jm *JobMetadata) MarshalJSON() ([]byte, error) {
 		serMeta.Roles[v.Name] = v.Permissions
 	}
 
-	return json.Marshal(serMeta)
+	if len(jm.Extra) == 0 {
+		return json.Marshal(serMeta)
+	}
 
+	// merge the extra keys in alongside the modelled ones
+	m := make(map[string]interface{}, len(jm.Extra)+3)
+	for key, value := range jm.Extra {
+		if isReservedMeta(key) {
+			return nil, fmt.Errorf("metadata key %q is reserved", key)
+		}
+		m[key] = value
+	}
+	if jm.Priority != 0 {
+		m["priority"] = jm.Priority
+	}
+	if jm.Enabled {
+		m["enabled"] = jm.Enabled
+	}
+	if len(serMeta.Roles) > 0 {
+		m["roles"] = serMeta.Roles
+	}
+	return json.Marshal(m)
 }
 
+// UnmarshalJSON reads roles in the server's name to permissions map, or as a list of users, and keeps every other
+// key in Extra
 func (jm *JobMetadata) UnmarshalJSON(data []byte) error {
+	if string(bytes.TrimSpace(data)) == "null" {
+		return nil
+	}
+
 	type Alias JobMetadata
 
 	aux := &struct {
-		Roles map[string]permissions.Permissions `json:"roles,omitempty"`
+		Roles json.RawMessage `json:"roles,omitempty"`
 		*Alias
 	}{
 		Alias: (*Alias)(jm),
 	}
 
-	json.Unmarshal(data, &aux)
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
 
-	for k, v := range aux.Roles {
-		jm.Roles = append(jm.Roles, permissions.User{Name: k, Permissions: v})
+	jm.Roles = nil
+	if len(aux.Roles) > 0 && string(aux.Roles) != "null" {
+		var roles map[string]permissions.Permissions
+		if err := json.Unmarshal(aux.Roles, &roles); err != nil {
+			if json.Unmarshal(aux.Roles, &jm.Roles) != nil {
+				return fmt.Errorf("roles: %w", err)
+			}
+		}
+		names := make([]string, 0, len(roles))
+		for name := range roles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			jm.Roles = append(jm.Roles, permissions.User{Name: name, Permissions: roles[name]})
+		}
+	}
+
+	var all map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&all); err != nil {
+		return err
+	}
+	jm.Extra = nil
+	for key, value := range all {
+		if !isReservedMeta(key) {
+			jm.setExtra(key, value)
+		}
 	}
 
 	return nil
@@ -3,10 +3,12 @@ package job
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"os"
 	"strings"
 	"testing"
 
+	"github.com/skyleronken/lemonclient/pkg/adapter"
 	"github.com/skyleronken/lemonclient/pkg/graph"
 	"github.com/skyleronken/lemonclient/pkg/permissions"
 	"github.com/stretchr/testify/assert"
@@ -105,7 +107,7 @@ func Setup() {
 
 	rawMeta = new(bytes.Buffer)
 
-	assertion = "{\"priority\":100,\"enabled\":true,\"roles\":[{\"name\":\"tUser\",\"permissions\":{\"reader\":true,\"writer\":true}},{\"name\":\"fUser\",\"permissions\":{\"reader\":false,\"writer\":false}}]}"
+	assertion = "{\"roles\":{\"fUser\":{\"reader\":false,\"writer\":false},\"tUser\":{\"reader\":true,\"writer\":true}},\"priority\":100,\"enabled\":true}"
 
 }
 
@@ -151,3 +153,300 @@ func Test_JobMetadata_Deserialize(t *testing.T) {
 	assert.NoError(t, err)
 
 }
+
+func Test_JobMetadata_Extra(t *testing.T) {
+	data := []byte(`{"priority": 5, "enabled": true, "roles": {"bob": {"reader": true, "writer": false}},
+		"case": "CASE-1234", "analysts": ["alice", "bob"], "count": 12345678901234567, "ratio": 0.5, "closed": false,
+		"source": {"system": "ticketing", "id": 7}}`)
+
+	var meta JobMetadata
+	assert.NoError(t, json.Unmarshal(data, &meta))
+	assert.Equal(t, uint8(5), meta.Priority)
+	assert.Equal(t, []permissions.User{{Name: "bob", Permissions: permissions.ReadOnly}}, meta.Roles)
+	assert.Equal(t, []string{"analysts", "case", "closed", "count", "ratio", "source"}, meta.Keys())
+
+	caseNumber, ok := meta.GetString("case")
+	assert.True(t, ok)
+	assert.Equal(t, "CASE-1234", caseNumber)
+	analysts, _ := meta.GetStrings("analysts")
+	assert.Equal(t, []string{"alice", "bob"}, analysts)
+	count, ok := meta.GetInt("count")
+	assert.True(t, ok)
+	assert.Equal(t, int64(12345678901234567), count)
+	ratio, _ := meta.GetFloat("ratio")
+	assert.Equal(t, 0.5, ratio)
+	closed, ok := meta.GetBool("closed")
+	assert.True(t, ok)
+	assert.False(t, closed)
+	_, ok = meta.GetInt("case")
+	assert.False(t, ok)
+
+	var source struct {
+		System string `json:"system"`
+		ID     int    `json:"id"`
+	}
+	assert.NoError(t, meta.Decode("source", &source))
+	assert.Equal(t, 7, source.ID)
+	assert.Error(t, meta.Decode("missing", &source))
+
+	// every key survives a round trip
+	again, err := json.Marshal(meta)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(data), string(again))
+
+	assert.NoError(t, meta.Set("owner", "carol"))
+	assert.Error(t, meta.Set("priority", 1))
+	assert.Error(t, meta.Set("bad", func() {}))
+	meta.Delete("owner")
+	_, ok = meta.Get("owner")
+	assert.False(t, ok)
+
+	// roles given as a list of users are read as well
+	var fromList JobMetadata
+	assert.NoError(t, json.Unmarshal([]byte(`{"roles": [{"name": "tUser", "permissions": {"reader": true, "writer": true}}]}`), &fromList))
+	assert.Equal(t, tJob.Meta.Roles[:1], fromList.Roles)
+
+	// decode errors are returned rather than ignored
+	assert.Error(t, json.Unmarshal([]byte(`{"priority": 300}`), &JobMetadata{}))
+	assert.Error(t, json.Unmarshal([]byte(`{"roles": "alice"}`), &JobMetadata{}))
+
+	j := NewJob(WithMeta("case", "CASE-1"), WithMeta("roles", "x"))
+	assert.ErrorContains(t, j.Validate(), `key "roles" cannot be set as extra metadata`)
+}
+
+func Test_BuildJob(t *testing.T) {
+	good := adapter.ConfigureAdapter("good", adapter.WithQuery("n()->e()->n()"))
+	bad := adapter.ConfigureAdapter("bad", adapter.WithQuery("n(type=)"))
+
+	j, err := BuildJob(WithChains(c1), WithAdapters(*good))
+	assert.NoError(t, err)
+	assert.Contains(t, j.Adapters, "GOOD")
+
+	_, err = BuildJob(WithChains(c1), WithAdapters(*good, *bad))
+	assert.Error(t, err)
+}
+
+func Test_Job_Deserialize(t *testing.T) {
+	good := adapter.ConfigureAdapter("dns", adapter.WithQuery("n(type=\"domain\")"), adapter.WithLimit(10))
+	j := NewJob(WithID("abc"), WithSeed(true), WithNodes(n1), WithChains(c1), WithAdapters(*good), WithPriority(5), WithRoles(truishUser))
+
+	data, err := json.Marshal(j)
+	assert.NoError(t, err)
+
+	var back Job
+	assert.NoError(t, json.Unmarshal(data, &back))
+	assert.Equal(t, "abc", back.ID)
+	assert.True(t, back.Seed)
+	assert.Equal(t, uint8(5), back.Meta.Priority)
+	assert.Equal(t, []permissions.User{truishUser}, back.Meta.Roles)
+	assert.Equal(t, adapter.AdapterOptsList{good.AdapterOpts}, back.Adapters["DNS"])
+	assert.Len(t, back.Nodes, 1)
+	assert.Equal(t, "foo1", back.Nodes[0].GetProperties()["Foo"])
+	assert.Len(t, back.Chains, 1)
+	assert.Len(t, back.Chains[0].GetElements(), 3)
+
+	again, err := json.Marshal(back)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(data), string(again))
+
+	assert.Error(t, json.Unmarshal([]byte(`{"chains": [[{"type": "a", "value": "b"}, {"type": "e"}]]}`), &back))
+}
+
+const testSpec = `
+id: job-1
+seed: true
+meta:
+  priority: 100
+  roles:
+    alice: {reader: true, writer: false}
+  case: CASE-1234
+  tags: [phishing]
+adapters:
+  dns:
+    query: n(type="domain")
+    limit: 100
+    timeout: 30
+    autotask: true
+nodes:
+  - {type: domain, value: example.com, source: analyst}
+chains:
+  - [{type: domain, value: example.com}, {type: resolves, ttl: 300}, {type: ip, value: 93.184.216.34}]
+`
+
+func Test_LoadSpec(t *testing.T) {
+	j, err := LoadSpec(strings.NewReader(testSpec))
+	assert.NoError(t, err)
+
+	assert.Equal(t, "job-1", j.ID)
+	assert.True(t, j.Seed)
+	assert.True(t, j.Meta.Enabled)
+	assert.Equal(t, uint8(100), j.Meta.Priority)
+	assert.Equal(t, []permissions.User{{Name: "alice", Permissions: permissions.Permissions{Reader: true}}}, j.Meta.Roles)
+	caseNumber, _ := j.Meta.GetString("case")
+	assert.Equal(t, "CASE-1234", caseNumber)
+	tags, _ := j.Meta.GetStrings("tags")
+	assert.Equal(t, []string{"phishing"}, tags)
+
+	assert.Len(t, j.Adapters["DNS"], 1)
+	dns := j.Adapters["DNS"][0]
+	assert.Equal(t, `n(type="domain")`, dns.Query)
+	assert.Equal(t, uint64(100), dns.Limit)
+	assert.Equal(t, 30, dns.Timeout)
+	assert.True(t, dns.Autotask)
+	assert.True(t, dns.Enabled)
+
+	assert.Equal(t, "analyst", j.Nodes[0].GetProperties()["source"])
+	edge := j.Chains[0].GetElements()[1].(graph.EdgeInterface)
+	assert.Equal(t, "resolves", edge.GetType())
+	assert.Equal(t, float64(300), edge.GetProperties()["ttl"])
+
+	// a marshalled job is a valid spec, in JSON
+	data, err := json.Marshal(j)
+	assert.NoError(t, err)
+	back, err := ParseSpec(data)
+	assert.NoError(t, err)
+	again, err := json.Marshal(back)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(data), string(again))
+}
+
+func Test_LoadSpecErrors(t *testing.T) {
+	spec := `meta:
+  priority: 300
+  enabled: maybe
+adapters:
+  dns:
+    query: n(type=)
+    limit: -1
+    colour: red
+nodes:
+  - {type: domain}
+chains:
+  - [{type: domain, value: a.com}, {value: x}, {type: ip, value: 1.1.1.1}]
+  - [{type: domain, value: a.com}, {type: resolves}]
+extra: 1
+`
+	_, err := LoadSpec(strings.NewReader(spec))
+	var specErrs SpecErrors
+	assert.True(t, errors.As(err, &specErrs))
+
+	lines := map[int]string{}
+	for _, e := range specErrs {
+		lines[e.Line] = e.Error()
+	}
+	assert.Len(t, specErrs, 9)
+	assert.Equal(t, "line 2: meta.priority: must be between 0 and 255", lines[2])
+	assert.Equal(t, "line 3: meta.enabled: must be true or false", lines[3])
+	assert.Contains(t, lines[6], "line 6: adapters.dns.query: ")
+	assert.Equal(t, "line 7: adapters.dns.limit: must be between 0 and 9223372036854775807", lines[7])
+	assert.Equal(t, "line 8: adapters.dns.colour: unknown field", lines[8])
+	assert.Equal(t, "line 10: nodes[0]: failed to unmarshal node: JSON must contain 'type' and 'value' fields", lines[10])
+	assert.Equal(t, "line 12: chains[0][1]: edges need a type, or an ID", lines[12])
+	assert.Contains(t, lines[13], "line 13: chains[1]: chains must contain an odd number of elements")
+	assert.Equal(t, "line 14: extra: unknown field", lines[14])
+
+	_, err = ParseSpec([]byte("meta: [unclosed"))
+	assert.ErrorContains(t, err, "invalid job spec")
+}
+
+func Test_MultipleAdapterQueries(t *testing.T) {
+	domains := adapter.ConfigureAdapter("whois", adapter.WithQuery(`n(type="domain")`))
+	ips := adapter.ConfigureAdapter("whois", adapter.WithQuery(`n(type="ip")`), adapter.WithLimit(5))
+
+	j, err := BuildJob(WithAdapters(*domains, *ips))
+	assert.NoError(t, err)
+	assert.Equal(t, adapter.AdapterOptsList{domains.AdapterOpts, ips.AdapterOpts}, j.Adapters["WHOIS"])
+
+	data, err := json.Marshal(j)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"adapters":{"WHOIS":{"n(type=\"domain\")":{"enabled":true},"n(type=\"ip\")":{"limit":5,"enabled":true}}}`)
+
+	spec := `adapters:
+  whois:
+    - query: n(type="domain")
+    - query: n(type="ip")
+      limit: 5
+`
+	fromSpec, err := ParseSpec([]byte(spec))
+	assert.NoError(t, err)
+	assert.Equal(t, j.Adapters, fromSpec.Adapters)
+
+	// the marshalled form, keyed by query, is a valid spec too
+	fromJSON, err := ParseSpec(data)
+	assert.NoError(t, err)
+	assert.Equal(t, j.Adapters, fromJSON.Adapters)
+
+	keyed := `adapters:
+  whois:
+    n(type="domain"): {}
+    n(type="ip"): {limit: 5}
+`
+	fromKeyed, err := ParseSpec([]byte(keyed))
+	assert.NoError(t, err)
+	assert.Equal(t, j.Adapters, fromKeyed.Adapters)
+
+	_, err = ParseSpec([]byte("adapters:\n  whois:\n    - query: n(\n"))
+	assert.ErrorContains(t, err, "line 3: adapters.whois[0].query: ")
+
+	_, err = ParseSpec([]byte("adapters:\n  whois:\n    n(type=\"ip\"): {query: n()}\n    limit: 5\n"))
+	assert.ErrorContains(t, err, `line 3: adapters.whois.n(type="ip").query: query is already given by the key`)
+	assert.ErrorContains(t, err, "line 4: adapters.whois.limit: adapter configs keyed by query must all be mappings")
+}
+
+func Test_Validate(t *testing.T) {
+	assert.NoError(t, tJob.Validate())
+
+	conflicting, _ := graph.Node(TestNode{NodeMembers: graph.NodeMembers{Type: "TestNode", Value: "foo1"}, Foo: "other"})
+	same, _ := graph.Node(TestNode{NodeMembers: graph.NodeMembers{Type: "TestNode", Value: "foo2"}, Foo: "foo2"})
+	untyped, _ := graph.JsonToEdge([]byte(`{"value": "x"}`))
+	badChain, _ := graph.CreateChain(n1, untyped, n2)
+
+	j := NewJob(
+		WithNodes(conflicting, same),
+		WithChains(c1, badChain),
+		WithRoles(truishUser, falsishUser, truishUser, permissions.User{}),
+	)
+	j.Adapters["lower"] = adapter.AdapterOptsList{{AdapterBehaviors: adapter.AdapterBehaviors{Query: "n()->n()"}}}
+
+	err := j.Validate()
+	var verr *ValidationError
+	assert.True(t, errors.As(err, &verr))
+	assert.Equal(t, []string{
+		`chains[0][0]: node TestNode "foo1" sets Foo to foo1, but nodes[0] set it to other`,
+		`chains[1][0]: node TestNode "foo1" sets Foo to foo1, but nodes[0] set it to other`,
+		"chains[1][1]: edge needs a type, or an ID",
+		"adapter lower: name must be upper case",
+		verr.Problems[4],
+		"roles[2]: tUser is given more than one role",
+		"roles[3]: name cannot be empty",
+	}, verr.Problems)
+	assert.Contains(t, verr.Problems[4], "adapter lower: invalid query")
+
+	_, err = BuildJob(WithChains(c1), WithRoles(truishUser, truishUser))
+	assert.ErrorContains(t, err, "invalid job: roles[1]: tUser is given more than one role")
+
+	_, err = ParseSpec([]byte("nodes:\n  - {type: a, value: b, x: 1}\n  - {type: a, value: b, x: 2}\n"))
+	assert.ErrorContains(t, err, `nodes[1]: node a "b" sets x to 2, but nodes[0] set it to 1`)
+}
+
+func Test_NewJobFrom(t *testing.T) {
+	dns := adapter.ConfigureAdapter("dns", adapter.WithQuery(`n(type="domain")`))
+	whois := adapter.ConfigureAdapter("whois", adapter.WithQuery(`n(type="domain")`))
+	template := NewJob(WithPriority(10), WithRoles(truishUser), WithChains(c1), WithAdapters(*dns, *whois))
+
+	limited := adapter.ConfigureAdapter("dns", adapter.WithQuery(`n(type="domain")`), adapter.WithLimit(5))
+	j := NewJobFrom(*template, WithNodes(n1), WithChains(), WithPriority(200), WithAdapters(*limited), WithoutAdapters("whois"))
+
+	assert.Equal(t, uint8(200), j.Meta.Priority)
+	assert.Equal(t, []graph.NodeInterface{n1}, j.Nodes)
+	assert.Empty(t, j.Chains)
+	assert.Equal(t, adapter.AdapterOptsList{limited.AdapterOpts}, j.Adapters["DNS"])
+	assert.NotContains(t, j.Adapters, "WHOIS")
+	assert.Equal(t, []permissions.User{truishUser}, j.Meta.Roles)
+
+	// the template is unchanged
+	assert.Equal(t, uint8(10), template.Meta.Priority)
+	assert.Len(t, template.Chains, 1)
+	assert.Equal(t, adapter.AdapterOptsList{dns.AdapterOpts}, template.Adapters["DNS"])
+	assert.Contains(t, template.Adapters, "WHOIS")
+}
@@ -0,0 +1,144 @@
+package job
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// reservedMeta are the metadata keys modelled by JobMetadata's own fields
+var reservedMeta = []string{"priority", "enabled", "roles"}
+
+func isReservedMeta(key string) bool {
+	return contains(reservedMeta, key)
+}
+
+func (jm *JobMetadata) setExtra(key string, value interface{}) {
+	if jm.Extra == nil {
+		jm.Extra = map[string]interface{}{}
+	}
+	jm.Extra[key] = value
+}
+
+// Set sets a metadata key other than priority, enabled and roles. The value must marshal to JSON.
+func (jm *JobMetadata) Set(key string, value interface{}) error {
+	if key == "" {
+		return fmt.Errorf("metadata key cannot be empty")
+	}
+	if isReservedMeta(key) {
+		return fmt.Errorf("metadata key %q is reserved, set the %s field instead", key, key)
+	}
+	if _, err := json.Marshal(value); err != nil {
+		return fmt.Errorf("metadata key %q: %w", key, err)
+	}
+	jm.setExtra(key, value)
+	return nil
+}
+
+// Delete removes an extra metadata key
+func (jm *JobMetadata) Delete(key string) {
+	delete(jm.Extra, key)
+}
+
+// Keys lists the extra metadata keys, ordered by name
+func (jm JobMetadata) Keys() []string {
+	keys := make([]string, 0, len(jm.Extra))
+	for key := range jm.Extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Get returns an extra metadata value as it was set or decoded
+func (jm JobMetadata) Get(key string) (interface{}, bool) {
+	value, ok := jm.Extra[key]
+	return value, ok
+}
+
+// GetString returns an extra metadata value which is a string
+func (jm JobMetadata) GetString(key string) (string, bool) {
+	s, ok := jm.Extra[key].(string)
+	return s, ok
+}
+
+// GetBool returns an extra metadata value which is a boolean
+func (jm JobMetadata) GetBool(key string) (bool, bool) {
+	b, ok := jm.Extra[key].(bool)
+	return b, ok
+}
+
+// GetInt returns an extra metadata value which is a whole number
+func (jm JobMetadata) GetInt(key string) (int64, bool) {
+	switch v := jm.Extra[key].(type) {
+	case json.Number:
+		i, err := v.Int64()
+		return i, err == nil
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case int32:
+		return int64(v), true
+	case uint8:
+		return int64(v), true
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int64(v), true
+		}
+	}
+	return 0, false
+}
+
+// GetFloat returns an extra metadata value which is a number
+func (jm JobMetadata) GetFloat(key string) (float64, bool) {
+	switch v := jm.Extra[key].(type) {
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	}
+	if i, ok := jm.GetInt(key); ok {
+		return float64(i), true
+	}
+	return 0, false
+}
+
+// GetStrings returns an extra metadata value which is a list of strings, such as tags
+func (jm JobMetadata) GetStrings(key string) ([]string, bool) {
+	switch v := jm.Extra[key].(type) {
+	case []string:
+		return v, true
+	case []interface{}:
+		list := make([]string, len(v))
+		for idx, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			list[idx] = s
+		}
+		return list, true
+	}
+	return nil, false
+}
+
+// Decode reads an extra metadata value into v, as json.Unmarshal would, for values with a structure of their own
+func (jm JobMetadata) Decode(key string, v interface{}) error {
+	value, ok := jm.Extra[key]
+	if !ok {
+		return fmt.Errorf("metadata key %q is not set", key)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("metadata key %q: %w", key, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("metadata key %q: %w", key, err)
+	}
+	return nil
+}
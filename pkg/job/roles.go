@@ -0,0 +1,57 @@
+package job
+
+import (
+	"github.com/skyleronken/lemonclient/pkg/permissions"
+)
+
+// Role returns the permissions the job gives the named user (or group, named with permissions.GroupPrefix)
+func (jm JobMetadata) Role(name string) (permissions.Permissions, bool) {
+	for _, user := range jm.Roles {
+		if user.Name == name {
+			return user.Permissions, true
+		}
+	}
+	return permissions.Permissions{}, false
+}
+
+// CanRead reports whether the named user's own role lets them read the job. Use a permissions.Policy to take
+// groups into account.
+func (jm JobMetadata) CanRead(name string) bool {
+	perms, _ := jm.Role(name)
+	return perms.Allows(permissions.ReadOnly)
+}
+
+// CanWrite reports whether the named user's own role lets them write to the job
+func (jm JobMetadata) CanWrite(name string) bool {
+	perms, _ := jm.Role(name)
+	return perms.Allows(permissions.Permissions{Writer: true})
+}
+
+// CanAdmin reports whether the named user's own role lets them change the job's roles
+func (jm JobMetadata) CanAdmin(name string) bool {
+	perms, _ := jm.Role(name)
+	return perms.Admin
+}
+
+// Grant gives the named user each permission set in perms, keeping any they already have
+func (jm *JobMetadata) Grant(name string, perms permissions.Permissions) {
+	for idx := range jm.Roles {
+		if jm.Roles[idx].Name == name {
+			jm.Roles[idx].Permissions = jm.Roles[idx].Union(perms)
+			return
+		}
+	}
+	jm.Roles = append(jm.Roles, permissions.User{Name: name, Permissions: perms})
+}
+
+// Revoke takes each permission set in perms away from the named user. The user keeps their entry with no
+// permissions rather than being removed, so the change is carried by a metadata merge. Revoking reader or writer from
+// an admin takes admin away too, see permissions.Permissions.Without.
+func (jm *JobMetadata) Revoke(name string, perms permissions.Permissions) {
+	for idx := range jm.Roles {
+		if jm.Roles[idx].Name == name {
+			jm.Roles[idx].Permissions = jm.Roles[idx].Without(perms)
+			return
+		}
+	}
+}
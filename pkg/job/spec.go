@@ -0,0 +1,440 @@
+package job
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/skyleronken/lemonclient/pkg/adapter"
+	"github.com/skyleronken/lemonclient/pkg/graph"
+	"github.com/skyleronken/lemonclient/pkg/permissions"
+	"github.com/skyleronken/lemonclient/pkg/query"
+	"gopkg.in/yaml.v3"
+)
+
+// A job spec describes a job in YAML or JSON. It uses the same layout as the JSON a Job marshals to, so a marshalled
+// job is also a valid spec:
+//
+//	id: optional-job-uuid
+//	seed: true
+//	meta:
+//	  priority: 100
+//	  enabled: true          # the default
+//	  roles:
+//	    alice: {reader: true, writer: true}
+//	    "@analysts": {reader: true}  # every member of a group, see permissions.Policy
+//	  case: CASE-1234        # any other key is kept as extra metadata
+//	adapters:
+//	  DNS:
+//	    query: n(type="domain")
+//	    limit: 100
+//	    timeout: 30
+//	    filter: n(type="domain",value!~/internal$/)
+//	    autotask: true
+//	  WHOIS:                 # a list of configs watches several queries
+//	    - query: n(type="domain")
+//	    - query: n(type="ip")
+//	  GEOIP:                 # or a mapping of query to config, as a marshalled job writes them
+//	    n(type="ip"): {limit: 10}
+//	    n(type="host"): {}
+//	nodes:
+//	  - {type: domain, value: example.com, source: analyst}
+//	chains:
+//	  - [{type: domain, value: example.com}, {type: resolves}, {type: ip, value: 93.184.216.34}]
+//
+// Adapter names are upper cased, as with adapter.ConfigureAdapter, and adapters are enabled unless they say otherwise.
+
+// SpecError is a problem found in a job spec
+type SpecError struct {
+	Line int
+	Path string // dotted path to the offending field, e.g. "adapters.DNS.query"
+	Msg  string
+}
+
+func (e SpecError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+	}
+	return fmt.Sprintf("line %d: %s: %s", e.Line, e.Path, e.Msg)
+}
+
+// SpecErrors is every problem found in a job spec, in the order they appear
+type SpecErrors []SpecError
+
+func (e SpecErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i := range e {
+		msgs[i] = e[i].Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// LoadSpec reads a YAML or JSON job spec. Every problem in the spec is reported in a SpecErrors, and the job it
+// describes is then checked with Validate.
+func LoadSpec(r io.Reader) (*Job, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSpec(data)
+}
+
+// LoadSpecFile reads a YAML or JSON job spec from a file
+func LoadSpecFile(path string) (*Job, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadSpec(f)
+}
+
+// ParseSpec parses a YAML or JSON job spec
+func ParseSpec(data []byte) (*Job, error) {
+	var doc yaml.Node
+	if err := yaml.NewDecoder(bytes.NewReader(data)).Decode(&doc); err != nil {
+		if err == io.EOF {
+			return nil, SpecErrors{{Line: 1, Msg: "spec is empty"}}
+		}
+		return nil, fmt.Errorf("invalid job spec: %w", err)
+	}
+
+	p := &specParser{}
+	opts := p.root(doc.Content[0])
+	if len(p.errs) > 0 {
+		return nil, p.errs
+	}
+	return BuildJob(opts...)
+}
+
+type specParser struct {
+	errs SpecErrors
+}
+
+func (p *specParser) fail(n *yaml.Node, path string, format string, args ...interface{}) {
+	p.errs = append(p.errs, SpecError{Line: n.Line, Path: path, Msg: fmt.Sprintf(format, args...)})
+}
+
+// fields calls fn for each key of a mapping, reporting keys which are not in known
+func (p *specParser) fields(n *yaml.Node, path string, known []string, fn func(key string, value *yaml.Node, path string)) {
+	if n.Kind != yaml.MappingNode {
+		p.fail(n, path, "must be a mapping")
+		return
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		childPath := key.Value
+		if path != "" {
+			childPath = path + "." + key.Value
+		}
+		if known != nil && !contains(known, key.Value) {
+			p.fail(key, childPath, "unknown field")
+			continue
+		}
+		fn(key.Value, value, childPath)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *specParser) str(n *yaml.Node, path string) string {
+	if n.Kind != yaml.ScalarNode || n.Tag == "!!null" {
+		p.fail(n, path, "must be a string")
+		return ""
+	}
+	return n.Value
+}
+
+func (p *specParser) boolean(n *yaml.Node, path string) bool {
+	var b bool
+	if n.Kind != yaml.ScalarNode || n.Tag != "!!bool" || n.Decode(&b) != nil {
+		p.fail(n, path, "must be true or false")
+	}
+	return b
+}
+
+func (p *specParser) integer(n *yaml.Node, path string, min int64, max int64) int64 {
+	var i int64
+	if n.Kind != yaml.ScalarNode || n.Tag != "!!int" || n.Decode(&i) != nil {
+		p.fail(n, path, "must be an integer")
+		return 0
+	}
+	if i < min || i > max {
+		p.fail(n, path, "must be between %d and %d", min, max)
+	}
+	return i
+}
+
+func (p *specParser) root(n *yaml.Node) []OptFunc {
+	var opts []OptFunc
+	p.fields(n, "", []string{"id", "seed", "meta", "adapters", "nodes", "chains"}, func(key string, value *yaml.Node, path string) {
+		switch key {
+		case "id":
+			opts = append(opts, WithID(p.str(value, path)))
+		case "seed":
+			opts = append(opts, WithSeed(p.boolean(value, path)))
+		case "meta":
+			opts = append(opts, p.meta(value, path)...)
+		case "adapters":
+			opts = append(opts, p.adapters(value, path)...)
+		case "nodes":
+			opts = append(opts, WithNodes(p.nodes(value, path)...))
+		case "chains":
+			opts = append(opts, WithChains(p.chains(value, path)...))
+		}
+	})
+	return opts
+}
+
+func (p *specParser) meta(n *yaml.Node, path string) []OptFunc {
+	var opts []OptFunc
+	p.fields(n, path, nil, func(key string, value *yaml.Node, path string) {
+		switch key {
+		case "priority":
+			opts = append(opts, WithPriority(uint8(p.integer(value, path, 0, math.MaxUint8))))
+		case "enabled":
+			opts = append(opts, WithEnabled(p.boolean(value, path)))
+		case "roles":
+			var users []permissions.User
+			p.fields(value, path, nil, func(name string, perms *yaml.Node, path string) {
+				user := permissions.User{Name: name}
+				p.fields(perms, path, []string{"reader", "writer", "admin"}, func(key string, value *yaml.Node, path string) {
+					switch key {
+					case "reader":
+						user.Reader = p.boolean(value, path)
+					case "writer":
+						user.Writer = p.boolean(value, path)
+					case "admin":
+						user.Admin = p.boolean(value, path)
+					}
+				})
+				users = append(users, user)
+			})
+			opts = append(opts, WithRoles(users...))
+		default:
+			if value, ok := p.value(value, path); ok {
+				opts = append(opts, WithMeta(key, value))
+			}
+		}
+	})
+	return opts
+}
+
+func (p *specParser) adapters(n *yaml.Node, path string) []OptFunc {
+	var adapters []adapter.Adapter
+	p.fields(n, path, nil, func(name string, value *yaml.Node, path string) {
+		if name == "" {
+			p.fail(value, path, "adapter name cannot be empty")
+			return
+		}
+		if keyedByQuery(value) {
+			p.fields(value, path, nil, func(q string, item *yaml.Node, path string) {
+				if item.Kind != yaml.MappingNode {
+					p.fail(item, path, "adapter configs keyed by query must all be mappings")
+					return
+				}
+				if q == "" {
+					p.fail(item, path, "query cannot be empty")
+				} else if err := query.Validate(q); err != nil {
+					p.fail(item, path, "%v", err)
+				}
+				for i := 0; i+1 < len(item.Content); i += 2 {
+					if item.Content[i].Value == "query" {
+						p.fail(item.Content[i], path+".query", "query is already given by the key")
+					}
+				}
+				opts := append([]adapter.AdapterOptFunc{adapter.WithQuery(q)}, p.adapterOpts(item, path)...)
+				adapters = append(adapters, *adapter.ConfigureAdapter(name, opts...))
+			})
+			return
+		}
+		if value.Kind != yaml.SequenceNode {
+			adapters = append(adapters, *adapter.ConfigureAdapter(name, p.adapterOpts(value, path)...))
+			return
+		}
+		for i, item := range value.Content {
+			adapters = append(adapters, *adapter.ConfigureAdapter(name, p.adapterOpts(item, fmt.Sprintf("%s[%d]", path, i))...))
+		}
+	})
+	return []OptFunc{WithAdapters(adapters...)}
+}
+
+// keyedByQuery reports whether an adapter's configs are a mapping of query to config, the form an
+// adapter.AdapterOptsList marshals to when it watches more than one query. A single config only has scalar fields.
+func keyedByQuery(n *yaml.Node) bool {
+	if n.Kind != yaml.MappingNode {
+		return false
+	}
+	for i := 1; i < len(n.Content); i += 2 {
+		if n.Content[i].Kind == yaml.MappingNode {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *specParser) adapterOpts(n *yaml.Node, path string) []adapter.AdapterOptFunc {
+	var opts []adapter.AdapterOptFunc
+	p.fields(n, path, []string{"query", "filter", "limit", "timeout", "autotask", "enabled", "pos"}, func(key string, value *yaml.Node, path string) {
+		switch key {
+		case "query", "filter":
+			q := p.str(value, path)
+			if q != "" {
+				if err := query.Validate(q); err != nil {
+					p.fail(value, path, "%v", err)
+				}
+			}
+			if key == "query" {
+				opts = append(opts, adapter.WithQuery(q))
+			} else {
+				opts = append(opts, adapter.WithFilter(q))
+			}
+		case "limit":
+			opts = append(opts, adapter.WithLimit(uint64(p.integer(value, path, 0, math.MaxInt64))))
+		case "timeout":
+			opts = append(opts, adapter.WithTimeout(int(p.integer(value, path, 0, math.MaxInt32))))
+		case "autotask":
+			opts = append(opts, adapter.WithAutotask(p.boolean(value, path)))
+		case "enabled":
+			opts = append(opts, adapter.WithEnabled(p.boolean(value, path)))
+		case "pos":
+			opts = append(opts, adapter.WithPosition(uint64(p.integer(value, path, 0, math.MaxInt64))))
+		}
+	})
+	return opts
+}
+
+// value decodes any YAML value to the type it would have from JSON, with numbers as json.Number
+func (p *specParser) value(n *yaml.Node, path string) (interface{}, bool) {
+	var v interface{}
+	if err := n.Decode(&v); err != nil {
+		p.fail(n, path, "%v", err)
+		return nil, false
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		p.fail(n, path, "%v", err)
+		return nil, false
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		p.fail(n, path, "%v", err)
+		return nil, false
+	}
+	return v, true
+}
+
+// element converts a mapping to JSON so nodes and edges are read exactly as they are from the wire format
+func (p *specParser) element(n *yaml.Node, path string) ([]byte, bool) {
+	if n.Kind != yaml.MappingNode {
+		p.fail(n, path, "must be a mapping")
+		return nil, false
+	}
+	var m map[string]interface{}
+	if err := n.Decode(&m); err != nil {
+		p.fail(n, path, "%v", err)
+		return nil, false
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		p.fail(n, path, "%v", err)
+		return nil, false
+	}
+	return data, true
+}
+
+func (p *specParser) node(n *yaml.Node, path string) graph.NodeInterface {
+	data, ok := p.element(n, path)
+	if !ok {
+		return nil
+	}
+	node, err := graph.JsonToNode(data)
+	if err != nil {
+		p.fail(n, path, "%v", err)
+		return nil
+	}
+	return node
+}
+
+func (p *specParser) nodes(n *yaml.Node, path string) []graph.NodeInterface {
+	if n.Kind != yaml.SequenceNode {
+		p.fail(n, path, "must be a list")
+		return nil
+	}
+	var nodes []graph.NodeInterface
+	for i, item := range n.Content {
+		if node := p.node(item, fmt.Sprintf("%s[%d]", path, i)); node != nil {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+func (p *specParser) chains(n *yaml.Node, path string) []graph.ChainInterface {
+	if n.Kind != yaml.SequenceNode {
+		p.fail(n, path, "must be a list")
+		return nil
+	}
+
+	var chains []graph.ChainInterface
+	for i, item := range n.Content {
+		chainPath := fmt.Sprintf("%s[%d]", path, i)
+		if item.Kind != yaml.SequenceNode {
+			p.fail(item, chainPath, "must be a list")
+			continue
+		}
+		if len(item.Content)%2 == 0 {
+			p.fail(item, chainPath, "chains must contain an odd number of elements, alternating between nodes and edges")
+			continue
+		}
+
+		elements := make([]interface{}, 0, len(item.Content))
+		for j, el := range item.Content {
+			elPath := fmt.Sprintf("%s[%d]", chainPath, j)
+			if j%2 == 0 {
+				if node := p.node(el, elPath); node != nil {
+					elements = append(elements, node)
+				}
+				continue
+			}
+
+			data, ok := p.element(el, elPath)
+			if !ok {
+				continue
+			}
+			edge, err := graph.JsonToEdge(data)
+			if err != nil {
+				p.fail(el, elPath, "%v", err)
+				continue
+			}
+			if edge.GetID() == 0 && edge.GetType() == "" {
+				p.fail(el, elPath, "edges need a type, or an ID")
+				continue
+			}
+			elements = append(elements, edge)
+		}
+
+		if len(elements) != len(item.Content) {
+			continue
+		}
+		chain, err := graph.CreateChain(elements...)
+		if err != nil {
+			p.fail(item, chainPath, "%v", err)
+			continue
+		}
+		chains = append(chains, chain)
+	}
+	return chains
+}
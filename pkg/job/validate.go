@@ -0,0 +1,163 @@
+package job
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/skyleronken/lemonclient/pkg/adapter"
+	"github.com/skyleronken/lemonclient/pkg/graph"
+)
+
+// ValidationError collects every problem found with a job
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid job: %s", strings.Join(e.Problems, "; "))
+}
+
+// Validate checks that the job can be created as intended:
+// - chains alternate between nodes and edges, starting and ending with a node
+// - nodes have a type and a value (or an ID), and edges a type (or an ID)
+// - nodes which appear more than once with the same type and value do not give a property different values
+// - adapter names are non-empty and upper case, and their queries and filters are valid
+// - roles are named and each name is used once
+// - extra metadata keys are named, not reserved, and their values marshal to JSON
+//
+// Every problem is returned at once in a *ValidationError.
+func (j Job) Validate() error {
+	var problems []string
+	addProblem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	// properties of every node seen so far, and where they were first set, to find conflicting duplicates
+	type seenProperty struct {
+		value interface{}
+		where string
+	}
+	seen := map[graph.NodeKey]map[string]seenProperty{}
+	checkNode := func(n graph.NodeInterface, where string) {
+		if v := reflect.ValueOf(n); n == nil || (v.Kind() == reflect.Ptr && v.IsNil()) {
+			addProblem("%s: node is nil", where)
+			return
+		}
+		if n.GetID() == 0 && (n.GetType() == "" || n.GetValue() == "") {
+			addProblem("%s: node needs a type and a value, or an ID", where)
+			return
+		}
+		if n.GetType() == "" || n.GetValue() == "" {
+			return
+		}
+
+		key := graph.KeyOf(n)
+		props, ok := seen[key]
+		if !ok {
+			props = map[string]seenProperty{}
+			seen[key] = props
+		}
+		names := make([]string, 0, len(n.GetProperties()))
+		for name := range n.GetProperties() {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			value := n.GetProperties()[name]
+			prev, ok := props[name]
+			if !ok {
+				props[name] = seenProperty{value: value, where: where}
+				continue
+			}
+			if !reflect.DeepEqual(prev.value, value) {
+				addProblem("%s: node %s %q sets %s to %v, but %s set it to %v", where, key.Type, key.Value, name, value, prev.where, prev.value)
+			}
+		}
+	}
+
+	for idx, n := range j.Nodes {
+		checkNode(n, fmt.Sprintf("nodes[%d]", idx))
+	}
+
+	for idx, c := range j.Chains {
+		where := fmt.Sprintf("chains[%d]", idx)
+		if c == nil {
+			addProblem("%s: chain is nil", where)
+			continue
+		}
+		elements := c.GetElements()
+		if len(elements)%2 == 0 {
+			addProblem("%s: chain has %d elements, it must have an odd number", where, len(elements))
+		}
+		for e, element := range elements {
+			elementWhere := fmt.Sprintf("%s[%d]", where, e)
+			if e%2 == 0 {
+				n, ok := element.(graph.NodeInterface)
+				if !ok {
+					addProblem("%s: expected a node", elementWhere)
+					continue
+				}
+				checkNode(n, elementWhere)
+				continue
+			}
+
+			edge, ok := element.(graph.EdgeInterface)
+			if !ok {
+				addProblem("%s: expected an edge", elementWhere)
+				continue
+			}
+			if edge.GetID() == 0 && edge.GetType() == "" {
+				addProblem("%s: edge needs a type, or an ID", elementWhere)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(j.Adapters))
+	for name := range j.Adapters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "" {
+			addProblem("adapter name cannot be empty")
+			continue
+		}
+		if name != strings.ToUpper(name) {
+			addProblem("adapter %s: name must be upper case", name)
+		}
+		for _, opts := range j.Adapters[name] {
+			if err := (adapter.Adapter{Name: name, AdapterOpts: opts}).Validate(); err != nil {
+				addProblem("%v", err)
+			}
+		}
+	}
+
+	roles := map[string]bool{}
+	for idx, user := range j.Meta.Roles {
+		switch {
+		case user.Name == "":
+			addProblem("roles[%d]: name cannot be empty", idx)
+		case roles[user.Name]:
+			addProblem("roles[%d]: %s is given more than one role", idx, user.Name)
+		}
+		roles[user.Name] = true
+	}
+
+	for _, key := range j.Meta.Keys() {
+		if key == "" || isReservedMeta(key) {
+			addProblem("meta: key %q cannot be set as extra metadata", key)
+			continue
+		}
+		if _, err := json.Marshal(j.Meta.Extra[key]); err != nil {
+			addProblem("meta.%s: %v", key, err)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
@@ -1,5 +1,21 @@
+// The permissions package describes who may do what with a job. On the wire a job's roles are a map of name to
+// permissions in its metadata:
+//
+//	"roles": {"alice": {"reader": true, "writer": false}, "@analysts": {"reader": true, "writer": true}}
+//
+// Names starting with GroupPrefix grant their permissions to every member of a named group, and the admin
+// permission is only sent when set, so roles using neither are unchanged from what LemonGrenade has always
+// accepted. Groups and admin are understood by clients using a Policy, not by the server.
 package permissions
 
+import (
+	"sort"
+	"strings"
+)
+
+// GroupPrefix marks a role as belonging to a group of users rather than a single user
+const GroupPrefix = "@"
+
 type User struct {
 	Name        string `json:"name,omitempty"`
 	Permissions `json:"permissions,omitempty"`
@@ -8,4 +24,122 @@ type User struct {
 type Permissions struct {
 	Reader bool `json:"reader"`
 	Writer bool `json:"writer"`
+	Admin  bool `json:"admin,omitempty"` // may change the job's roles; implies reader and writer
+}
+
+var (
+	ReadOnly   = Permissions{Reader: true}
+	ReadWrite  = Permissions{Reader: true, Writer: true}
+	FullAccess = Permissions{Reader: true, Writer: true, Admin: true}
+)
+
+// GroupRole gives every member of a group perms
+func GroupRole(group string, perms Permissions) User {
+	return User{Name: GroupPrefix + group, Permissions: perms}
+}
+
+// IsGroup reports whether the role is for a group rather than a single user
+func (u User) IsGroup() bool {
+	return strings.HasPrefix(u.Name, GroupPrefix)
+}
+
+// Group is the name of the group the role is for, or "" if it is for a single user
+func (u User) Group() string {
+	if !u.IsGroup() {
+		return ""
+	}
+	return strings.TrimPrefix(u.Name, GroupPrefix)
+}
+
+// Effective fills in the permissions implied by admin
+func (p Permissions) Effective() Permissions {
+	if p.Admin {
+		return FullAccess
+	}
+	return p
+}
+
+// Union has every permission set in either p or other
+func (p Permissions) Union(other Permissions) Permissions {
+	return Permissions{
+		Reader: p.Reader || other.Reader,
+		Writer: p.Writer || other.Writer,
+		Admin:  p.Admin || other.Admin,
+	}
+}
+
+// Without clears every permission set in other. Since admin implies reader and writer, clearing either of those
+// from an admin clears admin too and keeps the other one, so FullAccess.Without(ReadOnly) is writer only.
+func (p Permissions) Without(other Permissions) Permissions {
+	if p.Admin && (other.Reader || other.Writer) {
+		p = p.Effective()
+		p.Admin = false
+	}
+	return Permissions{
+		Reader: p.Reader && !other.Reader,
+		Writer: p.Writer && !other.Writer,
+		Admin:  p.Admin && !other.Admin,
+	}
+}
+
+// Allows reports whether p, including the permissions admin implies, has every permission set in required
+func (p Permissions) Allows(required Permissions) bool {
+	p = p.Effective()
+	return (p.Reader || !required.Reader) && (p.Writer || !required.Writer) && (p.Admin || !required.Admin)
+}
+
+// Groups maps a group name (without GroupPrefix) to the users in it
+type Groups map[string][]string
+
+// Of lists the groups a user is in, ordered by name
+func (g Groups) Of(user string) []string {
+	var groups []string
+	for group, members := range g {
+		for _, member := range members {
+			if member == user {
+				groups = append(groups, group)
+				break
+			}
+		}
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+// Policy decides what a user may do with a job from the job's roles
+type Policy struct {
+	Groups       Groups
+	Admins       []string    // users with full access to every job
+	Unrestricted Permissions // given to everyone on jobs without any roles
+}
+
+// Evaluate returns what user may do with a job given its roles: the union of the user's own role and the roles of
+// every group they are in, with admin implying reader and writer.
+func (p Policy) Evaluate(user string, roles []User) Permissions {
+	for _, admin := range p.Admins {
+		if admin == user {
+			return FullAccess
+		}
+	}
+	if len(roles) == 0 {
+		return p.Unrestricted.Effective()
+	}
+
+	groups := map[string]bool{}
+	for _, group := range p.Groups.Of(user) {
+		groups[group] = true
+	}
+
+	var perms Permissions
+	for _, role := range roles {
+		if role.IsGroup() && groups[role.Group()] || !role.IsGroup() && role.Name == user {
+			perms = perms.Union(role.Permissions)
+		}
+	}
+	return perms.Effective()
+}
+
+// Can reports whether user has every permission set in required on a job with roles
+func (p Policy) Can(user string, roles []User, required Permissions) bool {
+	return p.Evaluate(user, roles).Allows(required)
 }
@@ -1 +1,62 @@
 package permissions
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Permissions(t *testing.T) {
+	assert.True(t, Permissions{Admin: true}.Allows(ReadWrite))
+	assert.False(t, ReadOnly.Allows(ReadWrite))
+	assert.True(t, ReadWrite.Allows(Permissions{}))
+	assert.Equal(t, ReadWrite, ReadOnly.Union(Permissions{Writer: true}))
+	assert.Equal(t, Permissions{Writer: true}, ReadWrite.Without(ReadOnly))
+	assert.Equal(t, Permissions{Writer: true}, Permissions{Admin: true}.Without(ReadOnly))
+	assert.False(t, FullAccess.Without(ReadOnly).Allows(ReadOnly))
+	assert.Equal(t, ReadWrite, FullAccess.Without(Permissions{Admin: true}))
+
+	// admin is only sent when set, so existing roles are unchanged on the wire
+	data, err := json.Marshal(map[string]Permissions{"alice": ReadOnly, "bob": FullAccess})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"alice":{"reader":true,"writer":false},"bob":{"reader":true,"writer":true,"admin":true}}`, string(data))
+
+	g := GroupRole("analysts", ReadWrite)
+	assert.Equal(t, "@analysts", g.Name)
+	assert.True(t, g.IsGroup())
+	assert.Equal(t, "analysts", g.Group())
+	assert.Equal(t, "", User{Name: "alice"}.Group())
+}
+
+func Test_Policy(t *testing.T) {
+	p := Policy{
+		Groups: Groups{"analysts": {"alice", "bob"}, "leads": {"bob"}},
+		Admins: []string{"root"},
+	}
+	roles := []User{
+		{Name: "carol", Permissions: ReadOnly},
+		GroupRole("analysts", ReadOnly),
+		GroupRole("leads", Permissions{Writer: true}),
+		{Name: "dave", Permissions: Permissions{Admin: true}},
+	}
+
+	assert.Equal(t, ReadOnly, p.Evaluate("alice", roles))
+	assert.Equal(t, ReadWrite, p.Evaluate("bob", roles))
+	assert.Equal(t, ReadOnly, p.Evaluate("carol", roles))
+	assert.Equal(t, FullAccess, p.Evaluate("dave", roles))
+	assert.Equal(t, FullAccess, p.Evaluate("root", roles))
+	assert.Equal(t, Permissions{}, p.Evaluate("eve", roles))
+
+	// a user named like a group does not get the group's role
+	assert.Equal(t, Permissions{}, p.Evaluate("@analysts", roles[1:2]))
+
+	assert.True(t, p.Can("bob", roles, ReadWrite))
+	assert.False(t, p.Can("alice", roles, ReadWrite))
+
+	// jobs without roles are open only as far as Unrestricted allows
+	assert.False(t, p.Can("eve", nil, ReadOnly))
+	p.Unrestricted = ReadOnly
+	assert.True(t, p.Can("eve", nil, ReadOnly))
+	assert.False(t, p.Can("eve", nil, ReadWrite))
+}
@@ -0,0 +1,224 @@
+// The query package parses, validates and pretty-prints LemonGraph query strings such as those used by adapters:
+//
+//	n(type="domain", value~/evil/i)->e(type="resolves")->n(type="ip")
+//
+// A query is a chain of node (n) and edge (e) patterns joined by arrows (->, <- or -). Each pattern may carry a
+// comma separated list of predicates of the form `key op literal`, or just `key` / `!key` to test for existence.
+package query
+
+import (
+	"strings"
+)
+
+// ElementKind identifies whether a query element matches nodes or edges
+type ElementKind int
+
+const (
+	NodeElement ElementKind = iota
+	EdgeElement
+)
+
+func (k ElementKind) String() string {
+	if k == EdgeElement {
+		return "edge"
+	}
+	return "node"
+}
+
+// Direction is the arrow joining two query elements
+type Direction string
+
+const (
+	DirectionAny Direction = "-"
+	DirectionOut Direction = "->"
+	DirectionIn  Direction = "<-"
+)
+
+// Operator is the comparison used by a predicate
+type Operator string
+
+const (
+	OpExists      Operator = ""
+	OpNotExists   Operator = "!"
+	OpEqual       Operator = "="
+	OpNotEqual    Operator = "!="
+	OpMatch       Operator = "~"
+	OpNotMatch    Operator = "!~"
+	OpLess        Operator = "<"
+	OpLessOrEqual Operator = "<="
+	OpMore        Operator = ">"
+	OpMoreOrEqual Operator = ">="
+)
+
+// LiteralKind is the type of value a predicate compares against
+type LiteralKind int
+
+const (
+	StringLiteral LiteralKind = iota
+	NumberLiteral
+	BoolLiteral
+	NullLiteral
+	RegexLiteral
+)
+
+// Literal is the right hand side of a predicate. Raw holds the unquoted string, the number or the regex pattern as
+// written. Flags is only used by regular expressions.
+type Literal struct {
+	Kind  LiteralKind
+	Raw   string
+	Flags string
+}
+
+// Predicate constrains a single key of an element
+type Predicate struct {
+	Key   string
+	Op    Operator
+	Value Literal
+	Pos   int
+}
+
+// Element is a single n() or e() pattern. Name is the letter as written, so upper case elements survive printing.
+type Element struct {
+	Kind       ElementKind
+	Name       string
+	Predicates []Predicate
+	Pos        int
+}
+
+// Query is the parsed form of a query string. Arrows[i] joins Elements[i] and Elements[i+1].
+type Query struct {
+	Elements []Element
+	Arrows   []Direction
+}
+
+// Length is the number of elements in the query, which is the length of each chain returned for it
+func (q *Query) Length() int {
+	return len(q.Elements)
+}
+
+// Types returns the value of any `type=` predicate for each element, or an empty string if it is unconstrained
+func (q *Query) Types() []string {
+	types := make([]string, len(q.Elements))
+	for idx, el := range q.Elements {
+		for _, p := range el.Predicates {
+			if p.Key == "type" && p.Op == OpEqual && p.Value.Kind == StringLiteral {
+				types[idx] = p.Value.Raw
+			}
+		}
+	}
+	return types
+}
+
+// String pretty-prints the query in its canonical form
+func (q *Query) String() string {
+	var sb strings.Builder
+	for idx, el := range q.Elements {
+		if idx > 0 {
+			sb.WriteString(string(q.Arrows[idx-1]))
+		}
+		sb.WriteString(el.String())
+	}
+	return sb.String()
+}
+
+func (e Element) String() string {
+	parts := make([]string, len(e.Predicates))
+	for idx, p := range e.Predicates {
+		parts[idx] = p.String()
+	}
+	return e.Name + "(" + strings.Join(parts, ",") + ")"
+}
+
+func (p Predicate) String() string {
+	key := p.Key
+	if !isIdentifier(key) {
+		key = quote(key)
+	}
+
+	switch p.Op {
+	case OpExists:
+		return key
+	case OpNotExists:
+		return "!" + key
+	}
+	return key + string(p.Op) + p.Value.String()
+}
+
+func (l Literal) String() string {
+	switch l.Kind {
+	case RegexLiteral:
+		return "/" + escapeRegex(l.Raw) + "/" + l.Flags
+	case NumberLiteral:
+		return l.Raw
+	case BoolLiteral, NullLiteral:
+		return l.Raw
+	}
+	return quote(l.Raw)
+}
+
+// quote double quotes s using only the escapes the parser reads back (\", \\, \n, \t and \r); every other byte is
+// written as it is
+func quote(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for idx := 0; idx < len(s); idx++ {
+		switch c := s[idx]; c {
+		case '"', '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\r':
+			sb.WriteString(`\r`)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// escapeRegex escapes the slashes in a regular expression that are not already escaped, leaving other escapes as
+// they are
+func escapeRegex(raw string) string {
+	var sb strings.Builder
+	for idx := 0; idx < len(raw); idx++ {
+		c := raw[idx]
+		switch {
+		case c == '\\' && idx+1 < len(raw):
+			sb.WriteByte(c)
+			idx++
+			sb.WriteByte(raw[idx])
+		case c == '/':
+			sb.WriteString(`\/`)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// isIdentifier reports whether a key can be printed without quotes
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for idx, r := range s {
+		if !isIdentRune(r, idx == 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func isIdentRune(r rune, first bool) bool {
+	switch {
+	case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		return true
+	case r >= '0' && r <= '9', r == '.':
+		return !first
+	}
+	return false
+}
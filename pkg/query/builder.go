@@ -0,0 +1,183 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Builder builds queries fluently instead of by string concatenation:
+//
+//	query.N().Type("domain").Where("value", "~", "evil").E("resolves").N().Type("ip")
+//
+// renders as n(type="domain",value~/evil/)->e(type="resolves")->n(type="ip"). Elements are joined with -> unless
+// In() or Any() is used to change the direction of the following arrows. Predicate methods apply to the most recently
+// added element. Errors are collected and returned by Err() and Query().
+type Builder struct {
+	q   Query
+	dir Direction
+	err error
+}
+
+// N starts a new builder with a node element, optionally constrained to a type
+func N(typ ...string) *Builder {
+	return (&Builder{dir: DirectionOut}).N(typ...)
+}
+
+// E starts a new builder with an edge element, optionally constrained to a type
+func E(typ ...string) *Builder {
+	return (&Builder{dir: DirectionOut}).E(typ...)
+}
+
+// N appends a node element, optionally constrained to a type
+func (b *Builder) N(typ ...string) *Builder {
+	return b.add(NodeElement, "n", typ)
+}
+
+// E appends an edge element, optionally constrained to a type
+func (b *Builder) E(typ ...string) *Builder {
+	return b.add(EdgeElement, "e", typ)
+}
+
+// Out joins the following elements with -> (the default)
+func (b *Builder) Out() *Builder {
+	b.dir = DirectionOut
+	return b
+}
+
+// In joins the following elements with <-
+func (b *Builder) In() *Builder {
+	b.dir = DirectionIn
+	return b
+}
+
+// Any joins the following elements with -, matching edges in either direction
+func (b *Builder) Any() *Builder {
+	b.dir = DirectionAny
+	return b
+}
+
+// Type constrains the current element to the given type
+func (b *Builder) Type(typ string) *Builder {
+	return b.Where("type", string(OpEqual), typ)
+}
+
+// Value constrains the current element to the given value
+func (b *Builder) Value(value string) *Builder {
+	return b.Where("value", string(OpEqual), value)
+}
+
+// Has requires the current element to have the given key
+func (b *Builder) Has(key string) *Builder {
+	return b.addPredicate(Predicate{Key: key, Op: OpExists})
+}
+
+// Missing requires the current element to not have the given key
+func (b *Builder) Missing(key string) *Builder {
+	return b.addPredicate(Predicate{Key: key, Op: OpNotExists})
+}
+
+// Where adds a `key op value` predicate to the current element. Values may be strings, numbers, bools, nil or a
+// *regexp.Regexp. Strings used with ~ or !~ are treated as regular expressions.
+func (b *Builder) Where(key, op string, value interface{}) *Builder {
+	operator := Operator(op)
+	switch operator {
+	case OpEqual, OpNotEqual, OpMatch, OpNotMatch, OpLess, OpLessOrEqual, OpMore, OpMoreOrEqual:
+	default:
+		b.setErr(fmt.Errorf("unknown operator %q for key %q", op, key))
+		return b
+	}
+
+	lit, err := toLiteral(value, operator == OpMatch || operator == OpNotMatch)
+	if err != nil {
+		b.setErr(fmt.Errorf("key %q: %w", key, err))
+		return b
+	}
+
+	return b.addPredicate(Predicate{Key: key, Op: operator, Value: lit})
+}
+
+// Length is the number of elements, which is the expected length of each chain returned for the query
+func (b *Builder) Length() int {
+	return b.q.Length()
+}
+
+// Err returns the first error encountered while building
+func (b *Builder) Err() error {
+	return b.err
+}
+
+// Query returns the built and validated query
+func (b *Builder) Query() (*Query, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	// copy the slices, so later calls on the builder do not change queries it has already returned
+	q := Query{
+		Elements: make([]Element, len(b.q.Elements)),
+		Arrows:   append([]Direction(nil), b.q.Arrows...),
+	}
+	for idx, el := range b.q.Elements {
+		el.Predicates = append([]Predicate(nil), el.Predicates...)
+		q.Elements[idx] = el
+	}
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
+
+// String renders the query string
+func (b *Builder) String() string {
+	return b.q.String()
+}
+
+func (b *Builder) add(kind ElementKind, name string, typ []string) *Builder {
+	if len(b.q.Elements) > 0 {
+		b.q.Arrows = append(b.q.Arrows, b.dir)
+	}
+	b.q.Elements = append(b.q.Elements, Element{Kind: kind, Name: name})
+	if len(typ) > 0 && typ[0] != "" {
+		b.Type(typ[0])
+	}
+	return b
+}
+
+func (b *Builder) addPredicate(p Predicate) *Builder {
+	if len(b.q.Elements) == 0 {
+		b.setErr(fmt.Errorf("predicate %q added before any element", p.Key))
+		return b
+	}
+	el := &b.q.Elements[len(b.q.Elements)-1]
+	el.Predicates = append(el.Predicates, p)
+	return b
+}
+
+func (b *Builder) setErr(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+func toLiteral(value interface{}, regex bool) (Literal, error) {
+	switch v := value.(type) {
+	case nil:
+		return Literal{Kind: NullLiteral, Raw: "null"}, nil
+	case *regexp.Regexp:
+		return Literal{Kind: RegexLiteral, Raw: v.String()}, nil
+	case string:
+		if regex {
+			return Literal{Kind: RegexLiteral, Raw: v}, nil
+		}
+		return Literal{Kind: StringLiteral, Raw: v}, nil
+	case bool:
+		return Literal{Kind: BoolLiteral, Raw: strconv.FormatBool(v)}, nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return Literal{Kind: NumberLiteral, Raw: fmt.Sprint(v)}, nil
+	case float32:
+		return Literal{Kind: NumberLiteral, Raw: strconv.FormatFloat(float64(v), 'g', -1, 32)}, nil
+	case float64:
+		return Literal{Kind: NumberLiteral, Raw: strconv.FormatFloat(v, 'g', -1, 64)}, nil
+	}
+	return Literal{}, fmt.Errorf("unsupported value type %T", value)
+}
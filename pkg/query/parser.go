@@ -0,0 +1,375 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// SyntaxError reports a problem with a query along with the byte offset at which it was found
+type SyntaxError struct {
+	Query string
+	Pos   int
+	Msg   string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("invalid query %q at offset %d: %s", e.Query, e.Pos, e.Msg)
+}
+
+type parser struct {
+	src string
+	pos int
+}
+
+// Parse turns a query string into a Query. It only checks syntax, use Validate (or Query.Validate) to also check
+// that the query is well formed.
+func Parse(s string) (*Query, error) {
+	p := &parser{src: s}
+	q := &Query{}
+
+	p.skipSpace()
+	if p.eof() {
+		return nil, p.errorf("query is empty")
+	}
+
+	for {
+		el, err := p.parseElement()
+		if err != nil {
+			return nil, err
+		}
+		q.Elements = append(q.Elements, el)
+
+		p.skipSpace()
+		if p.eof() {
+			break
+		}
+
+		arrow, err := p.parseArrow()
+		if err != nil {
+			return nil, err
+		}
+		q.Arrows = append(q.Arrows, arrow)
+		p.skipSpace()
+	}
+
+	return q, nil
+}
+
+// MustParse is like Parse but panics on error. It is intended for queries which are constants in code.
+func MustParse(s string) *Query {
+	q, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+// Validate parses and validates a query string
+func Validate(s string) error {
+	q, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	return q.Validate()
+}
+
+func (p *parser) eof() bool { return p.pos >= len(p.src) }
+
+func (p *parser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{Query: p.src, Pos: p.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) skipSpace() {
+	for !p.eof() && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *parser) expect(c byte) error {
+	p.skipSpace()
+	if p.peek() != c {
+		if p.eof() {
+			return p.errorf("expected %q, got end of query", c)
+		}
+		return p.errorf("expected %q, got %q", c, p.peek())
+	}
+	p.pos++
+	return nil
+}
+
+func (p *parser) parseElement() (Element, error) {
+	el := Element{Pos: p.pos}
+
+	switch p.peek() {
+	case 'n', 'N':
+		el.Kind = NodeElement
+	case 'e', 'E':
+		el.Kind = EdgeElement
+	default:
+		if p.eof() {
+			return el, p.errorf("expected n() or e(), got end of query")
+		}
+		return el, p.errorf("expected n() or e(), got %q", p.peek())
+	}
+	el.Name = string(p.peek())
+	p.pos++
+
+	if err := p.expect('('); err != nil {
+		return el, err
+	}
+
+	p.skipSpace()
+	if p.peek() == ')' {
+		p.pos++
+		return el, nil
+	}
+
+	for {
+		pred, err := p.parsePredicate()
+		if err != nil {
+			return el, err
+		}
+		el.Predicates = append(el.Predicates, pred)
+
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ')':
+			p.pos++
+			return el, nil
+		default:
+			if p.eof() {
+				return el, p.errorf("unterminated %s(), expected ')'", el.Name)
+			}
+			return el, p.errorf("expected ',' or ')', got %q", p.peek())
+		}
+	}
+}
+
+func (p *parser) parseArrow() (Direction, error) {
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "->"):
+		p.pos += 2
+		return DirectionOut, nil
+	case strings.HasPrefix(p.src[p.pos:], "<-"):
+		p.pos += 2
+		return DirectionIn, nil
+	case p.peek() == '-':
+		p.pos++
+		return DirectionAny, nil
+	}
+	return "", p.errorf("expected '->', '<-' or '-', got %q", p.peek())
+}
+
+func (p *parser) parsePredicate() (Predicate, error) {
+	p.skipSpace()
+	pred := Predicate{Pos: p.pos}
+
+	negate := false
+	if p.peek() == '!' {
+		negate = true
+		p.pos++
+		p.skipSpace()
+	}
+
+	key, err := p.parseKey()
+	if err != nil {
+		return pred, err
+	}
+	pred.Key = key
+
+	p.skipSpace()
+	op := p.parseOperator()
+	if negate {
+		if op != OpExists {
+			return pred, p.errorf("'!' can only be used to test that %q does not exist", key)
+		}
+		pred.Op = OpNotExists
+		return pred, nil
+	}
+	pred.Op = op
+	if op == OpExists {
+		return pred, nil
+	}
+
+	p.skipSpace()
+	lit, err := p.parseLiteral()
+	if err != nil {
+		return pred, err
+	}
+	pred.Value = lit
+
+	return pred, nil
+}
+
+func (p *parser) parseKey() (string, error) {
+	if c := p.peek(); c == '"' || c == '\'' {
+		return p.parseQuoted()
+	}
+
+	start := p.pos
+	for !p.eof() && isIdentRune(rune(p.peek()), p.pos == start) {
+		p.pos++
+	}
+	if start == p.pos {
+		if p.eof() {
+			return "", p.errorf("expected a key, got end of query")
+		}
+		return "", p.errorf("expected a key, got %q", p.peek())
+	}
+	return p.src[start:p.pos], nil
+}
+
+func (p *parser) parseOperator() Operator {
+	// longest operators first
+	for _, op := range []Operator{OpNotEqual, OpNotMatch, OpLessOrEqual, OpMoreOrEqual, OpEqual, OpMatch, OpLess, OpMore} {
+		if strings.HasPrefix(p.src[p.pos:], string(op)) {
+			p.pos += len(op)
+			return op
+		}
+	}
+	return OpExists
+}
+
+func (p *parser) parseLiteral() (Literal, error) {
+	switch c := p.peek(); {
+	case c == '"' || c == '\'':
+		s, err := p.parseQuoted()
+		return Literal{Kind: StringLiteral, Raw: s}, err
+	case c == '/':
+		return p.parseRegex()
+	case c == '-' || c == '+' || (c >= '0' && c <= '9'):
+		return p.parseNumber()
+	}
+
+	// bare words
+	start := p.pos
+	for !p.eof() && isBareRune(p.peek()) {
+		p.pos++
+	}
+	if start == p.pos {
+		if p.eof() {
+			return Literal{}, p.errorf("expected a value, got end of query")
+		}
+		return Literal{}, p.errorf("expected a value, got %q", p.peek())
+	}
+
+	word := p.src[start:p.pos]
+	switch word {
+	case "true", "false":
+		return Literal{Kind: BoolLiteral, Raw: word}, nil
+	case "null", "None":
+		return Literal{Kind: NullLiteral, Raw: "null"}, nil
+	}
+	return Literal{Kind: StringLiteral, Raw: word}, nil
+}
+
+func isBareRune(c byte) bool {
+	return isIdentRune(rune(c), false) || c == '-' || c == ':' || c == '@'
+}
+
+func (p *parser) parseQuoted() (string, error) {
+	quote := p.peek()
+	start := p.pos
+	p.pos++
+
+	var sb strings.Builder
+	for !p.eof() {
+		c := p.peek()
+		p.pos++
+		switch c {
+		case quote:
+			return sb.String(), nil
+		case '\\':
+			if p.eof() {
+				break
+			}
+			esc := p.peek()
+			p.pos++
+			switch esc {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			default:
+				sb.WriteByte(esc)
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	p.pos = start
+	return "", p.errorf("unterminated string")
+}
+
+func (p *parser) parseRegex() (Literal, error) {
+	start := p.pos
+	p.pos++
+
+	var sb strings.Builder
+	for !p.eof() {
+		c := p.peek()
+		p.pos++
+		// an escape is read as a pair, so an escaped backslash cannot escape the closing slash
+		if c == '\\' && !p.eof() {
+			esc := p.peek()
+			p.pos++
+			if esc != '/' {
+				sb.WriteByte(c)
+			}
+			sb.WriteByte(esc)
+			continue
+		}
+		if c == '/' {
+			flagStart := p.pos
+			for !p.eof() && unicode.IsLetter(rune(p.peek())) {
+				p.pos++
+			}
+			return Literal{Kind: RegexLiteral, Raw: sb.String(), Flags: p.src[flagStart:p.pos]}, nil
+		}
+		sb.WriteByte(c)
+	}
+
+	p.pos = start
+	return Literal{}, p.errorf("unterminated regular expression")
+}
+
+func (p *parser) parseNumber() (Literal, error) {
+	start := p.pos
+	if c := p.peek(); c == '-' || c == '+' {
+		p.pos++
+	}
+	digits := 0
+	for !p.eof() {
+		c := p.peek()
+		if (c >= '0' && c <= '9') || c == '.' || c == 'e' || c == 'E' {
+			digits++
+			p.pos++
+			continue
+		}
+		if (c == '-' || c == '+') && (p.src[p.pos-1] == 'e' || p.src[p.pos-1] == 'E') {
+			p.pos++
+			continue
+		}
+		break
+	}
+
+	raw := p.src[start:p.pos]
+	if digits == 0 || !isNumber(raw) {
+		p.pos = start
+		return Literal{}, p.errorf("invalid number %q", raw)
+	}
+	return Literal{Kind: NumberLiteral, Raw: raw}, nil
+}
@@ -0,0 +1,165 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseSimple(t *testing.T) {
+	assert := assert.New(t)
+
+	q, err := Parse("n()")
+	assert.NoError(err)
+	assert.Equal(1, q.Length())
+	assert.NoError(q.Validate())
+
+	q, err = Parse("n()->e()->n()")
+	assert.NoError(err)
+	assert.Equal(3, q.Length())
+	assert.Equal([]Direction{DirectionOut, DirectionOut}, q.Arrows)
+	assert.Equal(EdgeElement, q.Elements[1].Kind)
+	assert.NoError(q.Validate())
+}
+
+func Test_ParsePredicates(t *testing.T) {
+	assert := assert.New(t)
+
+	q, err := Parse(`n(type="domain", value~/evil\/ish/i, !seen) <- E(type='resolves', weight>=0.5) <- N(type=ip, ID=3, active=true, note)`)
+	assert.NoError(err)
+	assert.NoError(q.Validate())
+	assert.Equal(3, q.Length())
+
+	n := q.Elements[0]
+	assert.Len(n.Predicates, 3)
+	assert.Equal(Predicate{Key: "type", Op: OpEqual, Value: Literal{Kind: StringLiteral, Raw: "domain"}, Pos: 2}, n.Predicates[0])
+	assert.Equal(Literal{Kind: RegexLiteral, Raw: "evil/ish", Flags: "i"}, n.Predicates[1].Value)
+	assert.Equal(OpNotExists, n.Predicates[2].Op)
+
+	e := q.Elements[1]
+	assert.Equal("E", e.Name)
+	assert.Equal(Literal{Kind: NumberLiteral, Raw: "0.5"}, e.Predicates[1].Value)
+
+	assert.Equal([]string{"domain", "resolves", "ip"}, q.Types())
+
+	// pretty printing is canonical and re-parses to the same query
+	printed := q.String()
+	assert.Equal(`n(type="domain",value~/evil\/ish/i,!seen)<-E(type="resolves",weight>=0.5)<-N(type="ip",ID=3,active=true,note)`, printed)
+
+	q2, err := Parse(printed)
+	assert.NoError(err)
+	assert.Equal(printed, q2.String())
+}
+
+func Test_RoundTrip(t *testing.T) {
+	queries := []*Query{
+		// a regex ending in an escaped backslash, and one with an escaped slash
+		MustParse(`n(value~/a\\/)`),
+		MustParse(`n(value~/a\/b\d/i)`),
+		// strings with bytes strconv.Quote would write as \x.., \u.... or \a
+		{Elements: []Element{{Kind: NodeElement, Name: "n", Predicates: []Predicate{
+			{Key: "bell", Op: OpEqual, Value: Literal{Kind: StringLiteral, Raw: "a\x07b"}},
+			{Key: "sep", Op: OpEqual, Value: Literal{Kind: StringLiteral, Raw: "x\u2028y\x00z"}},
+			{Key: "odd key", Op: OpEqual, Value: Literal{Kind: StringLiteral, Raw: "caf\u00e9 \"q\" \\ \n\t\r"}},
+		}}}},
+	}
+	assert.Equal(t, `a\\`, queries[0].Elements[0].Predicates[0].Value.Raw)
+	assert.Equal(t, `a/b\d`, queries[1].Elements[0].Predicates[0].Value.Raw)
+
+	for _, q := range queries {
+		parsed, err := Parse(q.String())
+		if assert.NoError(t, err, q.String()) {
+			for idx, p := range q.Elements[0].Predicates {
+				assert.Equal(t, p.Key, parsed.Elements[0].Predicates[idx].Key)
+				assert.Equal(t, p.Value, parsed.Elements[0].Predicates[idx].Value)
+			}
+			assert.Equal(t, q.String(), parsed.String())
+		}
+	}
+}
+
+func Test_ParseErrors(t *testing.T) {
+	bad := []string{
+		"",
+		"x()",
+		"n(",
+		"n()->",
+		"n()=>e()",
+		`n(type="foo)`,
+		"n(value~/foo)",
+		"n(type=)",
+		"n(!type=foo)",
+		"n(x=1.2.3)",
+	}
+
+	for _, s := range bad {
+		_, err := Parse(s)
+		assert.Error(t, err, s)
+		_, ok := err.(*SyntaxError)
+		assert.True(t, ok, s)
+	}
+}
+
+func Test_ValidateErrors(t *testing.T) {
+	bad := []string{
+		"n()->n()",
+		"e()->e()",
+		"n()->e()<-n()",
+		"n(value~/foo/q)",
+		"n(value=/foo/)",
+		"n(ID=abc)",
+		"n(count<true)",
+	}
+
+	for _, s := range bad {
+		q, err := Parse(s)
+		assert.NoError(t, err, s)
+		err = q.Validate()
+		assert.Error(t, err, s)
+		_, ok := err.(*ValidationError)
+		assert.True(t, ok, s)
+	}
+
+	// every problem is reported at once
+	err := Validate("n(ID=abc)->n()")
+	assert.Len(t, err.(*ValidationError).Problems, 2)
+
+	assert.NoError(t, Validate("e()"))
+	assert.NoError(t, Validate("n()-e()-n()"))
+}
+
+func Test_Builder(t *testing.T) {
+	assert := assert.New(t)
+
+	b := N().Type("domain").Where("value", "~", "evil").E("resolves").N().Type("ip")
+	assert.Equal(`n(type="domain",value~/evil/)->e(type="resolves")->n(type="ip")`, b.String())
+	assert.Equal(3, b.Length())
+
+	q, err := b.Query()
+	assert.NoError(err)
+	assert.Equal(b.String(), q.String())
+
+	// a returned query is not changed by later calls on the builder
+	rendered := q.String()
+	b.Where("asn", "=", 13335).E().N()
+	assert.Equal(rendered, q.String())
+	b = N().Type("domain").Where("value", "~", "evil").E("resolves").N().Type("ip")
+
+	b = N("ip").In().E().Any().N().Where("port", ">=", 1024).Has("banner").Missing("seen").Where("up", "=", true)
+	assert.Equal(`n(type="ip")<-e()-n(port>=1024,banner,!seen,up=true)`, b.String())
+	_, err = b.Query()
+	assert.NoError(err)
+
+	// the rendered query parses back to the same thing
+	parsed, err := Parse(b.String())
+	assert.NoError(err)
+	assert.Equal(b.String(), parsed.String())
+
+	b = N().Where("value", "=~", "x")
+	assert.Error(b.Err())
+	_, err = b.Query()
+	assert.Error(err)
+
+	_, err = N().N().Query()
+	assert.Error(err)
+}
@@ -0,0 +1,104 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Flags accepted after a regular expression literal
+const regexFlags = "imsx"
+
+// ValidationError collects every problem found with a parsed query
+type ValidationError struct {
+	Query    string
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid query %q: %s", e.Query, strings.Join(e.Problems, "; "))
+}
+
+// Validate checks that a parsed query is well formed:
+// - elements alternate between nodes and edges
+// - the arrows on either side of an edge point the same way
+// - operators are used with literals they can compare against
+func (q *Query) Validate() error {
+	var problems []string
+	addProblem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if len(q.Elements) == 0 {
+		addProblem("query has no elements")
+	}
+
+	if len(q.Arrows) != len(q.Elements)-1 && len(q.Elements) > 0 {
+		addProblem("query has %d elements but %d arrows", len(q.Elements), len(q.Arrows))
+	}
+
+	for idx, el := range q.Elements {
+		if idx > 0 && el.Kind == q.Elements[idx-1].Kind {
+			addProblem("element %d (%s()) follows another %s, nodes and edges must alternate", idx, el.Name, el.Kind)
+		}
+
+		if el.Kind == EdgeElement && idx > 0 && idx < len(q.Arrows) {
+			in, out := q.Arrows[idx-1], q.Arrows[idx]
+			if in != DirectionAny && out != DirectionAny && in != out {
+				addProblem("element %d (%s()) has arrows pointing in opposite directions (%s and %s)", idx, el.Name, in, out)
+			}
+		}
+
+		for _, pred := range el.Predicates {
+			if err := pred.validate(); err != nil {
+				addProblem("element %d (%s()): %s", idx, el.Name, err)
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Query: q.String(), Problems: problems}
+	}
+	return nil
+}
+
+func (p Predicate) validate() error {
+	if p.Key == "" {
+		return fmt.Errorf("predicate has an empty key")
+	}
+
+	switch p.Op {
+	case OpExists, OpNotExists:
+		return nil
+	case OpMatch, OpNotMatch:
+		if p.Value.Kind != RegexLiteral && p.Value.Kind != StringLiteral {
+			return fmt.Errorf("%s%s requires a regular expression", p.Key, p.Op)
+		}
+		for _, f := range p.Value.Flags {
+			if !strings.ContainsRune(regexFlags, f) {
+				return fmt.Errorf("%s%s has unknown regular expression flag %q", p.Key, p.Op, f)
+			}
+		}
+	case OpLess, OpLessOrEqual, OpMore, OpMoreOrEqual:
+		if p.Value.Kind != NumberLiteral && p.Value.Kind != StringLiteral {
+			return fmt.Errorf("%s%s requires a number or string", p.Key, p.Op)
+		}
+	case OpEqual, OpNotEqual:
+		if p.Value.Kind == RegexLiteral {
+			return fmt.Errorf("%s%s cannot compare against a regular expression, use ~ instead", p.Key, p.Op)
+		}
+	default:
+		return fmt.Errorf("unknown operator %q", p.Op)
+	}
+
+	if p.Key == "ID" && p.Op != OpMatch && p.Op != OpNotMatch && p.Value.Kind != NumberLiteral {
+		return fmt.Errorf("ID can only be compared against numbers")
+	}
+
+	return nil
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
@@ -0,0 +1,287 @@
+package task
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// States are the states a task can be in. TaskState_Delete is only ever a state to move to.
+var States = []TaskState{TaskState_Active, TaskState_Idle, TaskState_Done, TaskState_Errr, TaskState_Retry, TaskState_Void}
+
+type conditionKind int
+
+const (
+	conditionDefault conditionKind = iota
+	conditionSetTo
+	conditionStates
+	conditionMatch
+)
+
+// TaskStateCondition is the state sent with task results. It decides whether the results are accepted, given the
+// task's current state, and what the task's state becomes. It has one of four forms on the wire:
+//
+//	nil (or a nil *TaskStateCondition)  post if active or idle, and set the task to done
+//	"error"                             post if active or idle, and set the task to the given state (StateSetTo)
+//	["active", "idle"]                  post if the task is in one of the states, and leave it as it is (StateIn)
+//	{"error": "retry"}                  post if the task is in a key's state, and set it to the value (StateSetMatch)
+type TaskStateCondition struct {
+	kind   conditionKind
+	to     TaskState
+	states []TaskState
+	match  map[TaskState]TaskState
+}
+
+// StateSetTo accepts results for an active or idle task and moves it to state
+func StateSetTo(state TaskState) *TaskStateCondition {
+	return &TaskStateCondition{kind: conditionSetTo, to: state}
+}
+
+// StateIn accepts results for a task in one of states, leaving its state unchanged
+func StateIn(states ...TaskState) *TaskStateCondition {
+	return &TaskStateCondition{kind: conditionStates, states: append([]TaskState{}, states...)}
+}
+
+// StateSetMatch accepts results for a task in one of the map's keys, and moves it to that key's value
+func StateSetMatch(transitions map[TaskState]TaskState) *TaskStateCondition {
+	match := make(map[TaskState]TaskState, len(transitions))
+	for from, to := range transitions {
+		match[from] = to
+	}
+	return &TaskStateCondition{kind: conditionMatch, match: match}
+}
+
+// From lists the states in which a task accepts the results, ordered by name
+func (c *TaskStateCondition) From() []TaskState {
+	var states []TaskState
+	switch c.getKind() {
+	case conditionStates:
+		states = append(states, c.states...)
+	case conditionMatch:
+		for from := range c.match {
+			states = append(states, from)
+		}
+	default:
+		states = []TaskState{TaskState_Active, TaskState_Idle}
+	}
+	sortStates(states)
+	return states
+}
+
+// Next returns the state a task in current is left in, and false if the task does not accept the results
+func (c *TaskStateCondition) Next(current TaskState) (TaskState, bool) {
+	switch c.getKind() {
+	case conditionSetTo:
+		if current == TaskState_Active || current == TaskState_Idle {
+			return c.to, true
+		}
+	case conditionStates:
+		for _, state := range c.states {
+			if state == current {
+				return current, true
+			}
+		}
+	case conditionMatch:
+		if to, ok := c.match[current]; ok {
+			return to, true
+		}
+	default:
+		if current == TaskState_Active || current == TaskState_Idle {
+			return TaskState_Done, true
+		}
+	}
+	return current, false
+}
+
+// targets lists the states a task accepting the results can be left in
+func (c *TaskStateCondition) targets() []TaskState {
+	switch c.getKind() {
+	case conditionSetTo:
+		return []TaskState{c.to}
+	case conditionStates:
+		return c.states
+	case conditionMatch:
+		var states []TaskState
+		for _, to := range c.match {
+			states = append(states, to)
+		}
+		return states
+	default:
+		return []TaskState{TaskState_Done}
+	}
+}
+
+func (c *TaskStateCondition) getKind() conditionKind {
+	if c == nil {
+		return conditionDefault
+	}
+	return c.kind
+}
+
+// Validate checks the condition against LemonGrenade's task states: a task is only ever in one of States, and
+// moves to any of them but active (retry queues a task to become active again) or to the delete pseudostate.
+func (c *TaskStateCondition) Validate() error {
+	var errs []error
+	checkFrom := func(state TaskState) {
+		if !isState(state) {
+			errs = append(errs, fmt.Errorf("a task is never in state %q", state))
+		}
+	}
+	checkTo := func(state TaskState) {
+		switch {
+		case state == TaskState_Active:
+			errs = append(errs, fmt.Errorf("a task cannot be set to active, use retry to reissue it"))
+		case state != TaskState_Delete && !isState(state):
+			errs = append(errs, fmt.Errorf("unknown task state %q", state))
+		}
+	}
+
+	switch c.getKind() {
+	case conditionSetTo:
+		checkTo(c.to)
+	case conditionStates:
+		if len(c.states) == 0 {
+			errs = append(errs, fmt.Errorf("a state list must have at least one state"))
+		}
+		for _, state := range c.states {
+			checkFrom(state)
+		}
+	case conditionMatch:
+		if len(c.match) == 0 {
+			errs = append(errs, fmt.Errorf("a state map must have at least one state"))
+		}
+		for _, from := range c.From() {
+			checkFrom(from)
+			checkTo(c.match[from])
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func isState(state TaskState) bool {
+	for _, s := range States {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+func sortStates(states []TaskState) {
+	sort.Slice(states, func(i, j int) bool { return states[i] < states[j] })
+}
+
+func joinStates(states []TaskState) string {
+	names := make([]string, len(states))
+	for idx, state := range states {
+		names[idx] = string(state)
+	}
+	return strings.Join(names, "|")
+}
+
+// String describes the transition, for logging, e.g. "active|idle -> done" or "error -> retry, void -> retry"
+func (c *TaskStateCondition) String() string {
+	switch c.getKind() {
+	case conditionSetTo:
+		return fmt.Sprintf("active|idle -> %s", c.to)
+	case conditionStates:
+		states := append([]TaskState{}, c.states...)
+		sortStates(states)
+		return fmt.Sprintf("%s (unchanged)", joinStates(states))
+	case conditionMatch:
+		var parts []string
+		for _, from := range c.From() {
+			parts = append(parts, fmt.Sprintf("%s -> %s", from, c.match[from]))
+		}
+		return strings.Join(parts, ", ")
+	default:
+		return "active|idle -> done"
+	}
+}
+
+func (c *TaskStateCondition) MarshalJSON() ([]byte, error) {
+	switch c.getKind() {
+	case conditionSetTo:
+		return json.Marshal(c.to)
+	case conditionStates:
+		return json.Marshal(c.states)
+	case conditionMatch:
+		return json.Marshal(c.match)
+	default:
+		return []byte("null"), nil
+	}
+}
+
+func (c *TaskStateCondition) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	cond, err := conditionOf(v)
+	if err != nil {
+		return err
+	}
+	if cond == nil {
+		cond = &TaskStateCondition{}
+	}
+	*c = *cond
+	return nil
+}
+
+// conditionOf converts any of the forms a state has been given in: a *TaskStateCondition, a TaskState or string, a
+// list of them, a map of them, or nil
+func conditionOf(state interface{}) (*TaskStateCondition, error) {
+	switch s := state.(type) {
+	case nil:
+		return nil, nil
+	case *TaskStateCondition:
+		return s, nil
+	case TaskStateCondition:
+		return &s, nil
+	case TaskState:
+		return StateSetTo(s), nil
+	case string:
+		return StateSetTo(TaskState(s)), nil
+	case []TaskState:
+		return StateIn(s...), nil
+	case []string:
+		states := make([]TaskState, len(s))
+		for idx, v := range s {
+			states[idx] = TaskState(v)
+		}
+		return StateIn(states...), nil
+	case []interface{}:
+		states := make([]TaskState, len(s))
+		for idx, v := range s {
+			str, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("task state list must only hold strings, not %T", v)
+			}
+			states[idx] = TaskState(str)
+		}
+		return StateIn(states...), nil
+	case map[TaskState]TaskState:
+		return StateSetMatch(s), nil
+	case map[string]string:
+		match := make(map[TaskState]TaskState, len(s))
+		for from, to := range s {
+			match[TaskState(from)] = TaskState(to)
+		}
+		return StateSetMatch(match), nil
+	case map[string]interface{}:
+		match := make(map[TaskState]TaskState, len(s))
+		for from, to := range s {
+			str, ok := to.(string)
+			if !ok {
+				return nil, fmt.Errorf("task state map must only hold strings, not %T", to)
+			}
+			match[TaskState(from)] = TaskState(str)
+		}
+		return StateSetMatch(match), nil
+	default:
+		return nil, fmt.Errorf("a task state must be a string, a list or a map, not %T", state)
+	}
+}
@@ -2,8 +2,6 @@ package task
 
 import (
 	"encoding/json"
-	"fmt"
-	"reflect"
 
 	"github.com/skyleronken/lemonclient/pkg/adapter"
 	"github.com/skyleronken/lemonclient/pkg/graph"
@@ -21,19 +19,14 @@ const (
 	TaskState_Delete TaskState = "delete" // pseudostate, set manually to delete task from job
 )
 
-// string = will post results and set state to the provided task state if currently `active` or `idle`
-// []string = will post results if state is in the list, then doesnt change the state
-// map[string]string = will pst results if a key exists with the current state, then changes the state to the value assciated with the key
-// nil = post results and set an `active` or `idle` task to `done`
-
 type TaskResultsOpts struct {
-	State    interface{}                    `json:"state,omitempty"`
-	Timeout  uint                           `json:"timeout,omitempty"`
-	Details  string                         `json:"details,omitempty"`
-	Nodes    []graph.NodeInterface          `json:"nodes,omitempty"`
-	Chains   []graph.ChainInterface         `json:"chains,omitempty"`
-	Edges    []graph.EdgeInterface          `json:"edges,omitempty"`
-	Adapters map[string]adapter.AdapterOpts `json:"adapters,omitempty"`
+	State    *TaskStateCondition                `json:"state,omitempty"` // nil posts to an active or idle task and sets it to done
+	Timeout  uint                               `json:"timeout,omitempty"`
+	Details  string                             `json:"details,omitempty"`
+	Nodes    []graph.NodeInterface              `json:"nodes,omitempty"`
+	Chains   []graph.ChainInterface             `json:"chains,omitempty"`
+	Edges    []graph.EdgeInterface              `json:"edges,omitempty"`
+	Adapters map[string]adapter.AdapterOptsList `json:"adapters,omitempty"` // was map[string]adapter.AdapterOpts, see adapter.ListsFrom
 }
 
 type TaskResults struct {
@@ -46,21 +39,31 @@ func defaultOpts() TaskResultsOpts {
 	return TaskResultsOpts{}
 }
 
+// WithStateSetTo posts the results if the task is active or idle, and sets it to state
 func WithStateSetTo(state TaskState) TaskResultsOptsFunc {
-	return func(opts *TaskResultsOpts) {
-		opts.State = state
-	}
+	return WithStateCondition(StateSetTo(state))
 }
 
+// WithStates posts the results if the task is in one of states, and leaves its state unchanged
 func WithStates(states []TaskState) TaskResultsOptsFunc {
+	return WithStateCondition(StateIn(states...))
+}
+
+// WithStateSetMatch posts the results if the task is in one of the map's keys, and sets it to the key's value
+func WithStateSetMatch(statesmap map[TaskState]TaskState) TaskResultsOptsFunc {
+	return WithStateCondition(StateSetMatch(statesmap))
+}
+
+// WithStateCondition sets the state condition the results are posted with
+func WithStateCondition(condition *TaskStateCondition) TaskResultsOptsFunc {
 	return func(opts *TaskResultsOpts) {
-		opts.State = states
+		opts.State = condition
 	}
 }
 
-func WithStateSetMatch(statesmap map[TaskState]TaskState) TaskResultsOptsFunc {
+func WithDetails(details string) TaskResultsOptsFunc {
 	return func(opts *TaskResultsOpts) {
-		opts.State = statesmap
+		opts.Details = details
 	}
 }
 
@@ -82,12 +85,18 @@ func WithChains(chains ...graph.ChainInterface) TaskResultsOptsFunc {
 	}
 }
 
+// WithAdapters configures adapters on the job along with the results. An adapter may be given more than once with
+// different queries to have it watch each of them.
 func WithAdapters(adapters ...adapter.Adapter) TaskResultsOptsFunc {
 	return func(opts *TaskResultsOpts) {
+		if opts.Adapters == nil {
+			opts.Adapters = map[string]adapter.AdapterOptsList{}
+		}
 		for idx := range adapters {
-			// TODO: If key already exists, provide a list of parameters instead of just a single config
 			adapter := adapters[idx]
-			opts.Adapters[adapter.Name] = adapter.AdapterOpts
+			list := opts.Adapters[adapter.Name]
+			list.Add(adapter.AdapterOpts)
+			opts.Adapters[adapter.Name] = list
 		}
 	}
 }
@@ -103,99 +112,51 @@ func PrepareTaskResults(opts ...TaskResultsOptsFunc) *TaskResults {
 	}
 }
 
+// CheckState reports whether results posted with state can leave a task in any of states. state may be a
+// *TaskStateCondition or any of the forms one is built from: a TaskState or string, a list of them, a map of them,
+// or nil, which leaves the task done.
 func CheckState(state interface{}, states ...TaskState) bool {
-	// Get value and type using reflection
-	val := reflect.ValueOf(state)
-	kind := val.Kind()
-
-	switch kind {
-	case reflect.Slice, reflect.Array:
-		// Handle list/array case
-		for i := 0; i < val.Len(); i++ {
-			item := val.Index(i).Interface()
-			if ts, ok := item.(TaskState); ok {
-				for _, checkState := range states {
-					if ts == checkState {
-						return true
-					}
-				}
-			}
-		}
+	cond, err := conditionOf(state)
+	if err != nil {
 		return false
-
-	case reflect.String:
-		// Handle string case
-		stateStr := TaskState(val.String())
+	}
+	for _, target := range cond.targets() {
 		for _, checkState := range states {
-			if stateStr == checkState {
+			if target == checkState {
 				return true
 			}
 		}
-		return false
+	}
+	return false
+}
 
-	case reflect.Map:
-		// Handle map[string]string case
-		if val.Type().Key().Kind() == reflect.String && val.Type().Elem().Kind() == reflect.String {
-			for _, key := range val.MapKeys() {
-				if strVal := TaskState(val.MapIndex(key).Interface().(string)); strVal != "" {
-					for _, checkState := range states {
-						if strVal == checkState {
-							return true
-						}
-					}
-				}
-			}
-		}
-		return false
+// MarshalJSON encodes nodes, edges and chains with graph's canonical encoders, as job.Job does, so that
+// UnmarshalJSON reads back exactly what was written
+func (r TaskResults) MarshalJSON() ([]byte, error) {
+	type Alias TaskResults
 
-	default:
-		// For any other type, return false
-		return false
+	tTaskResults := &struct {
+		Nodes  []json.RawMessage   `json:"nodes,omitempty"`
+		Edges  []json.RawMessage   `json:"edges,omitempty"`
+		Chains [][]json.RawMessage `json:"chains,omitempty"`
+		*Alias
+	}{
+		Alias: (*Alias)(&r),
 	}
-}
 
-//func (r TaskResults) MarshalJSON() ([]byte, error) {
-// type Alias TaskResults
-
-// tTaskResults := &struct {
-// 	Nodes  []json.RawMessage `json:"nodes,omitempty"`
-// 	Edges  []json.RawMessage `json:"edges,omitempty"`
-// 	Chains []json.RawMessage `json:"chains,omitempty"`
-// 	*Alias
-// }{
-// 	Alias: (*Alias)(&r),
-// }
-
-// // Marshal edges directly
-// for _, edge := range r.Edges {
-// 	edgeJson, err := json.Marshal(edge)
-// 	if err != nil {
-// 		return nil, fmt.Errorf("failed to marshal edge: %w", err)
-// 	}
-// 	tTaskResults.Edges = append(tTaskResults.Edges, edgeJson)
-// }
-
-// // Marshal nodes directly
-// for _, node := range r.Nodes {
-// 	nodeJson, err := json.Marshal(node)
-// 	if err != nil {
-// 		return nil, fmt.Errorf("failed to marshal node: %w", err)
-// 	}
-// 	tTaskResults.Nodes = append(tTaskResults.Nodes, nodeJson)
-// }
-
-// // Marshal chains directly
-// for _, chain := range r.Chains {
-// 	chainJson, err := json.Marshal(chain)
-// 	if err != nil {
-// 		return nil, fmt.Errorf("failed to marshal chain: %w", err)
-// 	}
-// 	tTaskResults.Chains = append(tTaskResults.Chains, chainJson)
-// }
-
-// return json.Marshal(tTaskResults)
-
-//}
+	var err error
+	if tTaskResults.Nodes, err = graph.NodesToJson(r.Nodes, false); err != nil {
+		return nil, err
+	}
+	if tTaskResults.Edges, err = graph.EdgesToJson(r.Edges, false); err != nil {
+		return nil, err
+	}
+	if tTaskResults.Chains, err = graph.ChainsToJson(r.Chains, false); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(tTaskResults)
+}
 
 func (r *TaskResults) UnmarshalJSON(data []byte) error {
 	type Alias TaskResults
@@ -213,38 +174,15 @@ func (r *TaskResults) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
-	// Convert node JSON back to NodeInterface
-	r.Nodes = make([]graph.NodeInterface, 0, len(tTaskResults.Nodes))
-	for _, nodeJson := range tTaskResults.Nodes {
-		node, err := graph.JsonToNode(nodeJson)
-		if err != nil {
-			return fmt.Errorf("failed to unmarshal node: %w", err)
-		}
-		r.Nodes = append(r.Nodes, node)
+	var err error
+	if r.Nodes, err = graph.JsonToNodes(tTaskResults.Nodes); err != nil {
+		return err
 	}
-
-	// Convert edge JSON back to EdgeInterface
-	r.Edges = make([]graph.EdgeInterface, 0, len(tTaskResults.Edges))
-	for _, edgeJson := range tTaskResults.Edges {
-		edge, err := graph.JsonToEdge(edgeJson)
-		if err != nil {
-			return fmt.Errorf("failed to unmarshal edge: %w", err)
-		}
-		r.Edges = append(r.Edges, edge)
+	if r.Edges, err = graph.JsonToEdges(tTaskResults.Edges); err != nil {
+		return err
 	}
-
-	// Convert chain JSON back to ChainInterface
-	r.Chains = make([]graph.ChainInterface, 0, len(tTaskResults.Chains))
-	for _, chainElements := range tTaskResults.Chains {
-		chainBytes := make([][]byte, len(chainElements))
-		for i, element := range chainElements {
-			chainBytes[i] = []byte(element)
-		}
-		chain, err := graph.JsonToChain(chainBytes)
-		if err != nil {
-			return fmt.Errorf("failed to unmarshal chain: %w", err)
-		}
-		r.Chains = append(r.Chains, chain)
+	if r.Chains, err = graph.JsonToChains(tTaskResults.Chains); err != nil {
+		return err
 	}
 
 	return nil
@@ -1 +1,200 @@
 package task
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/skyleronken/lemonclient/pkg/adapter"
+	"github.com/skyleronken/lemonclient/pkg/graph"
+	"github.com/skyleronken/lemonclient/pkg/job"
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+type testNode struct {
+	graph.NodeMembers `mapstructure:",squash"`
+	Note              string
+}
+
+type testEdge struct {
+	graph.EdgeMembers `mapstructure:",squash"`
+	TTL               int
+}
+
+func Test_WithAdapters(t *testing.T) {
+	domains := adapter.ConfigureAdapter("whois", adapter.WithQuery(`n(type="domain")`))
+	ips := adapter.ConfigureAdapter("whois", adapter.WithQuery(`n(type="ip")`))
+	dns := adapter.ConfigureAdapter("dns", adapter.WithQuery(`n(type="domain")`))
+
+	r := PrepareTaskResults(WithAdapters(*domains, *ips, *dns))
+	assert.Len(t, r.Adapters["WHOIS"], 2)
+	assert.Len(t, r.Adapters["DNS"], 1)
+
+	data, err := json.Marshal(r)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"DNS":{"query":"n(type=\"domain\")","enabled":true}`)
+	assert.Contains(t, string(data), `"WHOIS":{"n(type=\"domain\")":{"enabled":true},"n(type=\"ip\")":{"enabled":true}}`)
+}
+
+func Test_TaskStateCondition(t *testing.T) {
+	retry := map[TaskState]TaskState{TaskState_Errr: TaskState_Retry, TaskState_Void: TaskState_Retry}
+
+	// each form marshals to its wire form and back
+	forms := map[string]*TaskStateCondition{
+		`null`:                             nil,
+		`"error"`:                          StateSetTo(TaskState_Errr),
+		`["active","idle"]`:                StateIn(TaskState_Active, TaskState_Idle),
+		`{"error":"retry","void":"retry"}`: StateSetMatch(retry),
+	}
+	for wire, cond := range forms {
+		data, err := json.Marshal(cond)
+		assert.NoError(t, err)
+		assert.JSONEq(t, wire, string(data))
+
+		var back TaskStateCondition
+		assert.NoError(t, json.Unmarshal([]byte(wire), &back))
+		assert.Equal(t, cond.String(), back.String())
+	}
+	assert.Error(t, json.Unmarshal([]byte(`5`), &TaskStateCondition{}))
+
+	r := PrepareTaskResults(WithStateSetMatch(retry))
+	data, err := json.Marshal(r)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"state":{"error":"retry","void":"retry"}`)
+	data, err = json.Marshal(PrepareTaskResults())
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "state")
+
+	// the map form used to panic
+	assert.True(t, CheckState(retry, TaskState_Retry))
+	assert.True(t, CheckState(r.State, TaskState_Retry))
+	assert.False(t, CheckState(retry, TaskState_Done))
+	assert.True(t, CheckState(map[string]string{"error": "retry"}, TaskState_Retry))
+	assert.True(t, CheckState("error", TaskState_Errr))
+	assert.True(t, CheckState([]TaskState{TaskState_Idle}, TaskState_Idle))
+	assert.True(t, CheckState(nil, TaskState_Done))
+	assert.False(t, CheckState(42, TaskState_Done))
+
+	var none *TaskStateCondition
+	next, ok := none.Next(TaskState_Idle)
+	assert.True(t, ok)
+	assert.Equal(t, TaskState_Done, next)
+	_, ok = StateSetTo(TaskState_Errr).Next(TaskState_Done)
+	assert.False(t, ok)
+	next, ok = StateSetMatch(retry).Next(TaskState_Void)
+	assert.True(t, ok)
+	assert.Equal(t, TaskState_Retry, next)
+	next, ok = StateIn(TaskState_Active).Next(TaskState_Active)
+	assert.True(t, ok)
+	assert.Equal(t, TaskState_Active, next)
+	assert.Equal(t, []TaskState{TaskState_Errr, TaskState_Void}, StateSetMatch(retry).From())
+
+	assert.Equal(t, "active|idle -> done", none.String())
+	assert.Equal(t, "error -> retry, void -> retry", StateSetMatch(retry).String())
+	assert.Equal(t, "active|idle (unchanged)", StateIn(TaskState_Idle, TaskState_Active).String())
+
+	assert.NoError(t, none.Validate())
+	assert.NoError(t, StateSetTo(TaskState_Delete).Validate())
+	assert.NoError(t, StateSetMatch(retry).Validate())
+	assert.ErrorContains(t, StateSetTo(TaskState_Active).Validate(), "use retry")
+	assert.ErrorContains(t, StateSetTo("finished").Validate(), `unknown task state "finished"`)
+	assert.ErrorContains(t, StateIn(TaskState_Delete).Validate(), `never in state "delete"`)
+	assert.Error(t, StateIn().Validate())
+	assert.Error(t, StateSetMatch(map[TaskState]TaskState{"bogus": TaskState_Active}).Validate())
+}
+
+// goldenResults has results using every field, with the state in each of its forms
+func goldenResults(t *testing.T) map[string]*TaskResults {
+	domain, err := graph.Node(testNode{NodeMembers: graph.NodeMembers{Type: "domain", Value: "example.com"}, Note: "zone walk"})
+	assert.NoError(t, err)
+	existing, err := graph.Node(testNode{NodeMembers: graph.NodeMembers{ID: 7, Type: "ip", Value: "93.184.216.34"}})
+	assert.NoError(t, err)
+	resolves, err := graph.Edge(testEdge{EdgeMembers: graph.EdgeMembers{Type: "resolves"}, TTL: 300})
+	assert.NoError(t, err)
+	chain, err := graph.CreateChain(domain, resolves, existing)
+	assert.NoError(t, err)
+	standalone, err := graph.Edge(testEdge{EdgeMembers: graph.EdgeMembers{Type: "resolves", Source: domain, Target: existing}, TTL: 60})
+	assert.NoError(t, err)
+	byId, err := graph.Edge(testEdge{EdgeMembers: graph.EdgeMembers{Type: "alias", SourceId: "7", TargetId: "9"}})
+	assert.NoError(t, err)
+
+	dns := adapter.ConfigureAdapter("dns", adapter.WithQuery(`n(type="domain")`), adapter.WithLimit(10))
+	whoisDomains := adapter.ConfigureAdapter("whois", adapter.WithQuery(`n(type="domain")`))
+	whoisIps := adapter.ConfigureAdapter("whois", adapter.WithQuery(`n(type="ip")`), adapter.WithEnabled(false))
+
+	full := PrepareTaskResults(
+		WithNodes(domain, existing),
+		WithEdges(standalone, byId),
+		WithChains(chain),
+		WithDetails("walked example.com"),
+		WithAdapters(*dns, *whoisDomains, *whoisIps),
+	)
+	full.Timeout = 30
+
+	return map[string]*TaskResults{
+		"full":      full,
+		"set_to":    PrepareTaskResults(WithStateSetTo(TaskState_Errr), WithDetails("lookup failed")),
+		"states":    PrepareTaskResults(WithStates([]TaskState{TaskState_Active, TaskState_Idle}), WithNodes(domain)),
+		"set_match": PrepareTaskResults(WithStateSetMatch(map[TaskState]TaskState{TaskState_Errr: TaskState_Retry, TaskState_Void: TaskState_Retry})),
+		"empty":     PrepareTaskResults(),
+	}
+}
+
+func Test_TaskResults_Golden(t *testing.T) {
+	for name, results := range goldenResults(t) {
+		t.Run(name, func(t *testing.T) {
+			data, err := json.Marshal(results)
+			assert.NoError(t, err)
+			var indented bytes.Buffer
+			assert.NoError(t, json.Indent(&indented, data, "", "  "))
+			indented.WriteByte('\n')
+
+			path := filepath.Join("testdata", "results_"+name+".json")
+			if *update {
+				assert.NoError(t, os.WriteFile(path, indented.Bytes(), 0o644))
+			}
+			golden, err := os.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, string(golden), indented.String())
+
+			// reading the golden file back and writing it again gives the same JSON
+			var back TaskResults
+			assert.NoError(t, json.Unmarshal(golden, &back))
+			again, err := json.Marshal(back)
+			assert.NoError(t, err)
+			assert.JSONEq(t, string(golden), string(again))
+			assert.Equal(t, results.State.String(), back.State.String())
+			assert.Equal(t, results.Timeout, back.Timeout)
+			assert.Equal(t, results.Details, back.Details)
+			assert.Len(t, back.Nodes, len(results.Nodes))
+			assert.Len(t, back.Edges, len(results.Edges))
+			assert.Len(t, back.Chains, len(results.Chains))
+			assert.Equal(t, results.Adapters, back.Adapters)
+		})
+	}
+}
+
+func Test_TaskResults_MatchesJob(t *testing.T) {
+	results := goldenResults(t)["full"]
+
+	// chains and nodes encode the same way in a job as in task results
+	j := job.NewJob(job.WithNodes(results.Nodes...), job.WithChains(results.Chains...))
+	jobData, err := json.Marshal(j)
+	assert.NoError(t, err)
+	resultsData, err := json.Marshal(results)
+	assert.NoError(t, err)
+
+	var fromJob, fromResults struct {
+		Nodes  json.RawMessage `json:"nodes"`
+		Chains json.RawMessage `json:"chains"`
+	}
+	assert.NoError(t, json.Unmarshal(jobData, &fromJob))
+	assert.NoError(t, json.Unmarshal(resultsData, &fromResults))
+	assert.JSONEq(t, string(fromJob.Nodes), string(fromResults.Nodes))
+	assert.JSONEq(t, string(fromJob.Chains), string(fromResults.Chains))
+}
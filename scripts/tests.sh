@@ -2,10 +2,8 @@
 
 export LC_ALL=C
 time (
-curl -s "${LG_SERVICE:-http://localhost:8000}/graph" | jq -r '.[].graph' | \
-	xargs -r printf "${LG_SERVICE:-http://localhost:8000}/graph/%s\n" | \
-	xargs -r -n50 curl -XDELETE
+go run ./cmd/lgctl jobs rm -all > /dev/null
 sync
 )
 
-go test -v ./...
\ No newline at end of file
+go test -v ./...